@@ -0,0 +1,10 @@
+// Package zeepass holds the web assets compiled into the server binary, so
+// it runs from any working directory.
+package zeepass
+
+import "embed"
+
+// Templates holds the HTML page templates, as templates/<name>.html.
+//
+//go:embed templates/*.html
+var Templates embed.FS
@@ -1,37 +1,155 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/anazri/zeepass/internal/handlers"
 	"github.com/anazri/zeepass/internal/services"
+	"google.golang.org/grpc"
 )
 
 func main() {
-	services.InitRedis()
+	if err := services.InitEncryptionKey(); err != nil {
+		log.Fatalf("Invalid encryption key configuration: %v", err)
+	}
+	if err := handlers.InitContactRouting(); err != nil {
+		log.Fatalf("Invalid contact routing configuration: %v", err)
+	}
+	if err := services.InitRedis(); err != nil {
+		log.Fatalf("Redis configuration error: %v", err)
+	}
+	if err := services.InitStorage(); err != nil {
+		log.Fatalf("Invalid storage configuration: %v", err)
+	}
+	if err := services.ValidateIDStrategy(); err != nil {
+		log.Fatalf("Invalid ID configuration: %v", err)
+	}
+	if err := services.InitFileScanner(); err != nil {
+		log.Fatalf("Invalid file scanning configuration: %v", err)
+	}
 
 	http.HandleFunc("/", handlers.HomeHandler)
 	http.HandleFunc("/text-encryption", handlers.TextEncryptionHandler)
-	http.HandleFunc("/encrypt-text", handlers.EncryptTextHandler)
+	http.HandleFunc("/encrypt-text", handlers.CountToolUsage("text", handlers.EncryptTextHandler))
 	http.HandleFunc("/file-encryption", handlers.FileEncryptionHandler)
-	http.HandleFunc("/encrypt-file", handlers.EncryptFileHandler)
+	http.HandleFunc("/encrypt-file", handlers.CountToolUsage("file", handlers.EncryptFileHandler))
 	http.HandleFunc("/chat-encryption", handlers.ChatEncryptionHandler)
-	http.HandleFunc("/ws/chat", handlers.ChatWebSocketHandler)
+	http.HandleFunc("/ws/chat", handlers.CountToolUsage("chat", handlers.ChatWebSocketHandler))
+	http.HandleFunc("/sse/chat", handlers.CountToolUsage("chat", handlers.ChatSSEHandler))
+	http.HandleFunc("/sse/chat/send", handlers.ChatSSESendHandler)
+	http.HandleFunc("/rooms", handlers.RequireAdmin(handlers.RoomsHandler))
+	http.HandleFunc("/admin/usage", handlers.RequireAdmin(handlers.UsageStatsHandler))
 	http.HandleFunc("/password-generator", handlers.PasswordGeneratorHandler)
-	http.HandleFunc("/generate-password", handlers.GeneratePasswordHandler)
+	http.HandleFunc("/generate-password", handlers.CountToolUsage("password", handlers.GeneratePasswordHandler))
+	http.HandleFunc("/generate-passwords", handlers.CountToolUsage("password", handlers.GeneratePasswordsHandler))
+	http.HandleFunc("/api/password", handlers.CountToolUsage("password", handlers.PasswordAPIHandler))
+	http.HandleFunc("/api/password/share", handlers.CountToolUsage("password", handlers.SharePasswordHandler))
+	http.HandleFunc("/verify-passphrase", handlers.VerifyPassphraseHandler)
+	http.HandleFunc("/api/check-passwords", handlers.CheckPasswordsHandler)
+	http.HandleFunc("/api/webhook/messages", handlers.WebhookMessageHandler)
+	http.HandleFunc("/api/encrypt", handlers.EncryptAPIHandler)
+	http.HandleFunc("/api/decrypt", handlers.DecryptAPIHandler)
+	http.HandleFunc("/api/client-secrets", handlers.ClientSecretsHandler)
+	http.HandleFunc("/api/client-secrets/", handlers.ClientSecretsHandler)
 	http.HandleFunc("/base64", handlers.Base64Handler)
-	http.HandleFunc("/base64-encode", handlers.Base64EncodeHandler)
-	http.HandleFunc("/base64-decode", handlers.Base64DecodeHandler)
+	http.HandleFunc("/base64-encode", handlers.CountToolUsage("base64", handlers.Base64EncodeHandler))
+	http.HandleFunc("/base64-decode", handlers.CountToolUsage("base64", handlers.Base64DecodeHandler))
+	http.HandleFunc("/encode", handlers.CountToolUsage("base64", handlers.EncodeHandler))
+	http.HandleFunc("/decode", handlers.CountToolUsage("base64", handlers.DecodeHandler))
 	http.HandleFunc("/ssh-key", handlers.SSHKeyHandler)
-	http.HandleFunc("/generate-ssh-key", handlers.GenerateSSHKeyHandler)
+	http.HandleFunc("/generate-ssh-key", handlers.CountToolUsage("ssh", handlers.GenerateSSHKeyHandler))
+	http.HandleFunc("/ssh-key/inspect", handlers.CountToolUsage("ssh", handlers.InspectSSHKeyHandler))
 	http.HandleFunc("/view/", handlers.ViewEncryptedHandler)
 	http.HandleFunc("/view-file/", handlers.ViewEncryptedFileHandler)
+	http.HandleFunc("/status/", handlers.SecretStatusHandler)
 	http.HandleFunc("/contact", handlers.HandleContact)
 	http.HandleFunc("/survey", handlers.SurveyHandler)
 	http.HandleFunc("/feedback", handlers.HandleFeedback)
 	http.HandleFunc("/static/", handlers.StaticHandler)
+	http.HandleFunc("/csp-report", handlers.CSPReportHandler)
 
-	log.Println("ZeePass server starting on :8080")
-	log.Fatal(http.ListenAndServe(":8080", nil))
+	grpcServer, err := handlers.StartGRPCServer()
+	if err != nil {
+		log.Fatalf("Invalid gRPC configuration: %v", err)
+	}
+
+	server := &http.Server{
+		Addr:    ":8080",
+		Handler: handlers.RecoverPanics(handlers.SecurityHeaders(http.DefaultServeMux)),
+	}
+	go func() {
+		log.Println("ZeePass server starting on :8080")
+		if err := server.ListenAndServe(); err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-stop
+	signal.Stop(stop) // A second signal kills the process without waiting
+
+	log.Printf("Received %v, shutting down (waiting up to %v)", sig, shutdownTimeout())
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout())
+	defer cancel()
+	shutdown(ctx, server, grpcServer)
+	log.Println("ZeePass server stopped")
+}
+
+// shutdownTimeout bounds how long in-flight requests and chat connections
+// may take to finish on shutdown, set with SHUTDOWN_TIMEOUT_SECONDS
+// (default 15).
+func shutdownTimeout() time.Duration {
+	if n, err := strconv.Atoi(os.Getenv("SHUTDOWN_TIMEOUT_SECONDS")); err == nil && n > 0 {
+		return time.Duration(n) * time.Second
+	}
+	return 15 * time.Second
+}
+
+// shutdown stops accepting connections and drains the HTTP server, chat
+// connections and the gRPC server (if running) in parallel. Whatever is
+// still open when ctx is done is closed forcibly.
+func shutdown(ctx context.Context, server *http.Server, grpcServer *grpc.Server) {
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		// Chat streams are in-flight requests, so they must be drained for
+		// the HTTP server to finish
+		if err := services.GetChatService().Shutdown(ctx); err != nil {
+			log.Printf("Chat drain incomplete: %v", err)
+		}
+	}()
+
+	if grpcServer != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stopped := make(chan struct{})
+			go func() {
+				grpcServer.GracefulStop()
+				close(stopped)
+			}()
+			select {
+			case <-stopped:
+			case <-ctx.Done():
+				grpcServer.Stop()
+			}
+		}()
+	}
+
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("HTTP shutdown incomplete: %v", err)
+		server.Close()
+	}
+	wg.Wait()
 }
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/anazri/zeepass/internal/services"
+)
+
+// RequireAdmin wraps a privileged handler so it only runs for requests with
+// "Authorization: Bearer <ADMIN_TOKEN>". Other requests get 401. When no
+// ADMIN_TOKEN is configured the wrapped endpoint is disabled and answers 404,
+// so its existence is not advertised.
+func RequireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !checkAdminToken(w, r) {
+			return
+		}
+		next(w, r)
+	}
+}
+
+// checkAdminToken verifies the request's bearer token against ADMIN_TOKEN in
+// constant time and writes an error response when it does not match.
+func checkAdminToken(w http.ResponseWriter, r *http.Request) bool {
+	expected := os.Getenv("ADMIN_TOKEN")
+	if expected == "" {
+		http.NotFound(w, r)
+		return false
+	}
+	scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !strings.EqualFold(scheme, "Bearer") || !services.SecureCompare(strings.TrimSpace(token), expected) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="zeepass-admin"`)
+		w.Header().Set("Cache-Control", "no-store")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
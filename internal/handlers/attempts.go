@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/anazri/zeepass/internal/services"
+)
+
+// Wrong PINs, passphrases and security question answers are counted per
+// secret, so a short PIN cannot be brute forced and passphrase guesses cannot
+// keep the server busy with key derivations. After PIN_MAX_ATTEMPTS failures (default 5)
+// further attempts are refused until PIN_LOCKOUT_MINUTES (default 15) after
+// the first failure. With PIN_DELETE_AFTER_ATTEMPTS set, the secret is deleted
+// once that many failures have been made in total. A correct answer resets
+// the counts.
+
+func pinMaxAttempts() int {
+	if n, err := strconv.Atoi(os.Getenv("PIN_MAX_ATTEMPTS")); err == nil && n > 0 {
+		return n
+	}
+	return 5
+}
+
+func pinLockoutDuration() time.Duration {
+	if n, err := strconv.Atoi(os.Getenv("PIN_LOCKOUT_MINUTES")); err == nil && n > 0 {
+		return time.Duration(n) * time.Minute
+	}
+	return 15 * time.Minute
+}
+
+// pinDeleteAfterAttempts is the hard cap of failures, or 0 to keep secrets
+// however often they are guessed at.
+func pinDeleteAfterAttempts() int {
+	if n, err := strconv.Atoi(os.Getenv("PIN_DELETE_AFTER_ATTEMPTS")); err == nil && n > 0 {
+		return n
+	}
+	return 0
+}
+
+// pinLockedOut reports whether attempts on a secret are currently refused.
+// kind is "message" or "file".
+func pinLockedOut(kind, id string) bool {
+	failures, err := services.GetStorage().FailedAttempts(kind, id)
+	if err != nil {
+		log.Printf("Error reading failed attempts of %s %s: %v", kind, id, err)
+		return false
+	}
+	return failures >= pinMaxAttempts()
+}
+
+// recordFailedPIN counts a wrong PIN or answer and reports whether it used up
+// the hard cap, in which case the secret has been deleted.
+func recordFailedPIN(kind, id string, expiresAt *time.Time) bool {
+	recent, total, err := services.GetStorage().RecordFailedAttempt(kind, id, pinLockoutDuration(), expiresAt)
+	if err != nil {
+		log.Printf("Error recording failed attempt on %s %s: %v", kind, id, err)
+		return false
+	}
+	if recent == pinMaxAttempts() {
+		log.Printf("Too many failed attempts on %s %s, locked for %v", kind, id, pinLockoutDuration())
+	}
+
+	limit := pinDeleteAfterAttempts()
+	if limit == 0 || total < limit {
+		return false
+	}
+	log.Printf("Deleting %s %s after %d failed attempts", kind, id, total)
+	if kind == "file" {
+		err = services.GetStorage().DeleteFile(id)
+	} else {
+		err = services.GetStorage().DeleteMessage(id)
+	}
+	if err != nil {
+		log.Printf("Error deleting %s %s: %v", kind, id, err)
+	}
+	return true
+}
+
+// clearFailedPINs resets the counts once a correct PIN or answer was given.
+func clearFailedPINs(kind, id string) {
+	if err := services.GetStorage().ResetFailedAttempts(kind, id); err != nil {
+		log.Printf("Error resetting failed attempts of %s %s: %v", kind, id, err)
+	}
+}
+
+// showTooManyAttempts refuses an attempt on a locked secret.
+func showTooManyAttempts(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(pinLockoutDuration().Seconds())))
+	w.WriteHeader(http.StatusTooManyRequests)
+	renderViewStatus(w, viewStatusPage{
+		Title:   "Too Many Attempts",
+		Heading: "Too Many Attempts",
+		Message: "Too many incorrect attempts were made. Please try again later.",
+	})
+}
+
+// showAttemptsExhausted is shown when the attempt that reached the hard cap
+// deleted the secret. kind is "message" or "file".
+func showAttemptsExhausted(w http.ResponseWriter, kind string) {
+	renderViewStatus(w, viewStatusPage{
+		Title:   "Too Many Attempts",
+		Heading: "Too Many Attempts",
+		Message: "Too many incorrect attempts were made, so this " + kind + " has been deleted.",
+	})
+}
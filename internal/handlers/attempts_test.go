@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anazri/zeepass/internal/models"
+	"github.com/anazri/zeepass/internal/services"
+)
+
+// storeTestMessage stores text as message id. A non-empty pin or passphrase
+// protects it the way the text encryption form does.
+func storeTestMessage(t *testing.T, id, text, pin, passphrase string, maxViews int) {
+	t.Helper()
+	key := services.GetEncryptionKey()
+	data := &models.EncryptedData{
+		ID:        id,
+		Lifetime:  "1h",
+		CreatedAt: time.Now(),
+		MaxViews:  maxViews,
+	}
+	expiresAt := time.Now().Add(time.Hour)
+	data.ExpiresAt = &expiresAt
+	if pin != "" {
+		data.PIN = services.HashPIN(pin)
+	}
+	if passphrase != "" {
+		salt, err := services.NewPassphraseSalt()
+		if err != nil {
+			t.Fatal(err)
+		}
+		key, err = services.DeriveKeyFromPassphrase(passphrase, salt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data.PassphraseSalt = base64.StdEncoding.EncodeToString(salt)
+	}
+	content, err := services.Encrypt(text, key, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data.Content = content
+	if err := services.GetStorage().StoreMessage(id, data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// submitView posts the view form of message id.
+func submitView(id string, form url.Values) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/view/"+id, strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	ViewEncryptedHandler(w, r)
+	return w
+}
+
+func TestWrongPINsLockOut(t *testing.T) {
+	storeTestMessage(t, "pin-lock", "the secret", "1234", "", 1)
+
+	for i := 0; i < pinMaxAttempts(); i++ {
+		if w := submitView("pin-lock", url.Values{"pin": {"0000"}}); !strings.Contains(w.Body.String(), "Invalid PIN") {
+			t.Fatalf("attempt %d: status %d", i+1, w.Code)
+		}
+	}
+
+	w := submitView("pin-lock", url.Values{"pin": {"1234"}})
+	if w.Code != http.StatusTooManyRequests || strings.Contains(w.Body.String(), "the secret") {
+		t.Fatalf("correct PIN during lockout: status %d", w.Code)
+	}
+	if data, err := services.GetStorage().GetMessage("pin-lock"); err != nil || data.ViewCount != 0 {
+		t.Fatal("locked message was viewed or deleted")
+	}
+}
+
+func TestWrongPassphrasesLockOut(t *testing.T) {
+	storeTestMessage(t, "pass-lock", "the secret", "", "correct horse", 1)
+
+	for i := 0; i < pinMaxAttempts(); i++ {
+		if w := submitView("pass-lock", url.Values{"passphrase": {"wrong"}}); !strings.Contains(w.Body.String(), "Incorrect Passphrase") {
+			t.Fatalf("attempt %d: status %d", i+1, w.Code)
+		}
+	}
+	if failures, _ := services.GetStorage().FailedAttempts("message", "pass-lock"); failures != pinMaxAttempts() {
+		t.Fatalf("%d wrong passphrases recorded, want %d", failures, pinMaxAttempts())
+	}
+
+	w := submitView("pass-lock", url.Values{"passphrase": {"correct horse"}})
+	if w.Code != http.StatusTooManyRequests || strings.Contains(w.Body.String(), "the secret") {
+		t.Fatalf("correct passphrase during lockout: status %d", w.Code)
+	}
+}
+
+func TestCorrectPassphraseResetsFailures(t *testing.T) {
+	storeTestMessage(t, "pass-reset", "the secret", "", "correct horse", 3)
+
+	for i := 0; i < pinMaxAttempts()-1; i++ {
+		submitView("pass-reset", url.Values{"passphrase": {"wrong"}})
+	}
+	if w := submitView("pass-reset", url.Values{"passphrase": {"correct horse"}}); !strings.Contains(w.Body.String(), "the secret") {
+		t.Fatalf("correct passphrase: status %d", w.Code)
+	}
+	if failures, _ := services.GetStorage().FailedAttempts("message", "pass-reset"); failures != 0 {
+		t.Fatalf("%d failures left after the correct passphrase", failures)
+	}
+}
+
+func TestSecretDeletedAfterAttemptCap(t *testing.T) {
+	t.Setenv("PIN_DELETE_AFTER_ATTEMPTS", "3")
+	storeTestMessage(t, "pin-cap", "the secret", "1234", "", 1)
+
+	for i := 0; i < 2; i++ {
+		submitView("pin-cap", url.Values{"pin": {"0000"}})
+	}
+	if w := submitView("pin-cap", url.Values{"pin": {"0000"}}); !strings.Contains(w.Body.String(), "has been deleted") {
+		t.Fatalf("attempt at the cap: status %d", w.Code)
+	}
+	if _, err := services.GetStorage().GetMessage("pin-cap"); err == nil {
+		t.Fatal("message still stored after the attempt cap")
+	}
+}
@@ -1,36 +1,53 @@
 package handlers
 
 import (
-	"html/template"
+	"encoding/json"
 	"net/http"
-	"log"
 
 	"github.com/anazri/zeepass/internal/services"
 )
 
 // ChatEncryptionHandler handles the chat encryption page
 func ChatEncryptionHandler(w http.ResponseWriter, r *http.Request) {
-	tmpl, err := template.ParseFiles("templates/chat-encryption.html")
-	if err != nil {
-		http.Error(w, "Error loading template", http.StatusInternalServerError)
-		log.Printf("Template error: %v", err)
-		return
-	}
-
 	data := struct {
 		Title string
 	}{
 		Title: "Chat Encryption - ZeePass",
 	}
 
-	if err := tmpl.Execute(w, data); err != nil {
-		http.Error(w, "Error executing template", http.StatusInternalServerError)
-		log.Printf("Template execution error: %v", err)
-	}
+	renderTemplate(w, "chat-encryption.html", data)
 }
 
 // ChatWebSocketHandler handles WebSocket connections for real-time chat
 func ChatWebSocketHandler(w http.ResponseWriter, r *http.Request) {
 	chatService := services.GetChatService()
 	chatService.HandleWebSocket(w, r)
-}
\ No newline at end of file
+}
+
+// ChatSSEHandler streams a chat room as server-sent events, the fallback for
+// networks that block WebSockets
+func ChatSSEHandler(w http.ResponseWriter, r *http.Request) {
+	services.GetChatService().HandleSSE(w, r)
+}
+
+// ChatSSESendHandler accepts frames from clients on the SSE transport
+func ChatSSESendHandler(w http.ResponseWriter, r *http.Request) {
+	services.GetChatService().HandleSSESend(w, r)
+}
+
+// RoomsHandler lists the active chat rooms with their participant counts as
+// JSON. It is an admin endpoint, served behind RequireAdmin.
+func RoomsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rooms := services.GetChatService().ListRooms()
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"rooms": rooms,
+		"count": len(rooms),
+	})
+}
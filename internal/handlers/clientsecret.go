@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/anazri/zeepass/internal/models"
+	"github.com/anazri/zeepass/internal/services"
+)
+
+// Client-encrypted secrets are encrypted in the browser with AES-256-GCM under
+// a random key that only ever appears in the share URL fragment, which
+// browsers do not send to the server. The server stores the ciphertext as an
+// opaque blob and hands it back once per view; it never sees the key or the
+// plaintext.
+//
+//	POST /api/client-secrets       {"ciphertext", "lifetime", "ttl", "max_views", "confirm_risk"}
+//	POST /api/client-secrets/{id}  returns {"ciphertext", "views_remaining"} and counts a view
+
+// clientCipherOverhead is the IV and GCM tag the browser adds to the text.
+const clientCipherOverhead = 12 + 16
+
+type clientSecretRequest struct {
+	Ciphertext  string `json:"ciphertext"`
+	Lifetime    string `json:"lifetime"`
+	TTL         string `json:"ttl"`
+	MaxViews    int    `json:"max_views"`
+	ConfirmRisk bool   `json:"confirm_risk"`
+}
+
+// clientSecretResponse carries a stored blob back to the view page.
+// ViewsRemaining is null for secrets limited only by their lifetime.
+type clientSecretResponse struct {
+	Ciphertext     string `json:"ciphertext"`
+	ViewsRemaining *int   `json:"views_remaining"`
+}
+
+// ClientSecretsHandler stores and releases client-encrypted secrets. Both
+// operations are POSTs, so link prefetchers cannot use up a view.
+func ClientSecretsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/client-secrets"), "/")
+	if id == "" {
+		storeClientSecret(w, r)
+		return
+	}
+	releaseClientSecret(w, id)
+}
+
+func storeClientSecret(w http.ResponseWriter, r *http.Request) {
+	maxCiphertext := base64.StdEncoding.EncodedLen(maxTextSize() + clientCipherOverhead)
+
+	var req clientSecretRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, int64(maxCiphertext)+4096))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		writeEncryptionError(w, http.StatusBadRequest, "Malformed JSON payload: "+err.Error())
+		return
+	}
+
+	if len(req.Ciphertext) > maxCiphertext {
+		writeEncryptionError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("ciphertext is too large (max %d bytes of text)", maxTextSize()))
+		return
+	}
+	blob, err := base64.StdEncoding.DecodeString(req.Ciphertext)
+	if err != nil || len(blob) <= clientCipherOverhead {
+		writeEncryptionError(w, http.StatusBadRequest, "ciphertext must be the base64 encoded IV, ciphertext and tag")
+		return
+	}
+	// base64 pads to whole 3-byte groups, so the length check above can be
+	// off by up to two bytes
+	if len(blob) > maxTextSize()+clientCipherOverhead {
+		writeEncryptionError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("ciphertext is too large (max %d bytes of text)", maxTextSize()))
+		return
+	}
+	if req.Lifetime == "" {
+		req.Lifetime = "once"
+	}
+
+	if message := lifetimeRiskMessage(req.Lifetime, req.TTL, req.ConfirmRisk); message != "" {
+		writeEncryptionError(w, http.StatusBadRequest, message)
+		return
+	}
+	lifetime, resolved, err := resolveRequestLifetime(req.Lifetime, req.TTL)
+	if err != nil {
+		writeEncryptionError(w, http.StatusBadRequest, "lifetime not allowed: "+err.Error())
+		return
+	}
+	if req.MaxViews != 0 {
+		if message := setMaxViews(&resolved, req.MaxViews); message != "" {
+			writeEncryptionError(w, http.StatusBadRequest, message)
+			return
+		}
+	}
+
+	id := services.GenerateID()
+	data := &models.EncryptedData{
+		ID:              id,
+		Content:         req.Ciphertext,
+		Lifetime:        lifetime,
+		CreatedAt:       time.Now(),
+		ExpiresAt:       resolved.ExpiresAt,
+		MaxViews:        resolved.MaxViews,
+		ClientEncrypted: true,
+	}
+	if err := services.GetStorage().StoreMessage(id, data); err != nil {
+		log.Printf("Error storing client-encrypted secret: %v", err)
+		writeEncryptionError(w, http.StatusInternalServerError, "error storing encrypted data")
+		return
+	}
+	log.Printf("Stored client-encrypted data for ID: %s", id)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(models.EncryptionResponse{
+		Success: true,
+		ID:      id,
+		URL:     buildShareURL(r, "/view/"+id),
+		Message: "Ciphertext stored, lifetime: " + getResolvedLifetimeDisplay(lifetime, resolved),
+	})
+}
+
+// releaseClientSecret returns a client-encrypted blob and counts the view,
+// following the same expiry and view limit rules as the view page.
+func releaseClientSecret(w http.ResponseWriter, id string) {
+	data, err := services.GetStorage().GetMessage(id)
+	if err != nil || !data.ClientEncrypted {
+		http.Error(w, "Secret not found", http.StatusNotFound)
+		return
+	}
+	if data.ExpiresAt != nil && time.Now().After(*data.ExpiresAt) {
+		services.GetStorage().DeleteMessage(id)
+		http.Error(w, "Secret not found", http.StatusNotFound)
+		return
+	}
+
+	permitted, err := claimMessageView(id, data)
+	if err != nil {
+		http.Error(w, "Error retrieving secret", http.StatusInternalServerError)
+		return
+	}
+	if !permitted {
+		http.Error(w, "Secret not found", http.StatusNotFound)
+		return
+	}
+
+	resp := clientSecretResponse{Ciphertext: data.Content}
+	if data.MaxViews < services.UnlimitedViews {
+		remaining := max(data.MaxViews-data.ViewCount, 0)
+		resp.ViewsRemaining = &remaining
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/anazri/zeepass/internal/models"
+	"github.com/anazri/zeepass/internal/services"
+)
+
+func postClientSecret(path, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	ClientSecretsHandler(w, r)
+	return w
+}
+
+func TestClientSecretIsStoredOpaqueAndReleasedOnce(t *testing.T) {
+	// The server never sees the browser's key, so any blob of the right
+	// shape is accepted and handed back unchanged
+	blob := make([]byte, clientCipherOverhead+64)
+	rand.Read(blob)
+	ciphertext := base64.StdEncoding.EncodeToString(blob)
+
+	w := postClientSecret("/api/client-secrets", `{"ciphertext": "`+ciphertext+`", "lifetime": "once"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("store: status %d: %s", w.Code, w.Body)
+	}
+	var stored models.EncryptionResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &stored); err != nil || stored.ID == "" {
+		t.Fatalf("store response %q: %v", w.Body, err)
+	}
+	data, err := services.GetStorage().GetMessage(stored.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !data.ClientEncrypted || data.Content != ciphertext {
+		t.Fatalf("stored record: ClientEncrypted=%v, content changed=%v", data.ClientEncrypted, data.Content != ciphertext)
+	}
+
+	w = postClientSecret("/api/client-secrets/"+stored.ID, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("release: status %d: %s", w.Code, w.Body)
+	}
+	var released clientSecretResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &released); err != nil || released.Ciphertext != ciphertext {
+		t.Fatalf("release returned %q, %v", w.Body, err)
+	}
+	if released.ViewsRemaining == nil || *released.ViewsRemaining != 0 {
+		t.Errorf("views_remaining = %v, want 0", released.ViewsRemaining)
+	}
+
+	if w := postClientSecret("/api/client-secrets/"+stored.ID, ""); w.Code != http.StatusNotFound {
+		t.Errorf("second release: status %d, want 404", w.Code)
+	}
+}
+
+func TestClientSecretRejectsBadCiphertext(t *testing.T) {
+	t.Setenv("MAX_TEXT_SIZE", "64")
+	tests := map[string]struct {
+		ciphertext string
+		want       int
+	}{
+		"not base64":   {"***", http.StatusBadRequest},
+		"only an IV":   {base64.StdEncoding.EncodeToString(make([]byte, clientCipherOverhead)), http.StatusBadRequest},
+		"over limit":   {base64.StdEncoding.EncodeToString(make([]byte, clientCipherOverhead+65)), http.StatusRequestEntityTooLarge},
+		"at the limit": {base64.StdEncoding.EncodeToString(make([]byte, clientCipherOverhead+64)), http.StatusCreated},
+	}
+	for name, tt := range tests {
+		w := postClientSecret("/api/client-secrets", `{"ciphertext": "`+tt.ciphertext+`", "lifetime": "once"}`)
+		if w.Code != tt.want {
+			t.Errorf("%s: status %d, want %d: %s", name, w.Code, tt.want, w.Body)
+		}
+	}
+
+	// Server-side releases are not served as client secrets
+	id := "server-encrypted"
+	storeTestMessage(t, id, "text", "", "", 1)
+	if w := postClientSecret("/api/client-secrets/"+id, ""); w.Code != http.StatusNotFound {
+		t.Errorf("release of a server-encrypted message: status %d, want 404", w.Code)
+	}
+}
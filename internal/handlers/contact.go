@@ -1,10 +1,15 @@
 package handlers
 
 import (
+	"crypto/tls"
 	"fmt"
+	"log"
+	"net"
 	"net/http"
+	"net/mail"
 	"net/smtp"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -17,12 +22,33 @@ type ContactForm struct {
 	Message     string `form:"message"`
 }
 
+// contactRateLimit is the number of contact form submissions each client IP
+// may make per hour, set with CONTACT_RATE_LIMIT (default 3).
+func contactRateLimit() int {
+	if n, err := strconv.Atoi(os.Getenv("CONTACT_RATE_LIMIT")); err == nil && n > 0 {
+		return n
+	}
+	return 3
+}
+
+var contactLimiter = &clientRateLimiter{windows: make(map[string]*rateWindow), period: time.Hour}
+
+// contactHoneypotField is a form field hidden from people. Bots that fill in
+// every field get a normal success response, but nothing is sent.
+const contactHoneypotField = "website"
+
 func HandleContact(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
+	if !contactLimiter.allow(getClientIP(r), contactRateLimit()) {
+		w.Header().Set("Retry-After", "3600")
+		http.Error(w, "Too many messages. Please try again later.", http.StatusTooManyRequests)
+		return
+	}
+
 	// Parse form data
 	if err := r.ParseForm(); err != nil {
 		http.Error(w, "Failed to parse form", http.StatusBadRequest)
@@ -37,16 +63,24 @@ func HandleContact(w http.ResponseWriter, r *http.Request) {
 		Message:     strings.TrimSpace(r.FormValue("message")),
 	}
 
+	if r.FormValue(contactHoneypotField) != "" {
+		log.Printf("Dropped contact form submission from %s: honeypot field filled", getClientIP(r))
+		writeContactSent(w)
+		return
+	}
+
 	// Basic validation
 	if form.Name == "" || form.Email == "" || form.Message == "" {
 		http.Error(w, "Name, email, and message are required", http.StatusBadRequest)
 		return
 	}
 
-	if !isValidEmail(form.Email) {
+	email, ok := normalizeEmail(form.Email)
+	if !ok {
 		http.Error(w, "Invalid email address", http.StatusBadRequest)
 		return
 	}
+	form.Email = email
 
 	// Send email
 	if err := sendContactEmail(form); err != nil {
@@ -55,6 +89,11 @@ func HandleContact(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	writeContactSent(w)
+}
+
+// writeContactSent shows the "Message Sent!" dialog.
+func writeContactSent(w http.ResponseWriter) {
 	// Return success response (you could redirect to a thank you page)
 	w.Header().Set("Content-Type", "text/html")
 	w.WriteHeader(http.StatusOK)
@@ -84,7 +123,7 @@ func sendContactEmail(form ContactForm) error {
 	smtpPort := os.Getenv("SMTP_PORT")
 	smtpUser := os.Getenv("SMTP_USER")
 	smtpPass := os.Getenv("SMTP_PASS")
-	toEmail := os.Getenv("CONTACT_EMAIL")
+	toEmail := getContactRecipient(form.InquiryType)
 
 	// Use defaults if not set
 	if smtpHost == "" {
@@ -93,13 +132,11 @@ func sendContactEmail(form ContactForm) error {
 	if smtpPort == "" {
 		smtpPort = "587"
 	}
-	if toEmail == "" {
-		toEmail = "contact@moonkite.io"
-	}
 
 	// If SMTP credentials are not configured, log the message instead
 	if smtpUser == "" || smtpPass == "" {
 		fmt.Printf("SMTP not configured. Contact form submission:\n")
+		fmt.Printf("To: %s\n", toEmail)
 		fmt.Printf("Name: %s\n", form.Name)
 		fmt.Printf("Email: %s\n", form.Email)
 		fmt.Printf("Company: %s\n", form.Company)
@@ -139,11 +176,135 @@ func sendContactEmail(form ContactForm) error {
 	msg := fmt.Sprintf("To: %s\r\nFrom: %s\r\nSubject: %s\r\n\r\n%s",
 		toEmail, smtpUser, subject, body)
 
-	// Send email
-	auth := smtp.PlainAuth("", smtpUser, smtpPass, smtpHost)
-	addr := fmt.Sprintf("%s:%s", smtpHost, smtpPort)
+	return sendSMTPMail(smtpHost, smtpPort, smtpUser, smtpPass, toEmail, []byte(msg))
+}
+
+// smtpTimeout bounds the whole SMTP exchange for one email.
+const smtpTimeout = 30 * time.Second
+
+// sendSMTPMail authenticates as user and sends msg to a single recipient. The
+// connection is encrypted before credentials are sent: with SMTP_TLS=implicit
+// it is TLS from the start (usually port 465), otherwise the server must offer
+// STARTTLS. Only a relay on the loopback interface may be used in the clear.
+func sendSMTPMail(host, port, user, pass, to string, msg []byte) error {
+	mode := strings.ToLower(strings.TrimSpace(os.Getenv("SMTP_TLS")))
+	if mode != "" && mode != "starttls" && mode != "implicit" {
+		return fmt.Errorf("invalid SMTP_TLS %q (expected starttls or implicit)", mode)
+	}
+
+	addr := net.JoinHostPort(host, port)
+	tlsConfig := &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+	dialer := &net.Dialer{Timeout: smtpTimeout}
+
+	var conn net.Conn
+	var err error
+	if mode == "implicit" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %v", addr, err)
+	}
+	conn.SetDeadline(time.Now().Add(smtpTimeout))
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to start SMTP session with %s: %v", addr, err)
+	}
+	defer client.Close()
 
-	return smtp.SendMail(addr, auth, smtpUser, []string{toEmail}, []byte(msg))
+	if mode != "implicit" {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(tlsConfig); err != nil {
+				return fmt.Errorf("STARTTLS with %s failed: %v", addr, err)
+			}
+		} else if !isLoopbackHost(host) {
+			return fmt.Errorf("%s does not offer STARTTLS, refusing to send credentials in the clear", addr)
+		}
+	}
+
+	if err := client.Auth(smtp.PlainAuth("", user, pass, host)); err != nil {
+		return fmt.Errorf("SMTP authentication failed: %v", err)
+	}
+	if err := client.Mail(user); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to); err != nil {
+		return err
+	}
+	body, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := body.Write(msg); err != nil {
+		return err
+	}
+	if err := body.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// isLoopbackHost reports whether host names this machine.
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// contactRoutes maps inquiry types to destination addresses. It is loaded
+// from CONTACT_ROUTES by InitContactRouting.
+var contactRoutes = map[string]string{}
+
+// InitContactRouting loads per-inquiry-type destinations from CONTACT_ROUTES,
+// a comma-separated list of type=address pairs such as
+// "support=support@example.com,cloud=sales@example.com". Inquiry types without
+// a route are sent to CONTACT_EMAIL. All configured addresses are validated.
+func InitContactRouting() error {
+	if defaultEmail := os.Getenv("CONTACT_EMAIL"); defaultEmail != "" {
+		if _, err := mail.ParseAddress(defaultEmail); err != nil {
+			return fmt.Errorf("invalid CONTACT_EMAIL %q: %v", defaultEmail, err)
+		}
+	}
+
+	routes := make(map[string]string)
+	config := strings.TrimSpace(os.Getenv("CONTACT_ROUTES"))
+	if config != "" {
+		for _, entry := range strings.Split(config, ",") {
+			parts := strings.SplitN(entry, "=", 2)
+			if len(parts) != 2 {
+				return fmt.Errorf("invalid CONTACT_ROUTES entry %q (expected type=address)", entry)
+			}
+
+			inquiryType := strings.TrimSpace(parts[0])
+			address := strings.TrimSpace(parts[1])
+			if inquiryType == "" {
+				return fmt.Errorf("invalid CONTACT_ROUTES entry %q: missing inquiry type", entry)
+			}
+			if _, err := mail.ParseAddress(address); err != nil {
+				return fmt.Errorf("invalid CONTACT_ROUTES address for %q: %v", inquiryType, err)
+			}
+			routes[inquiryType] = address
+		}
+	}
+
+	contactRoutes = routes
+	return nil
+}
+
+// getContactRecipient returns the destination address for an inquiry type.
+func getContactRecipient(inquiryType string) string {
+	if address, ok := contactRoutes[inquiryType]; ok {
+		return address
+	}
+	if address := os.Getenv("CONTACT_EMAIL"); address != "" {
+		return address
+	}
+	return "contact@moonkite.io"
 }
 
 func getInquiryTypeLabel(inquiryType string) string {
@@ -167,7 +328,13 @@ func getInquiryTypeLabel(inquiryType string) string {
 	}
 }
 
-func isValidEmail(email string) bool {
-	// Basic email validation
-	return strings.Contains(email, "@") && strings.Contains(email, ".") && len(email) > 5
+// normalizeEmail parses a submitted address per RFC 5322 and returns its bare
+// addr-spec form, dropping any display name. It reports false for anything
+// that is not a single valid address.
+func normalizeEmail(email string) (string, bool) {
+	address, err := mail.ParseAddress(email)
+	if err != nil {
+		return "", false
+	}
+	return address.Address, true
 }
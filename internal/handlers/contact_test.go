@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+)
+
+// postContact fills the honeypot field so no mail is sent; the submission
+// still counts towards the rate limit.
+func postContact(remoteAddr, forwardedFor string) int {
+	form := url.Values{contactHoneypotField: {"http://spam.example"}}
+	r := httptest.NewRequest(http.MethodPost, "/contact", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.RemoteAddr = remoteAddr
+	if forwardedFor != "" {
+		r.Header.Set("X-Forwarded-For", forwardedFor)
+	}
+	w := httptest.NewRecorder()
+	HandleContact(w, r)
+	return w.Code
+}
+
+func TestContactRateLimit(t *testing.T) {
+	t.Setenv("CONTACT_RATE_LIMIT", "2")
+	contactLimiter.windows = make(map[string]*rateWindow)
+
+	for i := 0; i < 2; i++ {
+		if code := postContact("203.0.113.7:5000", ""); code != http.StatusOK {
+			t.Fatalf("submission %d got %d, want 200", i+1, code)
+		}
+	}
+	// Rotating X-Forwarded-For must not reset the limit
+	if code := postContact("203.0.113.7:5000", "198.51.100.9"); code != http.StatusTooManyRequests {
+		t.Fatalf("third submission got %d, want 429", code)
+	}
+	if code := postContact("203.0.113.8:5000", ""); code != http.StatusOK {
+		t.Errorf("another client got %d, want 200", code)
+	}
+}
+
+func TestContactHoneypotDropsSubmission(t *testing.T) {
+	contactLimiter.windows = make(map[string]*rateWindow)
+	// Any attempt to send mail fails, so a 200 means nothing was sent
+	t.Setenv("SMTP_HOST", "127.0.0.1")
+	t.Setenv("SMTP_PORT", "1")
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	form := url.Values{
+		"name":               {"Bot"},
+		"email":              {"bot@example.com"},
+		"message":            {"Buy now"},
+		contactHoneypotField: {"http://spam.example"},
+	}
+	r := httptest.NewRequest(http.MethodPost, "/contact", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.RemoteAddr = "203.0.113.20:5000"
+	w := httptest.NewRecorder()
+	HandleContact(w, r)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Message Sent!") {
+		t.Fatalf("honeypot submission got %d, want the normal success response", w.Code)
+	}
+	if !strings.Contains(logged.String(), "Dropped contact form submission from 203.0.113.20: honeypot field filled") {
+		t.Errorf("dropped submission was not logged, log: %q", logged.String())
+	}
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/anazri/zeepass/internal/services"
+)
+
+// statelessContextPrefix separates the associated data of stateless
+// encryption (the /api/encrypt and /api/decrypt endpoints and the gRPC
+// Encrypt and Decrypt calls) from the IDs used for stored secrets, so the
+// decryption endpoints cannot open stored ciphertexts.
+const statelessContextPrefix = "api/"
+
+var cryptoAPILimiter = &clientRateLimiter{windows: make(map[string]*rateWindow)}
+
+// cryptoAPIRateLimit is the number of /api/encrypt and /api/decrypt requests
+// each API key may make per minute, set with CRYPTO_API_RATE_LIMIT
+// (default 60).
+func cryptoAPIRateLimit() int {
+	if n, err := strconv.Atoi(os.Getenv("CRYPTO_API_RATE_LIMIT")); err == nil && n > 0 {
+		return n
+	}
+	return 60
+}
+
+// authorizeCryptoAPI checks the API key and rate limit shared by the
+// stateless encryption endpoints and decodes the JSON body into v.
+func authorizeCryptoAPI(w http.ResponseWriter, r *http.Request, v interface{}) (string, bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return "", false
+	}
+
+	caller, ok := apiKeyCaller(r, "CRYPTO_API_KEYS")
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="zeepass"`)
+		http.Error(w, "Invalid or missing API key", http.StatusUnauthorized)
+		return "", false
+	}
+	if !cryptoAPILimiter.allow(caller, cryptoAPIRateLimit()) {
+		w.Header().Set("Retry-After", "60")
+		http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+		return "", false
+	}
+
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		http.Error(w, "Content-Type must be application/json", http.StatusUnsupportedMediaType)
+		return "", false
+	}
+	// Leave room for base64 and JSON escaping
+	body := http.MaxBytesReader(w, r.Body, int64(maxTextSize())*2+4096)
+	if err := json.NewDecoder(body).Decode(v); err != nil {
+		http.Error(w, "Malformed JSON payload", http.StatusBadRequest)
+		return "", false
+	}
+	return caller, true
+}
+
+// EncryptAPIHandler encrypts text under the server key without storing it,
+// for callers that keep the ciphertext themselves: POST /api/encrypt
+// {"plaintext": "...", "context": "..."} returns {"ciphertext": "<base64>"}.
+// The optional context is bound to the ciphertext and must be passed again
+// to decrypt it.
+func EncryptAPIHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Plaintext string `json:"plaintext"`
+		Context   string `json:"context"`
+	}
+	caller, ok := authorizeCryptoAPI(w, r, &req)
+	if !ok {
+		return
+	}
+	if len(req.Plaintext) > maxTextSize() {
+		http.Error(w, "plaintext is too large (max "+strconv.Itoa(maxTextSize())+" bytes)", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	ciphertext, err := services.Encrypt(req.Plaintext, services.GetEncryptionKey(), statelessContextPrefix+req.Context)
+	if err != nil {
+		log.Printf("Error encrypting for API caller %s: %v", caller, err)
+		http.Error(w, "Encryption failed", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]string{"ciphertext": ciphertext})
+}
+
+// DecryptAPIHandler decrypts a ciphertext produced by EncryptAPIHandler (or
+// the gRPC Encrypt call): POST /api/decrypt {"ciphertext": "<base64>",
+// "context": "..."} returns {"plaintext": "..."}. Stored secrets and
+// passphrase-protected content cannot be opened this way. It acts as a
+// decryption oracle for the server key, so it requires an API key from
+// CRYPTO_API_KEYS and is rate limited per key.
+func DecryptAPIHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Ciphertext string `json:"ciphertext"`
+		Context    string `json:"context"`
+	}
+	caller, ok := authorizeCryptoAPI(w, r, &req)
+	if !ok {
+		return
+	}
+	if req.Ciphertext == "" {
+		http.Error(w, "ciphertext is required", http.StatusBadRequest)
+		return
+	}
+
+	plaintext, err := services.Decrypt(req.Ciphertext, services.GetEncryptionKey(), statelessContextPrefix+req.Context)
+	if err != nil {
+		log.Printf("API caller %s sent a ciphertext that could not be decrypted", caller)
+		http.Error(w, "Ciphertext could not be decrypted with this context", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]string{"plaintext": plaintext})
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/anazri/zeepass/internal/services"
+)
+
+const testCryptoAPIKey = "crypto-test-key"
+
+func setupCryptoAPI(t *testing.T) {
+	t.Helper()
+	t.Setenv("CRYPTO_API_KEYS", "tests="+testCryptoAPIKey)
+	t.Setenv("CRYPTO_API_RATE_LIMIT", "1000")
+	cryptoAPILimiter.windows = make(map[string]*rateWindow)
+}
+
+// postCryptoAPI sends body to handler with the test API key.
+func postCryptoAPI(handler http.HandlerFunc, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/api/crypto", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Authorization", "Bearer "+testCryptoAPIKey)
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+func TestCryptoAPIRoundTrip(t *testing.T) {
+	setupCryptoAPI(t)
+
+	w := postCryptoAPI(EncryptAPIHandler, `{"plaintext": "secret value", "context": "customer-42"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("encrypt: status %d: %s", w.Code, w.Body)
+	}
+	var encrypted struct{ Ciphertext string }
+	if err := json.Unmarshal(w.Body.Bytes(), &encrypted); err != nil || encrypted.Ciphertext == "" {
+		t.Fatalf("encrypt response %q: %v", w.Body, err)
+	}
+	if got := w.Header().Get("Cache-Control"); got != "no-store" {
+		t.Errorf("Cache-Control = %q, want no-store", got)
+	}
+
+	w = postCryptoAPI(DecryptAPIHandler, `{"ciphertext": "`+encrypted.Ciphertext+`", "context": "customer-42"}`)
+	var decrypted struct{ Plaintext string }
+	if err := json.Unmarshal(w.Body.Bytes(), &decrypted); w.Code != http.StatusOK || err != nil || decrypted.Plaintext != "secret value" {
+		t.Fatalf("decrypt: status %d: %s", w.Code, w.Body)
+	}
+
+	w = postCryptoAPI(DecryptAPIHandler, `{"ciphertext": "`+encrypted.Ciphertext+`", "context": "customer-43"}`)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("decrypt under another context: status %d, want 400", w.Code)
+	}
+
+	// Ciphertexts of stored secrets are bound to their id, not an API context
+	stored, err := services.Encrypt("stored secret", services.GetEncryptionKey(), "customer-42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w = postCryptoAPI(DecryptAPIHandler, `{"ciphertext": "`+stored+`", "context": "customer-42"}`)
+	if w.Code != http.StatusBadRequest || strings.Contains(w.Body.String(), "stored secret") {
+		t.Errorf("decrypt of a stored secret: status %d, want 400", w.Code)
+	}
+}
+
+func TestCryptoAPIRejectsBadInput(t *testing.T) {
+	setupCryptoAPI(t)
+
+	tests := []struct {
+		name         string
+		handler      http.HandlerFunc
+		method, auth string
+		contentType  string
+		body         string
+		want         int
+	}{
+		{"wrong method", EncryptAPIHandler, http.MethodGet, "Bearer " + testCryptoAPIKey, "application/json", "", http.StatusMethodNotAllowed},
+		{"missing key", EncryptAPIHandler, http.MethodPost, "", "application/json", `{"plaintext": "x"}`, http.StatusUnauthorized},
+		{"wrong key", DecryptAPIHandler, http.MethodPost, "Bearer wrong", "application/json", `{"ciphertext": "x"}`, http.StatusUnauthorized},
+		{"form body", EncryptAPIHandler, http.MethodPost, "Bearer " + testCryptoAPIKey, "application/x-www-form-urlencoded", "plaintext=x", http.StatusUnsupportedMediaType},
+		{"malformed json", EncryptAPIHandler, http.MethodPost, "Bearer " + testCryptoAPIKey, "application/json", `{"plaintext": `, http.StatusBadRequest},
+		{"missing ciphertext", DecryptAPIHandler, http.MethodPost, "Bearer " + testCryptoAPIKey, "application/json", `{"context": "x"}`, http.StatusBadRequest},
+		{"not base64", DecryptAPIHandler, http.MethodPost, "Bearer " + testCryptoAPIKey, "application/json", `{"ciphertext": "***"}`, http.StatusBadRequest},
+		{"garbage ciphertext", DecryptAPIHandler, http.MethodPost, "Bearer " + testCryptoAPIKey, "application/json", `{"ciphertext": "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/api/crypto", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", tt.contentType)
+			if tt.auth != "" {
+				r.Header.Set("Authorization", tt.auth)
+			}
+			w := httptest.NewRecorder()
+			tt.handler(w, r)
+			if w.Code != tt.want {
+				t.Errorf("status %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
+
+func TestCryptoAPIEnforcesSizeLimit(t *testing.T) {
+	setupCryptoAPI(t)
+	t.Setenv("MAX_TEXT_SIZE", "1024")
+
+	if w := postCryptoAPI(EncryptAPIHandler, `{"plaintext": "`+strings.Repeat("a", 1024)+`"}`); w.Code != http.StatusOK {
+		t.Errorf("plaintext at the limit: status %d, want 200", w.Code)
+	}
+	if w := postCryptoAPI(EncryptAPIHandler, `{"plaintext": "`+strings.Repeat("a", 1025)+`"}`); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("plaintext over the limit: status %d, want 413", w.Code)
+	}
+	// Bodies are cut off while they are read, before the plaintext is checked
+	if w := postCryptoAPI(EncryptAPIHandler, `{"plaintext": "`+strings.Repeat("a", 64<<10)+`"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("oversized body: status %d, want 400", w.Code)
+	}
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+
+	"github.com/anazri/zeepass/internal/models"
+	"github.com/anazri/zeepass/internal/services"
+)
+
+// File downloads are served with http.ServeContent, so clients get a
+// Content-Length and can fetch byte ranges. A download claims a view up front,
+// so concurrent requests cannot both take the last one. The view sticks for
+// every full (200) or partial (206) response that delivered content without a
+// write or decryption error, so byte ranges cannot be used to read a file
+// without using up its views. Otherwise, e.g. when the client went away
+// mid-transfer or the response was 304 or 416, the view is released again and
+// the file stays available for a retry.
+
+// serveFileDownload sends the decrypted file, honouring Range, If-Range and
+// conditional headers. It reports whether the response delivered file content
+// without a write or decryption error, i.e. whether it counts as a view.
+func serveFileDownload(w http.ResponseWriter, r *http.Request, id string, data *models.EncryptedFileData) bool {
+	var content io.ReadSeeker
+	var size int64
+	if services.IsStreamCiphertext(data.Content) {
+		reader, err := services.NewStreamReader(data.Content, services.GetEncryptionKey(), id)
+		if err != nil {
+			log.Printf("Error opening file %s: %v", id, err)
+			http.Error(w, "Error decrypting file", http.StatusInternalServerError)
+			return false
+		}
+		defer reader.Wipe()
+		// ServeContent has nothing to read from an empty file, so its final
+		// chunk is authenticated here
+		if reader.Size() == 0 {
+			if _, err := reader.Read(nil); err != io.EOF {
+				log.Printf("Error decrypting file %s: %v", id, err)
+				http.Error(w, "Error decrypting file", http.StatusInternalServerError)
+				return false
+			}
+		}
+		content, size = reader, reader.Size()
+	} else {
+		decryptedData, err := services.DecryptFile(data.Content, services.GetEncryptionKey(), id)
+		if err != nil {
+			http.Error(w, "Error decrypting file", http.StatusInternalServerError)
+			return false
+		}
+		defer services.WipeBytes(decryptedData)
+		content, size = bytes.NewReader(decryptedData), int64(len(decryptedData))
+	}
+
+	// Without a stored type, ServeContent detects one from the name or content
+	if data.MimeType != "" {
+		w.Header().Set("Content-Type", data.MimeType)
+	}
+	// The file name comes from the uploader, so quote it properly instead of
+	// pasting it into the header
+	disposition := mime.FormatMediaType("attachment", map[string]string{"filename": data.FileName})
+	if disposition == "" {
+		disposition = "attachment"
+	}
+	w.Header().Set("Content-Disposition", disposition)
+
+	tracked := &trackedReader{ReadSeeker: content}
+	out := &trackedResponseWriter{ResponseWriter: w}
+	http.ServeContent(out, r, data.FileName, data.CreatedAt, tracked)
+	if tracked.err != nil {
+		log.Printf("Error decrypting file %s: %v", id, tracked.err)
+	}
+	if r.Method == http.MethodHead || tracked.err != nil || out.err != nil {
+		return false
+	}
+	// An empty file is delivered by a 200 without any body bytes
+	return out.status == http.StatusOK && (out.written > 0 || size == 0) ||
+		out.status == http.StatusPartialContent && out.written > 0
+}
+
+// trackedReader notes the first read error, e.g. a chunk that failed
+// authentication.
+type trackedReader struct {
+	io.ReadSeeker
+	err error
+}
+
+func (t *trackedReader) Read(p []byte) (int, error) {
+	n, err := t.ReadSeeker.Read(p)
+	if err != nil && err != io.EOF && t.err == nil {
+		t.err = err
+	}
+	return n, err
+}
+
+// trackedResponseWriter records the response status, the number of body
+// bytes written and the first write error.
+type trackedResponseWriter struct {
+	http.ResponseWriter
+	status  int
+	written int64
+	err     error
+}
+
+func (t *trackedResponseWriter) WriteHeader(status int) {
+	if t.status == 0 {
+		t.status = status
+	}
+	t.ResponseWriter.WriteHeader(status)
+}
+
+func (t *trackedResponseWriter) Write(p []byte) (int, error) {
+	if t.status == 0 {
+		t.status = http.StatusOK
+	}
+	n, err := t.ResponseWriter.Write(p)
+	t.written += int64(n)
+	if err != nil && t.err == nil {
+		t.err = err
+	}
+	return n, err
+}
@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/anazri/zeepass/internal/models"
+	"github.com/anazri/zeepass/internal/services"
+)
+
+// storeTestFile encrypts content and stores it as file id.
+func storeTestFile(t *testing.T, id string, content []byte, maxViews int) {
+	t.Helper()
+	var ciphertext bytes.Buffer
+	if err := services.EncryptStream(&ciphertext, bytes.NewReader(content), services.GetEncryptionKey(), id); err != nil {
+		t.Fatal(err)
+	}
+	expiresAt := time.Now().Add(time.Hour)
+	err := services.GetStorage().StoreFile(id, &models.EncryptedFileData{
+		ID:        id,
+		Content:   ciphertext.Bytes(),
+		FileName:  "report.txt",
+		FileSize:  int64(len(content)),
+		Lifetime:  "1h",
+		CreatedAt: time.Now(),
+		ExpiresAt: &expiresAt,
+		MaxViews:  maxViews,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func downloadFile(w http.ResponseWriter, id, byteRange string) {
+	r := httptest.NewRequest(http.MethodGet, "/view-file/"+id, nil)
+	r.Header.Set("User-Agent", "Mozilla/5.0")
+	if byteRange != "" {
+		r.Header.Set("Range", byteRange)
+	}
+	ViewEncryptedFileHandler(w, r)
+}
+
+func fileViewCount(t *testing.T, id string) (int, bool) {
+	t.Helper()
+	data, err := services.GetStorage().GetFile(id)
+	if err != nil || data == nil {
+		return 0, false
+	}
+	return data.ViewCount, true
+}
+
+var testFileContent = bytes.Repeat([]byte("0123456789abcdef"), 20000)
+
+func TestFullDownloadBurnsOneTimeFile(t *testing.T) {
+	storeTestFile(t, "dl-full", testFileContent, 1)
+
+	w := httptest.NewRecorder()
+	downloadFile(w, "dl-full", "")
+	if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), testFileContent) {
+		t.Fatalf("download: status %d, %d bytes", w.Code, w.Body.Len())
+	}
+	if _, exists := fileViewCount(t, "dl-full"); exists {
+		t.Fatal("one-time file still exists after a full download")
+	}
+}
+
+// A range that stops short of the end must use up the view too, or a
+// one-time file could be read over and over minus its last byte.
+func TestPartialRangeBurnsOneTimeFile(t *testing.T) {
+	storeTestFile(t, "dl-range", testFileContent, 1)
+	byteRange := fmt.Sprintf("bytes=0-%d", len(testFileContent)-2)
+
+	w := httptest.NewRecorder()
+	downloadFile(w, "dl-range", byteRange)
+	if w.Code != http.StatusPartialContent || w.Body.Len() != len(testFileContent)-1 {
+		t.Fatalf("first range: status %d, %d bytes", w.Code, w.Body.Len())
+	}
+	if _, exists := fileViewCount(t, "dl-range"); exists {
+		t.Fatal("one-time file still exists after a partial download")
+	}
+
+	w = httptest.NewRecorder()
+	downloadFile(w, "dl-range", byteRange)
+	if w.Code == http.StatusPartialContent || bytes.Contains(w.Body.Bytes(), testFileContent[:1024]) {
+		t.Fatalf("second range was served: status %d", w.Code)
+	}
+}
+
+func TestRangesCountViewsOnMultiViewFile(t *testing.T) {
+	storeTestFile(t, "dl-multi", testFileContent, 3)
+
+	for i := 1; i <= 2; i++ {
+		w := httptest.NewRecorder()
+		downloadFile(w, "dl-multi", "bytes=0-99")
+		if w.Code != http.StatusPartialContent {
+			t.Fatalf("range %d: status %d", i, w.Code)
+		}
+		if views, _ := fileViewCount(t, "dl-multi"); views != i {
+			t.Fatalf("after range %d: %d views counted", i, views)
+		}
+	}
+}
+
+// failingWriter accepts the headers and the first few KB of the body, then
+// fails like a connection the client closed.
+type failingWriter struct {
+	*httptest.ResponseRecorder
+	remaining int
+}
+
+func (f *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > f.remaining {
+		n, _ := f.ResponseRecorder.Write(p[:f.remaining])
+		f.remaining = 0
+		return n, errors.New("broken pipe")
+	}
+	f.remaining -= len(p)
+	return f.ResponseRecorder.Write(p)
+}
+
+func TestFailedTransferLeavesFileIntact(t *testing.T) {
+	storeTestFile(t, "dl-broken", testFileContent, 1)
+
+	downloadFile(&failingWriter{ResponseRecorder: httptest.NewRecorder(), remaining: 4096}, "dl-broken", "")
+	views, exists := fileViewCount(t, "dl-broken")
+	if !exists || views != 0 {
+		t.Fatalf("after a failed transfer: exists %v, %d views", exists, views)
+	}
+
+	w := httptest.NewRecorder()
+	downloadFile(w, "dl-broken", "")
+	if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), testFileContent) {
+		t.Fatalf("retry: status %d, %d bytes", w.Code, w.Body.Len())
+	}
+}
+
+func TestUnsatisfiableRangeKeepsView(t *testing.T) {
+	storeTestFile(t, "dl-416", testFileContent, 1)
+
+	w := httptest.NewRecorder()
+	downloadFile(w, "dl-416", fmt.Sprintf("bytes=%d-", len(testFileContent)+10))
+	if w.Code != http.StatusRequestedRangeNotSatisfiable {
+		t.Fatalf("status %d", w.Code)
+	}
+	if views, exists := fileViewCount(t, "dl-416"); !exists || views != 0 {
+		t.Fatalf("after a 416: exists %v, %d views", exists, views)
+	}
+}
+
+func TestContentDispositionQuotesFileName(t *testing.T) {
+	tests := map[string]string{
+		`report.txt`:                    `attachment; filename=report.txt`,
+		`a "quoted"; name.txt`:          `attachment; filename="a \"quoted\"; name.txt"`,
+		"evil.txt\"\r\nSet-Cookie: x=1": `attachment; filename*=utf-8''evil.txt%22%0D%0ASet-Cookie%3A%20x%3D1`,
+		"résumé.pdf":                    `attachment; filename*=utf-8''r%C3%A9sum%C3%A9.pdf`,
+	}
+	i := 0
+	for name, want := range tests {
+		id := fmt.Sprintf("dl-name-%d", i)
+		i++
+		storeTestFile(t, id, []byte("content"), 1)
+		data, err := services.GetStorage().GetFile(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data.FileName = name
+		if err := services.GetStorage().StoreFile(id, data); err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		downloadFile(w, id, "")
+		if got := w.Header().Get("Content-Disposition"); got != want {
+			t.Errorf("file name %q: Content-Disposition = %q, want %q", name, got, want)
+		}
+	}
+}
+
+// A longer file cut down to a single tag-sized chunk claims to be empty, so
+// ServeContent would not read it; it must still fail authentication.
+func TestTruncatedFileIsNotServedAsEmpty(t *testing.T) {
+	storeTestFile(t, "dl-truncated", bytes.Repeat([]byte("x"), 100<<10), 1)
+	data, err := services.GetStorage().GetFile("dl-truncated")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// An empty file is a stream header followed by one tag
+	var empty bytes.Buffer
+	if err := services.EncryptStream(&empty, bytes.NewReader(nil), services.GetEncryptionKey(), "dl-truncated"); err != nil {
+		t.Fatal(err)
+	}
+	data.Content = data.Content[:empty.Len()]
+	data.MimeType = "text/plain"
+	if err := services.GetStorage().StoreFile("dl-truncated", data); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	downloadFile(w, "dl-truncated", "")
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("truncated file: status %d, want 500", w.Code)
+	}
+
+	storeTestFile(t, "dl-empty", nil, 1)
+	w = httptest.NewRecorder()
+	downloadFile(w, "dl-empty", "")
+	if w.Code != http.StatusOK || w.Body.Len() != 0 {
+		t.Fatalf("empty file: status %d, %d bytes", w.Code, w.Body.Len())
+	}
+}
@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/anazri/zeepass/internal/services"
+)
+
+// textEncoding is implemented by base64.Encoding and base32.Encoding.
+type textEncoding interface {
+	EncodeToString(src []byte) string
+	DecodeString(s string) ([]byte, error)
+}
+
+// hexEncoding adapts the hex package to textEncoding. Decoding accepts both
+// upper and lower case digits.
+type hexEncoding struct{}
+
+func (hexEncoding) EncodeToString(src []byte) string      { return hex.EncodeToString(src) }
+func (hexEncoding) DecodeString(s string) ([]byte, error) { return hex.DecodeString(s) }
+
+// codec is an encoding offered by /encode and /decode with its variants.
+// detectOrder is the order variants are tried in when decoding without one;
+// the first entry is the default for encoding.
+type codec struct {
+	variants    map[string]textEncoding
+	detectOrder []string
+}
+
+// codecs maps the "encoding" form field to its codec. base64 has std and url
+// alphabets ("+/" or "-_"), padded or raw (unpadded) as used in JWTs; base32
+// can be raw as TOTP secrets often are.
+var codecs = map[string]codec{
+	"base64": {
+		variants: map[string]textEncoding{
+			"std":     base64.StdEncoding,
+			"url":     base64.URLEncoding,
+			"raw_std": base64.RawStdEncoding,
+			"raw_url": base64.RawURLEncoding,
+		},
+		detectOrder: []string{"std", "url", "raw_std", "raw_url"},
+	},
+	"base32": {
+		variants: map[string]textEncoding{
+			"std":     base32.StdEncoding,
+			"raw_std": base32.StdEncoding.WithPadding(base32.NoPadding),
+		},
+		detectOrder: []string{"std", "raw_std"},
+	},
+	"hex": {
+		variants:    map[string]textEncoding{"std": hexEncoding{}},
+		detectOrder: []string{"std"},
+	},
+}
+
+// lookupCodec returns the codec for an encoding name, base64 when empty.
+func lookupCodec(name string) (string, codec, error) {
+	if name == "" {
+		name = "base64"
+	}
+	c, ok := codecs[name]
+	if !ok {
+		return "", codec{}, fmt.Errorf("unknown encoding %q (use base64, base32 or hex)", name)
+	}
+	return name, c, nil
+}
+
+// variant returns the encoding for a variant name, the default when empty.
+func (c codec) variant(name string) (textEncoding, error) {
+	if name == "" {
+		name = c.detectOrder[0]
+	}
+	encoding, ok := c.variants[name]
+	if !ok {
+		names := make([]string, 0, len(c.variants))
+		for n := range c.variants {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown variant %q (use %s)", name, strings.Join(names, ", "))
+	}
+	return encoding, nil
+}
+
+// decode decodes data with the given variant, or with the first variant
+// that accepts it when variant is empty or "auto". It returns the variant
+// used.
+func (c codec) decode(data, variant, encodingName string) ([]byte, string, error) {
+	if variant != "" && variant != "auto" {
+		encoding, err := c.variant(variant)
+		if err != nil {
+			return nil, "", err
+		}
+		decoded, err := encoding.DecodeString(data)
+		if err != nil {
+			return nil, "", fmt.Errorf("data is not valid %s %s: %v", variant, encodingName, err)
+		}
+		return decoded, variant, nil
+	}
+
+	var lastErr error
+	for _, name := range c.detectOrder {
+		decoded, err := c.variants[name].DecodeString(data)
+		if err == nil {
+			return decoded, name, nil
+		}
+		lastErr = err
+	}
+	if len(c.detectOrder) == 1 {
+		return nil, "", fmt.Errorf("data is not valid %s: %v", encodingName, lastErr)
+	}
+	return nil, "", fmt.Errorf("data is not valid %s in any variant", encodingName)
+}
+
+// EncodeHandler encodes text or a file as base64, base32 or hex, chosen by
+// the "encoding" field (default base64) and its "variant".
+func EncodeHandler(w http.ResponseWriter, r *http.Request) {
+	handleEncode(w, r, "")
+}
+
+// DecodeHandler decodes base64, base32 or hex data, chosen by the "encoding"
+// field (default base64). Without a "variant" each one is tried in turn.
+func DecodeHandler(w http.ResponseWriter, r *http.Request) {
+	handleDecode(w, r, "")
+}
+
+// handleEncode serves an encode request. encoding fixes the encoding for
+// routes like /base64-encode; when empty it is read from the form.
+func handleEncode(w http.ResponseWriter, r *http.Request, encoding string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Parse the multipart form
+	err := r.ParseMultipartForm(10 << 20) // 10MB limit
+	if err != nil {
+		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		return
+	}
+
+	if encoding == "" {
+		encoding = r.FormValue("encoding")
+	}
+	encoding, c, err := lookupCodec(encoding)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	encoder, err := c.variant(r.FormValue("variant"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	dataType := r.FormValue("type")
+	var result string
+
+	if dataType == "file" {
+		// Handle file encoding
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, "Error reading file", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+
+		// Read file content
+		fileData, err := io.ReadAll(file)
+		if err != nil {
+			http.Error(w, "Error reading file content", http.StatusInternalServerError)
+			return
+		}
+
+		result = encoder.EncodeToString(fileData)
+	} else {
+		// Handle text encoding
+		text := r.FormValue("text")
+		if text == "" {
+			http.Error(w, "No text provided", http.StatusBadRequest)
+			return
+		}
+
+		result = encoder.EncodeToString([]byte(text))
+	}
+
+	// Return JSON response
+	response := map[string]interface{}{
+		"success":  true,
+		"result":   result,
+		"encoding": encoding,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleDecode serves a decode request. encoding fixes the encoding for
+// routes like /base64-decode; when empty it is read from the form.
+func handleDecode(w http.ResponseWriter, r *http.Request, encoding string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Parse the form
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		return
+	}
+
+	if encoding == "" {
+		encoding = r.FormValue("encoding")
+	}
+	encoding, c, err := lookupCodec(encoding)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	data := r.FormValue("data")
+	if data == "" {
+		http.Error(w, "No "+encoding+" data provided", http.StatusBadRequest)
+		return
+	}
+
+	// Decode in the requested variant, or detect it
+	decoded, variant, err := c.decode(data, r.FormValue("variant"), encoding)
+	if err != nil {
+		http.Error(w, "Invalid "+encoding+" data: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	dataType := r.FormValue("type")
+
+	if dataType == "file" {
+		// Return as file download
+		filename := r.FormValue("filename")
+		if filename == "" {
+			filename = "decoded_file"
+		}
+
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", "attachment; filename=\""+filename+"\"")
+		w.Header().Set("Content-Length", strconv.Itoa(len(decoded)))
+		w.Write(decoded)
+	} else {
+		// Detect the charset (or use the requested one) and transcode to UTF-8
+		text, charset, err := services.DecodeText(decoded, r.FormValue("charset"))
+		if err != nil {
+			http.Error(w, "Error decoding text: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// Return as text in JSON response
+		response := map[string]interface{}{
+			"success":  true,
+			"result":   text,
+			"charset":  charset,
+			"binary":   charset == services.CharsetBinary,
+			"size":     len(decoded),
+			"encoding": encoding,
+			"variant":  variant,
+		}
+		if charset == services.CharsetBinary {
+			response["message"] = "Decoded content is binary and cannot be displayed as text. Decode it as a file to download it instead."
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+}
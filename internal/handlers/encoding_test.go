@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/anazri/zeepass/internal/services"
+)
+
+// encodeTestFile uploads content to EncodeHandler and returns the base64
+// result.
+func encodeTestFile(t *testing.T, content []byte) string {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("type", "file")
+	part, _ := form.CreateFormFile("file", "input.txt")
+	part.Write(content)
+	form.Close()
+
+	r := httptest.NewRequest(http.MethodPost, "/encode", &body)
+	r.Header.Set("Content-Type", form.FormDataContentType())
+	w := httptest.NewRecorder()
+	EncodeHandler(w, r)
+	var resp struct{ Result string }
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); w.Code != http.StatusOK || err != nil {
+		t.Fatalf("encode: status %d: %s", w.Code, w.Body)
+	}
+	return resp.Result
+}
+
+// decodeTestData posts form to DecodeHandler.
+func decodeTestData(form url.Values) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/decode", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	DecodeHandler(w, r)
+	return w
+}
+
+func utf16Bytes(s string, bigEndian bool) []byte {
+	var out []byte
+	if bigEndian {
+		out = []byte{0xFE, 0xFF}
+	} else {
+		out = []byte{0xFF, 0xFE}
+	}
+	for _, unit := range utf16.Encode([]rune(s)) {
+		if bigEndian {
+			out = append(out, byte(unit>>8), byte(unit))
+		} else {
+			out = append(out, byte(unit), byte(unit>>8))
+		}
+	}
+	return out
+}
+
+func TestEncodingRoundTripsText(t *testing.T) {
+	const text = "Grüße, 世界 🔐"
+	tests := []struct {
+		name        string
+		content     []byte
+		want        string
+		wantCharset string
+	}{
+		{"utf-8", []byte(text), text, services.CharsetUTF8},
+		{"utf-8 with bom", append([]byte{0xEF, 0xBB, 0xBF}, text...), text, services.CharsetUTF8},
+		{"utf-16le with bom", utf16Bytes(text, false), text, services.CharsetUTF16LE},
+		{"utf-16be with bom", utf16Bytes(text, true), text, services.CharsetUTF16BE},
+		{"latin-1", []byte{'c', 'a', 'f', 0xE9, ' ', 0xA3, '5'}, "café £5", services.CharsetLatin1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded := encodeTestFile(t, tt.content)
+			w := decodeTestData(url.Values{"data": {encoded}})
+			var resp struct {
+				Result  string
+				Charset string
+				Binary  bool
+				Size    int
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); w.Code != http.StatusOK || err != nil {
+				t.Fatalf("decode: status %d: %s", w.Code, w.Body)
+			}
+			if resp.Result != tt.want || resp.Charset != tt.wantCharset || resp.Binary {
+				t.Errorf("decoded %q as %s (binary %v), want %q as %s", resp.Result, resp.Charset, resp.Binary, tt.want, tt.wantCharset)
+			}
+			if resp.Size != len(tt.content) {
+				t.Errorf("size = %d, want %d", resp.Size, len(tt.content))
+			}
+
+			// Downloading as a file returns the original bytes, BOM included
+			w = decodeTestData(url.Values{"data": {encoded}, "type": {"file"}})
+			if !bytes.Equal(w.Body.Bytes(), tt.content) {
+				t.Errorf("file download = %x, want %x", w.Body.Bytes(), tt.content)
+			}
+		})
+	}
+}
+
+func TestEncodingRoundTripsBinary(t *testing.T) {
+	content := []byte{0x89, 'P', 'N', 'G', 0x00, 0xFF, 0xFE, 0x01, 0x80}
+	encoded := encodeTestFile(t, content)
+
+	w := decodeTestData(url.Values{"data": {encoded}})
+	var resp struct {
+		Result  string
+		Charset string
+		Binary  bool
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); w.Code != http.StatusOK || err != nil {
+		t.Fatalf("decode: status %d: %s", w.Code, w.Body)
+	}
+	if !resp.Binary || resp.Charset != services.CharsetBinary || resp.Result != "" {
+		t.Errorf("binary data decoded as %q (%s)", resp.Result, resp.Charset)
+	}
+
+	w = decodeTestData(url.Values{"data": {encoded}, "type": {"file"}})
+	if !bytes.Equal(w.Body.Bytes(), content) {
+		t.Errorf("file download = %x, want %x", w.Body.Bytes(), content)
+	}
+
+	// Forcing UTF-8 on bytes that are not UTF-8 is an error, not mojibake
+	if w := decodeTestData(url.Values{"data": {encoded}, "charset": {"utf-8"}}); w.Code != http.StatusBadRequest {
+		t.Errorf("forced utf-8: status %d, want 400", w.Code)
+	}
+}
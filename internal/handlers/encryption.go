@@ -1,10 +1,16 @@
 package handlers
 
 import (
+	"bytes"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"html"
 	"io"
 	"log"
 	"net/http"
+	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -12,13 +18,163 @@ import (
 	"github.com/anazri/zeepass/internal/services"
 )
 
+// defaultMaxTextSize is the default cap, in bytes, on the stored size of a
+// text secret.
+const defaultMaxTextSize = 64 * 1024
+
+// maxTextSize returns the cap on the stored size of a text secret, configured
+// with MAX_TEXT_SIZE in bytes. With COMPRESS_TEXT=true the cap applies to the
+// compressed text, so larger inputs are accepted if they compress well.
+func maxTextSize() int {
+	if size, err := strconv.Atoi(os.Getenv("MAX_TEXT_SIZE")); err == nil && size > 0 {
+		return size
+	}
+	return defaultMaxTextSize
+}
+
+// maxTextFormBodySize bounds the body of a text form without an attachment.
+// Url-encoding can triple the text, and with compression the text may be
+// larger than the stored cap, so the bound is generous; it only has to stop
+// bodies that could never be stored.
+func maxTextFormBodySize() int64 {
+	size := int64(maxTextSize()) * 3
+	if textCompressionEnabled() {
+		size *= 8
+	}
+	return size + 64<<10
+}
+
+func textCompressionEnabled() bool {
+	return os.Getenv("COMPRESS_TEXT") == "true"
+}
+
+// confirmationLifetimes returns the lifetimes that must be confirmed explicitly
+// with a confirm_risk form field. It is configured with CONFIRM_LIFETIMES as a
+// comma-separated list and defaults to "never". Set it to "none" to disable.
+func confirmationLifetimes() []string {
+	value := os.Getenv("CONFIRM_LIFETIMES")
+	if value == "" {
+		return []string{"never"}
+	}
+	if value == "none" {
+		return []string{}
+	}
+
+	lifetimes := []string{}
+	for _, lifetime := range strings.Split(value, ",") {
+		if lifetime = strings.TrimSpace(lifetime); lifetime != "" {
+			lifetimes = append(lifetimes, lifetime)
+		}
+	}
+	return lifetimes
+}
+
+// checkRiskConfirmation returns an error message when lifetime requires
+// confirmation and the request did not include it, or "" otherwise. A custom
+// ttl needs confirmation when it is at least as long as a preset that does.
+func checkRiskConfirmation(r *http.Request, lifetime string) string {
+	return lifetimeRiskMessage(lifetime, r.FormValue("ttl"), r.FormValue("confirm_risk") != "")
+}
+
+// lifetimeRiskMessage is checkRiskConfirmation for a lifetime preset and an
+// optional custom ttl given as values.
+func lifetimeRiskMessage(lifetime, ttlValue string, confirmed bool) string {
+	if value := strings.TrimSpace(ttlValue); value != "" {
+		ttl, err := services.ParseTTL(value)
+		if err != nil || confirmed {
+			return "" // An invalid ttl is reported when the lifetime is resolved
+		}
+		for _, l := range confirmationLifetimes() {
+			if d := services.LifetimeDuration(l); d > 0 && ttl >= d {
+				return fmt.Sprintf("Links that last %s stay readable to anyone who obtains them for a long time. Please confirm that you understand this risk before creating it.", services.FormatLifetime(ttl))
+			}
+		}
+		return ""
+	}
+	return riskConfirmationMessage(lifetime, confirmed)
+}
+
+// resolveRequestLifetime resolves the custom ttl when one is given and the
+// lifetime preset otherwise. A ttl keeps the preset's view limit. It returns
+// the lifetime value to store, "custom" for a ttl.
+func resolveRequestLifetime(lifetime, ttlValue string) (string, services.ResolvedLifetime, error) {
+	value := strings.TrimSpace(ttlValue)
+	if value == "" {
+		resolved, err := services.ResolveLifetime(lifetime)
+		return lifetime, resolved, err
+	}
+	ttl, err := services.ParseTTL(value)
+	if err != nil {
+		return lifetime, services.ResolvedLifetime{}, err
+	}
+	resolved, err := services.ResolveCustomLifetime(lifetime, ttl)
+	return "custom", resolved, err
+}
+
+func riskConfirmationMessage(lifetime string, confirmed bool) string {
+	if confirmed {
+		return ""
+	}
+	for _, l := range confirmationLifetimes() {
+		if l == lifetime {
+			return fmt.Sprintf("Links set to \"%s\" stay readable to anyone who obtains them for a long time. Please confirm that you understand this risk before creating it.", getLifetimeDisplay(lifetime))
+		}
+	}
+	return ""
+}
+
+// buildShareURL returns the absolute link for path (e.g. "/view/<id>"). The
+// base is BASE_URL when set, e.g. "https://zeepass.example.com". Otherwise
+// it is derived from the request, honouring X-Forwarded-Proto from a TLS
+// terminating proxy. Without a request it falls back to localhost.
+func buildShareURL(r *http.Request, path string) string {
+	if base := strings.TrimSuffix(strings.TrimSpace(os.Getenv("BASE_URL")), "/"); base != "" {
+		return base + path
+	}
+	if r == nil || r.Host == "" {
+		return "http://localhost:8080" + path
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		// A chain of proxies may list several; the first is the client's
+		proto = strings.ToLower(strings.TrimSpace(strings.Split(proto, ",")[0]))
+		if proto == "http" || proto == "https" {
+			scheme = proto
+		}
+	}
+	return scheme + "://" + r.Host + path
+}
+
 func EncryptTextHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
+	if wantsJSON(r) {
+		encryptTextJSON(w, r)
+		return
+	}
 
-	err := r.ParseForm()
+	// Notes with an attachment are posted as multipart forms. Either way the
+	// body is bounded while it is read.
+	var err error
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		r.Body = http.MaxBytesReader(w, r.Body, maxTextFormBodySize()+maxAttachmentSize+1<<20)
+		err = r.ParseMultipartForm(maxAttachmentSize + 1<<20)
+	} else {
+		r.Body = http.MaxBytesReader(w, r.Body, maxTextFormBodySize())
+		err = r.ParseForm()
+	}
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		w.Write([]byte(`<div class="bg-red-100 border border-red-400 text-red-700 px-4 py-3 rounded mb-4">The message is too large</div>`))
+		return
+	}
 	if err != nil {
 		responseHTML := fmt.Sprintf(`<div class="bg-red-100 border border-red-400 text-red-700 px-4 py-3 rounded mb-4">Error parsing form data</div>`)
 		w.Write([]byte(responseHTML))
@@ -28,6 +184,10 @@ func EncryptTextHandler(w http.ResponseWriter, r *http.Request) {
 	text := strings.TrimSpace(r.FormValue("text"))
 	pin := r.FormValue("pin")
 	lifetime := r.FormValue("lifetime")
+	passphrase := r.FormValue("passphrase")
+	hintQuestion := strings.TrimSpace(r.FormValue("hint_question"))
+	hintAnswer := services.NormalizeHintAnswer(r.FormValue("hint_answer"))
+	pinEachView := r.FormValue("pin_each_view") != ""
 
 	if text == "" {
 		responseHTML := fmt.Sprintf(`<div class="bg-red-100 border border-red-400 text-red-700 px-4 py-3 rounded mb-4">Please enter some text to encrypt</div>`)
@@ -35,43 +195,100 @@ func EncryptTextHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if pinEachView && pin == "" {
+		responseHTML := `<div class="bg-red-100 border border-red-400 text-red-700 px-4 py-3 rounded mb-4">Set a PIN to ask for it on every view</div>`
+		w.Write([]byte(responseHTML))
+		return
+	}
+
+	if message := validateHint(hintQuestion, hintAnswer); message != "" {
+		responseHTML := fmt.Sprintf(`<div class="bg-red-100 border border-red-400 text-red-700 px-4 py-3 rounded mb-4">%s</div>`, message)
+		w.Write([]byte(responseHTML))
+		return
+	}
+
+	if message := checkRiskConfirmation(r, lifetime); message != "" {
+		responseHTML := fmt.Sprintf(`<div class="bg-red-100 border border-red-400 text-red-700 px-4 py-3 rounded mb-4">%s</div>`, message)
+		w.Write([]byte(responseHTML))
+		return
+	}
+
+	lifetime, resolved, err := resolveRequestLifetime(lifetime, r.FormValue("ttl"))
+	if err != nil {
+		responseHTML := fmt.Sprintf(`<div class="bg-red-100 border border-red-400 text-red-700 px-4 py-3 rounded mb-4">Lifetime not allowed: %s</div>`, html.EscapeString(err.Error()))
+		w.Write([]byte(responseHTML))
+		return
+	}
+	if message := applyMaxViews(r, &resolved); message != "" {
+		responseHTML := fmt.Sprintf(`<div class="bg-red-100 border border-red-400 text-red-700 px-4 py-3 rounded mb-4">%s</div>`, message)
+		w.Write([]byte(responseHTML))
+		return
+	}
+
+	// Enforce the size cap on what will actually be stored
+	plaintext := text
+	compressed := false
+	if textCompressionEnabled() {
+		raw := []byte(text)
+		deflated, err := services.CompressText(raw)
+		services.WipeBytes(raw)
+		if err != nil {
+			responseHTML := fmt.Sprintf(`<div class="bg-red-100 border border-red-400 text-red-700 px-4 py-3 rounded mb-4">Error compressing text: %v</div>`, err)
+			w.Write([]byte(responseHTML))
+			return
+		}
+		if len(deflated) < len(text) {
+			plaintext = string(deflated)
+			compressed = true
+		}
+		services.WipeBytes(deflated)
+	}
+	if len(plaintext) > maxTextSize() {
+		responseHTML := fmt.Sprintf(`<div class="bg-red-100 border border-red-400 text-red-700 px-4 py-3 rounded mb-4">Text is too large: %d bytes (%d bytes after compression), the limit is %d bytes</div>`, len(text), len(plaintext), maxTextSize())
+		w.Write([]byte(responseHTML))
+		return
+	}
+
 	id := services.GenerateID()
 
-	encryptedText, err := services.Encrypt(text, services.GetEncryptionKey())
+	// With a passphrase the content is encrypted under a key derived from it,
+	// so the server alone can no longer decrypt the secret
+	key := services.GetEncryptionKey()
+	passphraseSalt := ""
+	if passphrase != "" {
+		salt, err := services.NewPassphraseSalt()
+		if err == nil {
+			key, err = services.DeriveKeyFromPassphrase(passphrase, salt)
+		}
+		if err != nil {
+			responseHTML := fmt.Sprintf(`<div class="bg-red-100 border border-red-400 text-red-700 px-4 py-3 rounded mb-4">Error deriving key from passphrase: %v</div>`, err)
+			w.Write([]byte(responseHTML))
+			return
+		}
+		passphraseSalt = base64.StdEncoding.EncodeToString(salt)
+	}
+
+	encryptedText, err := services.Encrypt(plaintext, key, id)
 	if err != nil {
 		responseHTML := fmt.Sprintf(`<div class="bg-red-100 border border-red-400 text-red-700 px-4 py-3 rounded mb-4">Error encrypting text: %v</div>`, err)
 		w.Write([]byte(responseHTML))
 		return
 	}
 
+	attachment, err := encryptAttachment(r, key, id)
+	if err != nil {
+		responseHTML := fmt.Sprintf(`<div class="bg-red-100 border border-red-400 text-red-700 px-4 py-3 rounded mb-4">Could not attach file: %v</div>`, err)
+		w.Write([]byte(responseHTML))
+		return
+	}
+
 	hashedPIN := ""
 	if pin != "" {
 		hashedPIN = services.HashPIN(pin)
 	}
-
-	var expiresAt *time.Time
-	maxViews := 1
-	switch lifetime {
-	case "1h":
-		expiry := time.Now().Add(time.Hour)
-		expiresAt = &expiry
-		maxViews = 999999
-	case "24h":
-		expiry := time.Now().Add(24 * time.Hour)
-		expiresAt = &expiry
-		maxViews = 999999
-	case "7d":
-		expiry := time.Now().Add(7 * 24 * time.Hour)
-		expiresAt = &expiry
-		maxViews = 999999
-	case "30d":
-		expiry := time.Now().Add(30 * 24 * time.Hour)
-		expiresAt = &expiry
-		maxViews = 999999
-	case "never":
-		maxViews = 999999
-	default:
-		maxViews = 1
+	hintAnswerHash := ""
+	if hintQuestion != "" {
+		hintAnswerHash = services.HashHintAnswer(hintAnswer)
 	}
 
 	encData := &models.EncryptedData{
@@ -80,12 +297,19 @@ func EncryptTextHandler(w http.ResponseWriter, r *http.Request) {
 		PIN:       hashedPIN,
 		Lifetime:  lifetime,
 		CreatedAt: time.Now(),
-		ExpiresAt: expiresAt,
+		ExpiresAt: resolved.ExpiresAt,
 		ViewCount: 0,
-		MaxViews:  maxViews,
+		MaxViews:  resolved.MaxViews,
+		PassphraseSalt: passphraseSalt,
+		Compressed:     compressed,
+		OriginalSize:   len(text),
+		HintQuestion:   hintQuestion,
+		HintAnswerHash: hintAnswerHash,
+		PINEachView:    pinEachView,
+		Attachment:     attachment,
 	}
 
-	err = services.StoreEncryptedData(id, encData)
+	err = services.GetStorage().StoreMessage(id, encData)
 	if err != nil {
 		log.Printf("Error storing encrypted data for ID %s: %v", id, err)
 		responseHTML := fmt.Sprintf(`<div class="bg-red-100 border border-red-400 text-red-700 px-4 py-3 rounded mb-4">Error storing encrypted data: %v</div>`, err)
@@ -94,7 +318,8 @@ func EncryptTextHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	log.Printf("Successfully stored encrypted data for ID: %s", id)
 
-	viewURL := fmt.Sprintf("http://localhost:8080/view/%s", id)
+	shareURL := buildShareURL(r, "/view/"+id)
+	viewURL := html.EscapeString(shareURL)
 
 	responseHTML := fmt.Sprintf(`
 		<div class="bg-green-100 border border-green-400 text-green-700 px-4 py-3 rounded mb-4">
@@ -114,9 +339,12 @@ func EncryptTextHandler(w http.ResponseWriter, r *http.Request) {
 					</button>
 				</div>
 			</div>
+			%s
 			<div class="text-sm text-gray-600">
 				<p><strong>Lifetime:</strong> %s</p>
 				%s
+				%s
+				%s
 				<p class="mt-2 text-amber-600">⚠️ This link will expire according to the lifetime settings. Save it securely.</p>
 			</div>
 		</div>
@@ -128,26 +356,103 @@ func EncryptTextHandler(w http.ResponseWriter, r *http.Request) {
 				alert('Link copied to clipboard!');
 			}
 		</script>
-	`, viewURL, getLifetimeDisplay(lifetime), getPINDisplay(pin))
+	`, viewURL, getQRCodeDisplay(shareURL), getResolvedLifetimeDisplay(lifetime, resolved), getViewsDisplay(resolved.MaxViews)+getPINDisplay(pin)+getHintDisplay(hintQuestion), getPassphraseDisplay(passphrase), getSizeDisplay(len(text), len(plaintext), compressed)+getAttachmentDisplay(attachment))
 
 	w.Write([]byte(responseHTML))
 }
 
+// maxViewsLimit is the largest view count a user may choose for a link.
+const maxViewsLimit = 100
+
+// applyMaxViews sets the view limit from the optional max_views form field,
+// independently of the lifetime. It returns an error message when the value
+// is not a number from 1 to maxViewsLimit.
+func applyMaxViews(r *http.Request, resolved *services.ResolvedLifetime) string {
+	value := strings.TrimSpace(r.FormValue("max_views"))
+	if value == "" {
+		return ""
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return maxViewsMessage()
+	}
+	return setMaxViews(resolved, n)
+}
+
+// setMaxViews sets the view limit to n, or returns an error message when n
+// is outside 1 to maxViewsLimit.
+func setMaxViews(resolved *services.ResolvedLifetime, n int) string {
+	if n < 1 || n > maxViewsLimit {
+		return maxViewsMessage()
+	}
+	resolved.MaxViews = n
+	return ""
+}
+
+func maxViewsMessage() string {
+	return fmt.Sprintf("Number of views must be between 1 and %d", maxViewsLimit)
+}
+
+// storeTextSecret encrypts text under the server key and stores it as a
+// message, returning its id. It is the minimal flow used by tools that share
+// generated values; EncryptTextHandler adds compression, passphrases and
+// attachments on top.
+func storeTextSecret(text, pin, lifetime string, resolved services.ResolvedLifetime) (string, error) {
+	id := services.GenerateID()
+
+	encryptedText, err := services.Encrypt(text, services.GetEncryptionKey(), id)
+	if err != nil {
+		return "", fmt.Errorf("error encrypting text: %v", err)
+	}
+
+	hashedPIN := ""
+	if pin != "" {
+		hashedPIN = services.HashPIN(pin)
+	}
+
+	encData := &models.EncryptedData{
+		ID:           id,
+		Content:      encryptedText,
+		PIN:          hashedPIN,
+		Lifetime:     lifetime,
+		CreatedAt:    time.Now(),
+		ExpiresAt:    resolved.ExpiresAt,
+		MaxViews:     resolved.MaxViews,
+		OriginalSize: len(text),
+	}
+	if err := services.GetStorage().StoreMessage(id, encData); err != nil {
+		return "", fmt.Errorf("error storing encrypted data: %v", err)
+	}
+	return id, nil
+}
+
 func getLifetimeDisplay(lifetime string) string {
-	switch lifetime {
-	case "1h":
-		return "1 Hour"
-	case "24h":
-		return "24 Hours"
-	case "7d":
-		return "7 Days"
-	case "30d":
-		return "30 Days"
-	case "never":
-		return "Never expires"
-	default:
-		return "Once received"
+	return services.LifetimeLabel(lifetime)
+}
+
+// getResolvedLifetimeDisplay notes when the requested lifetime was shortened
+// to the configured maximum.
+func getResolvedLifetimeDisplay(lifetime string, resolved services.ResolvedLifetime) string {
+	label := resolved.Label
+	if label == "" {
+		label = getLifetimeDisplay(lifetime)
+	}
+	if resolved.Clamped {
+		return fmt.Sprintf("%s (shortened to the maximum of %s)", label, services.FormatLifetime(services.MaxLifetime()))
 	}
+	return label
+}
+
+// getViewsDisplay shows how often a link may be opened, unless it is only
+// limited by time.
+func getViewsDisplay(maxViews int) string {
+	if maxViews >= services.UnlimitedViews {
+		return ""
+	}
+	if maxViews == 1 {
+		return `<p><strong>Views allowed:</strong> 1 (burned after reading)</p>`
+	}
+	return fmt.Sprintf(`<p><strong>Views allowed:</strong> %d</p>`, maxViews)
 }
 
 func getPINDisplay(pin string) string {
@@ -157,14 +462,148 @@ func getPINDisplay(pin string) string {
 	return "<p><strong>PIN Protection:</strong> Not set</p>"
 }
 
+// maxHintQuestionLength bounds the security question shown on the view page.
+const maxHintQuestionLength = 200
+
+// validateHint checks that a security question and its answer are set
+// together; the answer is expected to be normalized already.
+func validateHint(question, answer string) string {
+	switch {
+	case question == "" && answer == "":
+		return ""
+	case question == "":
+		return "Please enter a security question for the answer"
+	case answer == "":
+		return "Please enter the answer to the security question"
+	case len(question) > maxHintQuestionLength:
+		return fmt.Sprintf("Security question is too long (max %d characters)", maxHintQuestionLength)
+	}
+	return ""
+}
+
+func getHintDisplay(question string) string {
+	if question != "" {
+		return "<p><strong>Security Question:</strong> " + html.EscapeString(question) + "</p>"
+	}
+	return ""
+}
+
+func getPassphraseDisplay(passphrase string) string {
+	if passphrase != "" {
+		return "<p><strong>Passphrase:</strong> Required (share it separately, it cannot be recovered)</p>"
+	}
+	return ""
+}
+
+func getSizeDisplay(originalSize, storedSize int, compressed bool) string {
+	if compressed {
+		return fmt.Sprintf("<p><strong>Size:</strong> %d bytes (stored as %d bytes compressed)</p>", originalSize, storedSize)
+	}
+	return fmt.Sprintf("<p><strong>Size:</strong> %d bytes</p>", originalSize)
+}
+
+func getAttachmentDisplay(attachment *models.EncryptedAttachment) string {
+	if attachment == nil {
+		return ""
+	}
+	return fmt.Sprintf("<p><strong>Attachment:</strong> %s (%s)</p>", html.EscapeString(attachment.FileName), formatFileSize(attachment.FileSize))
+}
+
+// maxAttachmentSize caps files attached to a text note. Attachments are
+// delivered inline with the note, so they are kept smaller than standalone
+// encrypted files.
+const maxAttachmentSize = 5 << 20
+
+// encryptAttachment encrypts the optional "attachment" file of a note with
+// the note's key. It is bound to the note's ID so it cannot be moved to
+// another note. It returns nil when no file was attached.
+func encryptAttachment(r *http.Request, key []byte, id string) (*models.EncryptedAttachment, error) {
+	if r.MultipartForm == nil {
+		return nil, nil
+	}
+	file, fileHeader, err := r.FormFile("attachment")
+	if err == http.ErrMissingFile {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading attachment: %v", err)
+	}
+	defer file.Close()
+
+	if fileHeader.Size > maxAttachmentSize {
+		return nil, fmt.Errorf("attachment size must be less than %s", formatFileSize(maxAttachmentSize))
+	}
+
+	data, err := io.ReadAll(io.LimitReader(file, maxAttachmentSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("error reading attachment: %v", err)
+	}
+	if len(data) > maxAttachmentSize {
+		return nil, fmt.Errorf("attachment size must be less than %s", formatFileSize(maxAttachmentSize))
+	}
+
+	if scanner := services.UploadScanner(); scanner != nil {
+		if message := scanFile(scanner, bytes.NewReader(data), fileHeader.Filename); message != "" {
+			services.WipeBytes(data)
+			return nil, fmt.Errorf("%s", message)
+		}
+	}
+
+	content, err := services.EncryptFile(data, key, attachmentAAD(id))
+	services.WipeBytes(data)
+	if err != nil {
+		return nil, fmt.Errorf("error encrypting attachment: %v", err)
+	}
+
+	return &models.EncryptedAttachment{
+		Content:  content,
+		FileName: fileHeader.Filename,
+		FileSize: int64(len(data)),
+		MimeType: fileHeader.Header.Get("Content-Type"),
+	}, nil
+}
+
+// scanFile runs an uploaded or decrypted file through scanner and returns a
+// message for the user when it must be blocked. Files that cannot be scanned
+// are blocked too, so an unreachable scanner does not let malware through.
+func scanFile(scanner services.FileScanner, content io.Reader, fileName string) string {
+	signature, err := scanner.Scan(content)
+	if err != nil {
+		log.Printf("Error scanning file %q: %v", fileName, err)
+		return "The file could not be checked for malware, please try again later"
+	}
+	if signature != "" {
+		log.Printf("Blocked file %q: malware detected (%s)", fileName, signature)
+		return fmt.Sprintf("The file was blocked because malware was detected (%s)", html.EscapeString(signature))
+	}
+	return ""
+}
+
+// attachmentAAD is the associated data binding an attachment to its note.
+func attachmentAAD(id string) string {
+	return id + "/attachment"
+}
+
+// maxUploadFileSize is the largest file EncryptFileHandler accepts. Storage
+// backends keep each file as a single record, so the whole ciphertext (the
+// file plus a 16-byte tag per 64 KiB chunk) is buffered in memory before it
+// is stored; this limit is what bounds that buffer per request.
+const maxUploadFileSize = 10 << 20
+
+// maxUploadBodySize leaves room for the multipart framing and the other form
+// fields around the file.
+const maxUploadBodySize = maxUploadFileSize + 1<<20
+
 func EncryptFileHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// Parse multipart form with 10MB limit
-	err := r.ParseMultipartForm(10 << 20)
+	// Refuse oversized bodies while reading them instead of spooling them to
+	// disk first, then parse with up to maxUploadFileSize held in memory
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadBodySize)
+	err := r.ParseMultipartForm(maxUploadFileSize)
 	if err != nil {
 		responseHTML := fmt.Sprintf(`<div class="bg-red-100 border border-red-400 text-red-700 px-4 py-3 rounded mb-4">Error parsing form data</div>`)
 		w.Write([]byte(responseHTML))
@@ -175,6 +614,24 @@ func EncryptFileHandler(w http.ResponseWriter, r *http.Request) {
 	pin := r.FormValue("pin")
 	lifetime := r.FormValue("lifetime")
 
+	if message := checkRiskConfirmation(r, lifetime); message != "" {
+		responseHTML := fmt.Sprintf(`<div class="bg-red-100 border border-red-400 text-red-700 px-4 py-3 rounded mb-4">%s</div>`, message)
+		w.Write([]byte(responseHTML))
+		return
+	}
+
+	lifetime, resolved, err := resolveRequestLifetime(lifetime, r.FormValue("ttl"))
+	if err != nil {
+		responseHTML := fmt.Sprintf(`<div class="bg-red-100 border border-red-400 text-red-700 px-4 py-3 rounded mb-4">Lifetime not allowed: %s</div>`, html.EscapeString(err.Error()))
+		w.Write([]byte(responseHTML))
+		return
+	}
+	if message := applyMaxViews(r, &resolved); message != "" {
+		responseHTML := fmt.Sprintf(`<div class="bg-red-100 border border-red-400 text-red-700 px-4 py-3 rounded mb-4">%s</div>`, message)
+		w.Write([]byte(responseHTML))
+		return
+	}
+
 	// Get uploaded file
 	file, fileHeader, err := r.FormFile("file")
 	if err != nil {
@@ -185,30 +642,40 @@ func EncryptFileHandler(w http.ResponseWriter, r *http.Request) {
 	defer file.Close()
 
 	// Check file size (10MB limit)
-	if fileHeader.Size > 10<<20 {
+	if fileHeader.Size > maxUploadFileSize {
 		responseHTML := fmt.Sprintf(`<div class="bg-red-100 border border-red-400 text-red-700 px-4 py-3 rounded mb-4">File size must be less than 10MB</div>`)
 		w.Write([]byte(responseHTML))
 		return
 	}
 
-	// Read file content
-	fileData, err := io.ReadAll(file)
-	if err != nil {
-		responseHTML := fmt.Sprintf(`<div class="bg-red-100 border border-red-400 text-red-700 px-4 py-3 rounded mb-4">Error reading file: %v</div>`, err)
-		w.Write([]byte(responseHTML))
-		return
+	// Scan the upload before it is stored, then rewind it for encryption
+	if scanner := services.UploadScanner(); scanner != nil {
+		if message := scanFile(scanner, file, fileHeader.Filename); message != "" {
+			responseHTML := fmt.Sprintf(`<div class="bg-red-100 border border-red-400 text-red-700 px-4 py-3 rounded mb-4">%s</div>`, message)
+			w.Write([]byte(responseHTML))
+			return
+		}
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			responseHTML := fmt.Sprintf(`<div class="bg-red-100 border border-red-400 text-red-700 px-4 py-3 rounded mb-4">Error reading file: %v</div>`, err)
+			w.Write([]byte(responseHTML))
+			return
+		}
 	}
 
 	// Generate ID for the encrypted file
 	id := services.GenerateID()
 
-	// Encrypt file data
-	encryptedData, err := services.EncryptFile(fileData, services.GetEncryptionKey())
-	if err != nil {
+	// Encrypt the upload chunk by chunk so the plaintext is never held in
+	// memory in full. The ciphertext is, since it is stored as one record;
+	// maxUploadFileSize bounds it.
+	var encryptedBuffer bytes.Buffer
+	encryptedBuffer.Grow(int(fileHeader.Size) + 64)
+	if err := services.EncryptStream(&encryptedBuffer, file, services.GetEncryptionKey(), id); err != nil {
 		responseHTML := fmt.Sprintf(`<div class="bg-red-100 border border-red-400 text-red-700 px-4 py-3 rounded mb-4">Error encrypting file: %v</div>`, err)
 		w.Write([]byte(responseHTML))
 		return
 	}
+	encryptedData := encryptedBuffer.Bytes()
 
 	// Hash PIN if provided
 	hashedPIN := ""
@@ -216,32 +683,6 @@ func EncryptFileHandler(w http.ResponseWriter, r *http.Request) {
 		hashedPIN = services.HashPIN(pin)
 	}
 
-	// Set expiration time and max views based on lifetime
-	var expiresAt *time.Time
-	maxViews := 1
-	switch lifetime {
-	case "1h":
-		expiry := time.Now().Add(time.Hour)
-		expiresAt = &expiry
-		maxViews = 999999
-	case "24h":
-		expiry := time.Now().Add(24 * time.Hour)
-		expiresAt = &expiry
-		maxViews = 999999
-	case "7d":
-		expiry := time.Now().Add(7 * 24 * time.Hour)
-		expiresAt = &expiry
-		maxViews = 999999
-	case "30d":
-		expiry := time.Now().Add(30 * 24 * time.Hour)
-		expiresAt = &expiry
-		maxViews = 999999
-	case "never":
-		maxViews = 999999
-	default:
-		maxViews = 1
-	}
-
 	// Create encrypted file data struct
 	encFileData := &models.EncryptedFileData{
 		ID:        id,
@@ -252,13 +693,13 @@ func EncryptFileHandler(w http.ResponseWriter, r *http.Request) {
 		PIN:       hashedPIN,
 		Lifetime:  lifetime,
 		CreatedAt: time.Now(),
-		ExpiresAt: expiresAt,
+		ExpiresAt: resolved.ExpiresAt,
 		ViewCount: 0,
-		MaxViews:  maxViews,
+		MaxViews:  resolved.MaxViews,
 	}
 
 	// Store encrypted file data
-	err = services.StoreEncryptedFileData(id, encFileData)
+	err = services.GetStorage().StoreFile(id, encFileData)
 	if err != nil {
 		log.Printf("Error storing encrypted file data for ID %s: %v", id, err)
 		responseHTML := fmt.Sprintf(`<div class="bg-red-100 border border-red-400 text-red-700 px-4 py-3 rounded mb-4">Error storing encrypted file: %v</div>`, err)
@@ -268,7 +709,8 @@ func EncryptFileHandler(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Successfully stored encrypted file data for ID: %s", id)
 
 	// Generate view URL
-	viewURL := fmt.Sprintf("http://localhost:8080/view-file/%s", id)
+	shareURL := buildShareURL(r, "/view-file/"+id)
+	viewURL := html.EscapeString(shareURL)
 
 	// Calculate file size in human-readable format
 	fileSize := formatFileSize(fileHeader.Size)
@@ -299,6 +741,7 @@ func EncryptFileHandler(w http.ResponseWriter, r *http.Request) {
 					</button>
 				</div>
 			</div>
+			%s
 			<div class="text-sm text-gray-600">
 				<p><strong>Lifetime:</strong> %s</p>
 				%s
@@ -313,7 +756,7 @@ func EncryptFileHandler(w http.ResponseWriter, r *http.Request) {
 				alert('Link copied to clipboard!');
 			}
 		</script>
-	`, fileHeader.Filename, fileSize, viewURL, getLifetimeDisplay(lifetime), getPINDisplay(pin))
+	`, fileHeader.Filename, fileSize, viewURL, getQRCodeDisplay(shareURL), getResolvedLifetimeDisplay(lifetime, resolved), getViewsDisplay(resolved.MaxViews)+getPINDisplay(pin))
 
 	w.Write([]byte(responseHTML))
 }
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/anazri/zeepass/internal/services"
+)
+
+func uploadFile(t *testing.T, content []byte) string {
+	return uploadFileWithFields(t, content, map[string]string{"lifetime": "1h"})
+}
+
+func uploadFileWithFields(t *testing.T, content []byte, fields map[string]string) string {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for name, value := range fields {
+		form.WriteField(name, value)
+	}
+	part, err := form.CreateFormFile("file", "upload.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(content)
+	form.Close()
+
+	r := httptest.NewRequest(http.MethodPost, "/encrypt-file", &body)
+	r.Header.Set("Content-Type", form.FormDataContentType())
+	w := httptest.NewRecorder()
+	EncryptFileHandler(w, r)
+	return w.Body.String()
+}
+
+func TestEncryptFileStoresStreamCiphertext(t *testing.T) {
+	content := bytes.Repeat([]byte("zeepass"), 30000)
+	page := uploadFile(t, content)
+	m := regexp.MustCompile(`/view-file/([A-Za-z0-9-]+)`).FindStringSubmatch(page)
+	if m == nil {
+		t.Fatalf("no share link in %q", page)
+	}
+
+	data, err := services.GetStorage().GetFile(m[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.FileSize != int64(len(content)) || !services.IsStreamCiphertext(data.Content) {
+		t.Fatalf("stored %d bytes of size %d", len(data.Content), data.FileSize)
+	}
+	var plain bytes.Buffer
+	if err := services.DecryptStream(&plain, bytes.NewReader(data.Content), services.GetEncryptionKey(), m[1]); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(plain.Bytes(), content) {
+		t.Error("stored file does not decrypt to the upload")
+	}
+}
+
+func TestEncryptFileRejectsOversizedUpload(t *testing.T) {
+	page := uploadFile(t, make([]byte, maxUploadBodySize))
+	if strings.Contains(page, "/view-file/") {
+		t.Fatal("oversized upload was stored")
+	}
+	if !strings.Contains(page, "Error parsing form data") && !strings.Contains(page, "less than 10MB") {
+		t.Errorf("unexpected response %q", page)
+	}
+}
+
+// The ttl value is quoted back in the error, which is swapped into the page
+func TestInvalidTTLIsEscaped(t *testing.T) {
+	const payload = `<img src=x onerror=alert(1)>`
+
+	form := url.Values{"text": {"secret"}, "lifetime": {"1h"}, "ttl": {payload}}
+	r := httptest.NewRequest(http.MethodPost, "/encrypt-text", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	EncryptTextHandler(w, r)
+	body := w.Body.String()
+	if !strings.Contains(body, "Lifetime not allowed") || strings.Contains(body, "<img") {
+		t.Errorf("text form response not escaped: %q", body)
+	}
+
+	body = uploadFileWithFields(t, []byte("data"), map[string]string{"lifetime": "1h", "ttl": payload})
+	if !strings.Contains(body, "Lifetime not allowed") || strings.Contains(body, "<img") {
+		t.Errorf("file form response not escaped: %q", body)
+	}
+}
+
+func TestEncryptTextRejectsOversizedBody(t *testing.T) {
+	t.Setenv("MAX_TEXT_SIZE", "1024")
+	form := url.Values{"text": {strings.Repeat("a", 200<<10)}, "lifetime": {"1h"}}
+	r := httptest.NewRequest(http.MethodPost, "/encrypt-text", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	EncryptTextHandler(w, r)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("url-encoded: status = %d, want 413", w.Code)
+	}
+
+	var body bytes.Buffer
+	multipartForm := multipart.NewWriter(&body)
+	multipartForm.WriteField("lifetime", "1h")
+	multipartForm.WriteField("text", "note")
+	part, _ := multipartForm.CreateFormFile("attachment", "big.bin")
+	part.Write(make([]byte, maxAttachmentSize+2<<20))
+	multipartForm.Close()
+	r = httptest.NewRequest(http.MethodPost, "/encrypt-text", &body)
+	r.Header.Set("Content-Type", multipartForm.FormDataContentType())
+	w = httptest.NewRecorder()
+	EncryptTextHandler(w, r)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("multipart: status = %d, want 413", w.Code)
+	}
+}
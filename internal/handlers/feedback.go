@@ -2,11 +2,16 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"net"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -25,20 +30,86 @@ type SurveyResponse struct {
 	Name               string    `json:"name,omitempty"`
 	Updates            bool      `json:"updates"`
 	IPAddress          string    `json:"ip_address"`
+	Source             string    `json:"source,omitempty"` // Campaign or referral source, e.g. "newsletter-2025-q3"
 }
 
+// maxSurveySourceLength bounds the stored campaign/source tag.
+const maxSurveySourceLength = 64
+
+// sanitizeSurveySource normalises a campaign/source tag to lowercase letters,
+// digits, '-', '_' and '.', turning spaces into dashes and dropping anything
+// else, so it is safe to store and group by.
+func sanitizeSurveySource(source string) string {
+	source = strings.ToLower(strings.TrimSpace(source))
+	var b strings.Builder
+	for _, r := range source {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			b.WriteRune(r)
+		case r == ' ':
+			b.WriteRune('-')
+		}
+		if b.Len() >= maxSurveySourceLength {
+			break
+		}
+	}
+	return strings.Trim(b.String(), "-_.")
+}
+
+// feedbackMaxBodySize is the largest feedback POST body accepted, set with
+// FEEDBACK_MAX_BODY_BYTES (default 16KB).
+func feedbackMaxBodySize() int64 {
+	if n, err := strconv.ParseInt(os.Getenv("FEEDBACK_MAX_BODY_BYTES"), 10, 64); err == nil && n > 0 {
+		return n
+	}
+	return 16 << 10
+}
+
+// feedbackRateLimit is the number of submissions each client IP may make
+// per hour, set with FEEDBACK_RATE_LIMIT (default 5).
+func feedbackRateLimit() int {
+	if n, err := strconv.Atoi(os.Getenv("FEEDBACK_RATE_LIMIT")); err == nil && n > 0 {
+		return n
+	}
+	return 5
+}
+
+var feedbackLimiter = &clientRateLimiter{windows: make(map[string]*rateWindow), period: time.Hour}
+
+// feedbackHoneypotField is a survey field hidden from people. Bots that fill
+// in every field get a normal success response, but nothing is saved.
+const feedbackHoneypotField = "website"
+
 func HandleFeedback(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
+	if !feedbackLimiter.allow(getClientIP(r), feedbackRateLimit()) {
+		w.Header().Set("Retry-After", "3600")
+		http.Error(w, "Too many submissions. Please try again later.", http.StatusTooManyRequests)
+		return
+	}
+
 	// Parse form data
+	r.Body = http.MaxBytesReader(w, r.Body, feedbackMaxBodySize())
 	if err := r.ParseForm(); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "Feedback is too large", http.StatusRequestEntityTooLarge)
+			return
+		}
 		http.Error(w, "Failed to parse form", http.StatusBadRequest)
 		return
 	}
 
+	if r.FormValue(feedbackHoneypotField) != "" {
+		log.Printf("Dropped feedback submission from %s: honeypot field filled", getClientIP(r))
+		writeFeedbackSent(w)
+		return
+	}
+
 	// Generate unique ID
 	id := fmt.Sprintf("survey_%d_%d", time.Now().Unix(), time.Now().Nanosecond())
 
@@ -66,6 +137,17 @@ func HandleFeedback(w http.ResponseWriter, r *http.Request) {
 		Name:               strings.TrimSpace(r.FormValue("name")),
 		Updates:            r.FormValue("updates") == "yes",
 		IPAddress:          getClientIP(r),
+		Source:             sanitizeSurveySource(r.FormValue("source")),
+	}
+
+	// The email is optional, but must be a real address when given
+	if response.Email != "" {
+		email, ok := normalizeEmail(response.Email)
+		if !ok {
+			http.Error(w, "Invalid email address", http.StatusBadRequest)
+			return
+		}
+		response.Email = email
 	}
 
 	// Save to file
@@ -75,6 +157,10 @@ func HandleFeedback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	writeFeedbackSent(w)
+}
+
+func writeFeedbackSent(w http.ResponseWriter) {
 	// Return success response
 	w.Header().Set("Content-Type", "text/html")
 	w.WriteHeader(http.StatusOK)
@@ -139,65 +225,123 @@ func HandleFeedback(w http.ResponseWriter, r *http.Request) {
 	`)
 }
 
+// surveyFile holds one JSON-encoded SurveyResponse per line.
+const surveyFile = "data/survey_responses.jsonl"
+
+// surveyFileMutex serializes appends to surveyFile.
+var surveyFileMutex sync.Mutex
+
+// saveSurveyToFile appends response to surveyFile as a single write, so the
+// cost does not grow with the number of stored responses.
 func saveSurveyToFile(response SurveyResponse) error {
-	// Create data directory if it doesn't exist
-	dataDir := "data"
-	if err := os.MkdirAll(dataDir, 0755); err != nil {
-		return fmt.Errorf("failed to create data directory: %v", err)
+	line, err := json.Marshal(response)
+	if err != nil {
+		return fmt.Errorf("failed to marshal survey response: %v", err)
 	}
+	line = append(line, '\n')
 
-	// Define file path
-	filePath := "data/survey_responses.json"
+	surveyFileMutex.Lock()
+	defer surveyFileMutex.Unlock()
 
-	// Read existing responses
-	var responses []SurveyResponse
-	if fileData, err := os.ReadFile(filePath); err == nil {
-		// File exists, unmarshal existing data
-		if err := json.Unmarshal(fileData, &responses); err != nil {
-			// If unmarshal fails, start with empty array
-			responses = []SurveyResponse{}
-		}
+	// Create data directory if it doesn't exist
+	if err := os.MkdirAll(filepath.Dir(surveyFile), 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %v", err)
 	}
 
-	// Add new response to the array
-	responses = append(responses, response)
-
-	// Marshal the updated array
-	jsonData, err := json.MarshalIndent(responses, "", "  ")
+	file, err := os.OpenFile(surveyFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
 	if err != nil {
-		return fmt.Errorf("failed to marshal survey responses: %v", err)
+		return fmt.Errorf("failed to open survey file: %v", err)
 	}
-
-	// Write the entire array back to file
-	if err := os.WriteFile(filePath, jsonData, 0644); err != nil {
+	if _, err := file.Write(line); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write to file: %v", err)
+	}
+	if err := file.Close(); err != nil {
 		return fmt.Errorf("failed to write to file: %v", err)
 	}
 
 	// Also log to console for immediate visibility
-	fmt.Printf("Survey response saved: ID=%s, Likelihood=%s, NPS=%d, Tools=%v, BusinessSector=%s, EnterpriseInterest=%s (Total responses: %d)\n", 
-		response.ID, response.Likelihood, response.NPS, response.Tools, response.BusinessSector, response.EnterpriseInterest, len(responses))
+	fmt.Printf("Survey response saved: ID=%s, Likelihood=%s, NPS=%d, Tools=%v, BusinessSector=%s, EnterpriseInterest=%s, Source=%s\n",
+		response.ID, response.Likelihood, response.NPS, response.Tools, response.BusinessSector, response.EnterpriseInterest, response.Source)
 
 	return nil
 }
 
+// getClientIP returns the address of the client that sent r. Forwarding
+// headers are only believed when the direct peer is listed in TRUSTED_PROXIES,
+// since anyone else could put any address there to dodge the per-IP limits.
+// Behind trusted proxies the client is the right-most X-Forwarded-For hop that
+// is not itself a trusted proxy.
 func getClientIP(r *http.Request) string {
-	// Check for X-Forwarded-For header (common in proxy/load balancer setups)
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+
+	proxies := trustedProxies()
+	if !isTrustedProxy(ip, proxies) {
+		return ip
+	}
+
 	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		// X-Forwarded-For can contain multiple IPs, get the first one
-		if ips := strings.Split(xff, ","); len(ips) > 0 {
-			return strings.TrimSpace(ips[0])
+		hops := strings.Split(xff, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if net.ParseIP(hop) == nil {
+				// A malformed entry ends the part of the chain we can vouch for
+				break
+			}
+			ip = hop
+			if !isTrustedProxy(hop, proxies) {
+				break
+			}
 		}
+		return ip
 	}
 
-	// Check for X-Real-IP header
-	if xri := r.Header.Get("X-Real-IP"); xri != "" {
+	if xri := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(xri) != nil {
 		return xri
 	}
+	return ip
+}
 
-	// Fall back to RemoteAddr
-	ip := r.RemoteAddr
-	if idx := strings.LastIndex(ip, ":"); idx != -1 {
-		ip = ip[:idx] // Remove port
+// trustedProxies parses TRUSTED_PROXIES, a comma separated list of proxy
+// addresses or CIDR ranges. Invalid entries are logged and skipped.
+func trustedProxies() []*net.IPNet {
+	var proxies []*net.IPNet
+	for _, entry := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if ip := net.ParseIP(entry); ip != nil {
+			bits := 128
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 32
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			log.Printf("Ignoring invalid TRUSTED_PROXIES entry %q", entry)
+			continue
+		}
+		proxies = append(proxies, network)
 	}
-	return ip
-}
\ No newline at end of file
+	return proxies
+}
+
+// isTrustedProxy reports whether addr falls in one of the proxy ranges.
+func isTrustedProxy(addr string, proxies []*net.IPNet) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, network := range proxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
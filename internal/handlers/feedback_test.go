@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestGetClientIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		trusted    string
+		remoteAddr string
+		xff        string
+		xRealIP    string
+		want       string
+	}{
+		{"no proxies", "", "203.0.113.7:5000", "", "", "203.0.113.7"},
+		{"forged header from untrusted peer", "", "203.0.113.7:5000", "198.51.100.1", "198.51.100.2", "203.0.113.7"},
+		{"peer not in trusted list", "10.0.0.0/8", "203.0.113.7:5000", "198.51.100.1", "", "203.0.113.7"},
+		{"trusted proxy", "10.0.0.0/8", "10.0.0.2:5000", "198.51.100.1", "", "198.51.100.1"},
+		{"client prepends a fake hop", "10.0.0.0/8", "10.0.0.2:5000", "1.2.3.4, 198.51.100.1", "", "198.51.100.1"},
+		{"chain of trusted proxies", "10.0.0.0/8", "10.0.0.2:5000", "198.51.100.1, 10.0.0.9", "", "198.51.100.1"},
+		{"malformed hop", "10.0.0.0/8", "10.0.0.2:5000", "198.51.100.1, junk, 10.0.0.9", "", "10.0.0.9"},
+		{"single address entry", "127.0.0.1", "127.0.0.1:5000", "198.51.100.1", "", "198.51.100.1"},
+		{"x-real-ip from trusted proxy", "127.0.0.1", "127.0.0.1:5000", "", "198.51.100.3", "198.51.100.3"},
+		{"ipv6 peer", "::1", "[::1]:5000", "2001:db8::1", "", "2001:db8::1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TRUSTED_PROXIES", tt.trusted)
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.xff != "" {
+				r.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			if tt.xRealIP != "" {
+				r.Header.Set("X-Real-IP", tt.xRealIP)
+			}
+			if got := getClientIP(r); got != tt.want {
+				t.Errorf("getClientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// postFeedback submits an invalid email so nothing is saved, which still
+// counts towards the rate limit.
+func postFeedback(remoteAddr, forwardedFor string) int {
+	form := url.Values{"email": {"not-an-email"}}
+	r := httptest.NewRequest(http.MethodPost, "/feedback", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.RemoteAddr = remoteAddr
+	if forwardedFor != "" {
+		r.Header.Set("X-Forwarded-For", forwardedFor)
+	}
+	w := httptest.NewRecorder()
+	HandleFeedback(w, r)
+	return w.Code
+}
+
+func TestFeedbackRateLimitIgnoresForgedForwardedFor(t *testing.T) {
+	t.Setenv("FEEDBACK_RATE_LIMIT", "2")
+	feedbackLimiter.windows = make(map[string]*rateWindow)
+
+	for i, fake := range []string{"1.1.1.1", "2.2.2.2"} {
+		if code := postFeedback("203.0.113.7:5000", fake); code == http.StatusTooManyRequests {
+			t.Fatalf("submission %d was rate limited", i+1)
+		}
+	}
+	if code := postFeedback("203.0.113.7:5000", "3.3.3.3"); code != http.StatusTooManyRequests {
+		t.Fatalf("third submission got %d, want 429", code)
+	}
+	if code := postFeedback("203.0.113.8:5000", ""); code == http.StatusTooManyRequests {
+		t.Error("another client was rate limited")
+	}
+}
+
+func TestFeedbackRateLimitBehindTrustedProxy(t *testing.T) {
+	t.Setenv("FEEDBACK_RATE_LIMIT", "1")
+	t.Setenv("TRUSTED_PROXIES", "10.0.0.1")
+	feedbackLimiter.windows = make(map[string]*rateWindow)
+
+	if code := postFeedback("10.0.0.1:5000", "198.51.100.1"); code == http.StatusTooManyRequests {
+		t.Fatal("first client was rate limited")
+	}
+	if code := postFeedback("10.0.0.1:5000", "198.51.100.2"); code == http.StatusTooManyRequests {
+		t.Fatal("clients behind the same proxy share a limit")
+	}
+	if code := postFeedback("10.0.0.1:5000", "198.51.100.1"); code != http.StatusTooManyRequests {
+		t.Fatalf("repeat submission got %d, want 429", code)
+	}
+}
+
+func TestConcurrentFeedbackIsPersistedIntact(t *testing.T) {
+	t.Chdir(t.TempDir())
+	feedbackLimiter.windows = make(map[string]*rateWindow)
+
+	const submissions = 50
+	var wg sync.WaitGroup
+	for i := 0; i < submissions; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			form := url.Values{
+				"name":            {fmt.Sprintf("user-%d", i)},
+				"likelihood":      {"very-likely"},
+				"tools":           {"text", "file"},
+				"feature_request": {strings.Repeat("x", 2000)},
+			}
+			r := httptest.NewRequest(http.MethodPost, "/feedback", strings.NewReader(form.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			r.RemoteAddr = fmt.Sprintf("203.0.113.%d:5000", i)
+			w := httptest.NewRecorder()
+			HandleFeedback(w, r)
+			if w.Code != http.StatusOK {
+				t.Errorf("submission %d got %d", i, w.Code)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	file, err := os.Open(surveyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var response SurveyResponse
+		if err := json.Unmarshal(scanner.Bytes(), &response); err != nil {
+			t.Fatalf("corrupt line %q: %v", scanner.Text(), err)
+		}
+		if len(response.FeatureRequest) != 2000 || len(response.Tools) != 2 {
+			t.Errorf("response %s was truncated", response.Name)
+		}
+		seen[response.Name] = true
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != submissions {
+		t.Errorf("%d distinct responses stored, want %d", len(seen), submissions)
+	}
+}
+
+func TestFeedbackHoneypotDropsSubmission(t *testing.T) {
+	t.Chdir(t.TempDir())
+	feedbackLimiter.windows = make(map[string]*rateWindow)
+
+	form := url.Values{"name": {"bot"}, "likelihood": {"very-likely"}, feedbackHoneypotField: {"http://spam.example"}}
+	r := httptest.NewRequest(http.MethodPost, "/feedback", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	HandleFeedback(w, r)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Thank You!") {
+		t.Fatalf("honeypot submission got %d, want the normal success response", w.Code)
+	}
+	if _, err := os.Stat(surveyFile); !os.IsNotExist(err) {
+		t.Errorf("honeypot submission was saved (stat: %v)", err)
+	}
+}
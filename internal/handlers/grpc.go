@@ -0,0 +1,321 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/anazri/zeepass/internal/models"
+	"github.com/anazri/zeepass/internal/proto/zeepassv1"
+	"github.com/anazri/zeepass/internal/services"
+)
+
+// grpcServer implements the ZeePass gRPC service on top of the same services
+// and helpers as the HTTP handlers.
+type grpcServer struct {
+	zeepassv1.UnimplementedZeePassServer
+}
+
+// StartGRPCServer serves the ZeePass gRPC API on GRPC_ADDR (default :9090)
+// when GRPC_ENABLED=true. It returns nil without starting anything when the
+// API is disabled. Every call must carry an API key from GRPC_API_KEYS.
+func StartGRPCServer() (*grpc.Server, error) {
+	if os.Getenv("GRPC_ENABLED") != "true" {
+		return nil, nil
+	}
+	if len(grpcAPIKeys()) == 0 {
+		return nil, fmt.Errorf("GRPC_ENABLED requires GRPC_API_KEYS")
+	}
+
+	addr := os.Getenv("GRPC_ADDR")
+	if addr == "" {
+		addr = ":9090"
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	server := NewGRPCServer()
+	go func() {
+		if err := server.Serve(listener); err != nil {
+			log.Printf("gRPC server stopped: %v", err)
+		}
+	}()
+	log.Printf("ZeePass gRPC server listening on %s", addr)
+	return server, nil
+}
+
+// NewGRPCServer returns a gRPC server with the ZeePass service and API key
+// authentication registered, ready to Serve on any listener.
+func NewGRPCServer() *grpc.Server {
+	server := grpc.NewServer(grpc.UnaryInterceptor(grpcAuthInterceptor))
+	zeepassv1.RegisterZeePassServer(server, &grpcServer{})
+	return server
+}
+
+// grpcAPIKeys parses GRPC_API_KEYS, a comma separated list of name=key pairs
+// in the same format as WEBHOOK_API_KEYS.
+func grpcAPIKeys() map[string]string {
+	keys := make(map[string]string)
+	for _, entry := range strings.Split(os.Getenv("GRPC_API_KEYS"), ",") {
+		name, key, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if ok && name != "" && key != "" {
+			keys[name] = key
+		}
+	}
+	return keys
+}
+
+func grpcAuthInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	token := ""
+	if values := md.Get("authorization"); len(values) > 0 {
+		token, _ = strings.CutPrefix(values[0], "Bearer ")
+	}
+
+	caller := ""
+	for name, key := range grpcAPIKeys() {
+		// Compare against every key so timing does not reveal which matched
+		if services.SecureCompare(token, key) && caller == "" {
+			caller = name
+		}
+	}
+	if token == "" || caller == "" {
+		return nil, status.Error(codes.Unauthenticated, "invalid or missing API key")
+	}
+	return handler(ctx, req)
+}
+
+func (s *grpcServer) Encrypt(ctx context.Context, req *zeepassv1.EncryptRequest) (*zeepassv1.EncryptResponse, error) {
+	ciphertext, err := services.EncryptFile(req.GetPlaintext(), services.GetEncryptionKey(), statelessContextPrefix+req.GetContext())
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "encryption failed: %v", err)
+	}
+	return &zeepassv1.EncryptResponse{Ciphertext: ciphertext}, nil
+}
+
+func (s *grpcServer) Decrypt(ctx context.Context, req *zeepassv1.DecryptRequest) (*zeepassv1.DecryptResponse, error) {
+	plaintext, err := services.DecryptFile(req.GetCiphertext(), services.GetEncryptionKey(), statelessContextPrefix+req.GetContext())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "ciphertext could not be decrypted with this context")
+	}
+	return &zeepassv1.DecryptResponse{Plaintext: plaintext}, nil
+}
+
+func (s *grpcServer) StoreSecret(ctx context.Context, req *zeepassv1.StoreSecretRequest) (*zeepassv1.StoreSecretResponse, error) {
+	text := strings.TrimSpace(req.GetText())
+	if text == "" {
+		return nil, status.Error(codes.InvalidArgument, "text is required")
+	}
+	if len(text) > maxTextSize() {
+		return nil, status.Errorf(codes.InvalidArgument, "text is too large (max %d bytes)", maxTextSize())
+	}
+	if len(req.GetPin()) > 50 {
+		return nil, status.Error(codes.InvalidArgument, "pin is too long (max 50 characters)")
+	}
+	lifetime := req.GetLifetime()
+	if lifetime == "" {
+		lifetime = "once"
+	}
+	if message := riskConfirmationMessage(lifetime, req.GetConfirmRisk()); message != "" {
+		return nil, status.Error(codes.FailedPrecondition, message)
+	}
+	resolved, err := services.ResolveLifetime(lifetime)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "lifetime not allowed: %v", err)
+	}
+
+	id, err := storeTextSecret(text, req.GetPin(), lifetime, resolved)
+	if err != nil {
+		log.Printf("Error storing gRPC secret: %v", err)
+		return nil, status.Error(codes.Internal, "error storing secret")
+	}
+
+	resp := &zeepassv1.StoreSecretResponse{
+		Id:           id,
+		ShareUrl:     buildShareURL(nil, "/view/"+id),
+		Lifetime:     getResolvedLifetimeDisplay(lifetime, resolved),
+		MaxViews:     int32(resolved.MaxViews),
+		PinProtected: req.GetPin() != "",
+	}
+	if resolved.ExpiresAt != nil {
+		resp.ExpiresAt = resolved.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+	return resp, nil
+}
+
+// RetrieveSecret follows the same checks as the view page: expiry, PIN,
+// security question and passphrase are verified before a view is counted.
+func (s *grpcServer) RetrieveSecret(ctx context.Context, req *zeepassv1.RetrieveSecretRequest) (*zeepassv1.RetrieveSecretResponse, error) {
+	id := req.GetId()
+	data, err := services.GetStorage().GetMessage(id)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, "secret not found")
+	}
+	if data.ExpiresAt != nil && time.Now().After(*data.ExpiresAt) {
+		services.GetStorage().DeleteMessage(id)
+		return nil, status.Error(codes.NotFound, "secret not found")
+	}
+	if data.ViewCount >= data.MaxViews {
+		services.GetStorage().DeleteMessage(id)
+		return nil, status.Error(codes.NotFound, "secret not found")
+	}
+	if data.ClientEncrypted {
+		return nil, status.Error(codes.FailedPrecondition, "secret is encrypted client-side and can only be opened in the browser")
+	}
+	guarded := data.PIN != "" || data.HintQuestion != "" || data.PassphraseSalt != ""
+	if guarded && pinLockedOut("message", id) {
+		return nil, status.Error(codes.ResourceExhausted, "too many incorrect attempts, try again later")
+	}
+	if data.PIN != "" && !services.VerifyPIN(req.GetPin(), data.PIN) {
+		recordFailedPIN("message", id, data.ExpiresAt)
+		return nil, status.Error(codes.PermissionDenied, "incorrect PIN")
+	}
+	if data.HintQuestion != "" && !services.VerifyHintAnswer(req.GetHintAnswer(), data.HintAnswerHash) {
+		recordFailedPIN("message", id, data.ExpiresAt)
+		return nil, status.Error(codes.PermissionDenied, "incorrect answer to the security question")
+	}
+	if guarded && data.PassphraseSalt == "" {
+		clearFailedPINs("message", id)
+	}
+
+	key := services.GetEncryptionKey()
+	if data.PassphraseSalt != "" {
+		salt, err := base64.StdEncoding.DecodeString(data.PassphraseSalt)
+		if err == nil {
+			key, err = services.DeriveKeyFromPassphrase(req.GetPassphrase(), salt)
+		}
+		if err != nil {
+			return nil, status.Error(codes.PermissionDenied, "incorrect passphrase")
+		}
+	}
+
+	text, err := services.Decrypt(data.Content, key, id)
+	if err != nil {
+		if data.PassphraseSalt != "" {
+			recordFailedPIN("message", id, data.ExpiresAt)
+			return nil, status.Error(codes.PermissionDenied, "incorrect passphrase")
+		}
+		return nil, status.Error(codes.Internal, "error decrypting secret")
+	}
+	if data.PassphraseSalt != "" {
+		clearFailedPINs("message", id)
+	}
+	text, err = decompressMessageText(id, data, text)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "error decrypting secret")
+	}
+	attachment, err := grpcAttachment(id, data, key)
+	if err != nil {
+		return nil, err
+	}
+
+	permitted, err := claimMessageView(id, data)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "error retrieving secret")
+	}
+	if !permitted {
+		return nil, status.Error(codes.NotFound, "secret not found")
+	}
+
+	return &zeepassv1.RetrieveSecretResponse{
+		Text:           text,
+		Attachment:     attachment,
+		ViewsRemaining: int32(max(data.MaxViews-data.ViewCount, 0)),
+	}, nil
+}
+
+// grpcAttachment decrypts a message's attachment, applying the download
+// malware scan when one is configured.
+func grpcAttachment(id string, data *models.EncryptedData, key []byte) (*zeepassv1.Attachment, error) {
+	if data.Attachment == nil {
+		return nil, nil
+	}
+	content, err := services.DecryptFile(data.Attachment.Content, key, attachmentAAD(id))
+	if err != nil {
+		log.Printf("Error decrypting attachment of message %s: %v", id, err)
+		return nil, status.Error(codes.Internal, "error decrypting secret")
+	}
+	if scanner := services.DownloadScanner(); scanner != nil {
+		if message := scanFile(scanner, bytes.NewReader(content), data.Attachment.FileName); message != "" {
+			services.WipeBytes(content)
+			return nil, status.Error(codes.FailedPrecondition, message)
+		}
+	}
+	return &zeepassv1.Attachment{
+		FileName: data.Attachment.FileName,
+		MimeType: data.Attachment.MimeType,
+		Content:  content,
+	}, nil
+}
+
+func (s *grpcServer) GeneratePassword(ctx context.Context, req *zeepassv1.GeneratePasswordRequest) (*zeepassv1.GeneratePasswordResponse, error) {
+	opts := services.PasswordOptions{
+		Length:       int(req.GetLength()),
+		UseNumbers:   req.GetUseNumbers(),
+		UseUppercase: req.GetUseUppercase(),
+		UseLowercase: req.GetUseLowercase(),
+		UseSymbols:   req.GetUseSymbols(),
+		Type:         req.GetType(),
+		Preset:       req.GetPreset(),
+		Words:        int(req.GetWords()),
+		Checksum:     req.GetChecksum(),
+		Separator:    req.GetSeparator(),
+		Capitalize:   req.GetCapitalize(),
+		AddNumber:    req.GetAddNumber(),
+	}
+	services.ApplyPasswordDefaults(&opts)
+	if _, err := validatePasswordOptions(opts); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	password, err := services.GeneratePassword(opts)
+	if err != nil {
+		log.Printf("Password generation error: %v", err)
+		return nil, status.Error(codes.Internal, "error generating password")
+	}
+	entropy := passwordEntropy(opts, password)
+	return &zeepassv1.GeneratePasswordResponse{
+		Password:    password,
+		Strength:    services.StrengthForEntropy(entropy),
+		EntropyBits: entropy,
+	}, nil
+}
+
+func (s *grpcServer) GenerateSSHKey(ctx context.Context, req *zeepassv1.GenerateSSHKeyRequest) (*zeepassv1.GenerateSSHKeyResponse, error) {
+	opts := services.SSHKeyOptions{
+		Type:       req.GetType(),
+		Length:     int(req.GetLength()),
+		Passphrase: req.GetPassphrase(),
+		Comment:    req.GetComment(),
+		Format:     req.GetFormat(),
+	}
+	if err := services.ValidateSSHKeyOptions(opts); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	keyPair, err := services.GenerateSSHKey(opts)
+	if err != nil {
+		log.Printf("SSH key generation error: %v", err)
+		return nil, status.Error(codes.Internal, "error generating SSH key")
+	}
+	return &zeepassv1.GenerateSSHKeyResponse{
+		PrivateKey:     keyPair.PrivateKey,
+		PublicKey:      keyPair.PublicKey,
+		Fingerprint:    keyPair.Fingerprint,
+		FingerprintMd5: keyPair.FingerprintMD5,
+		Randomart:      keyPair.Randomart,
+	}, nil
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/anazri/zeepass/internal/proto/zeepassv1"
+)
+
+const testGRPCKey = "test-key"
+
+// newTestGRPCClient serves NewGRPCServer over an in-memory listener and
+// returns a client for it.
+func newTestGRPCClient(t *testing.T) zeepassv1.ZeePassClient {
+	t.Helper()
+	t.Setenv("GRPC_API_KEYS", "tests="+testGRPCKey)
+
+	listener := bufconn.Listen(1 << 20)
+	server := NewGRPCServer()
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return zeepassv1.NewZeePassClient(conn)
+}
+
+func withAPIKey(key string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+key)
+}
+
+func TestGRPCRequiresAPIKey(t *testing.T) {
+	client := newTestGRPCClient(t)
+	req := &zeepassv1.GeneratePasswordRequest{Length: 16}
+
+	for name, ctx := range map[string]context.Context{
+		"missing": context.Background(),
+		"wrong":   withAPIKey("not-the-key"),
+	} {
+		if _, err := client.GeneratePassword(ctx, req); status.Code(err) != codes.Unauthenticated {
+			t.Errorf("%s key: got %v, want Unauthenticated", name, err)
+		}
+	}
+	if _, err := client.GeneratePassword(withAPIKey(testGRPCKey), req); err != nil {
+		t.Errorf("valid key: %v", err)
+	}
+}
+
+func TestGRPCStoreRetrieveBurnsOneTimeSecret(t *testing.T) {
+	client := newTestGRPCClient(t)
+	ctx := withAPIKey(testGRPCKey)
+
+	stored, err := client.StoreSecret(ctx, &zeepassv1.StoreSecretRequest{Text: "over grpc", Pin: "2468"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.GetMaxViews() != 1 || !stored.GetPinProtected() {
+		t.Fatalf("StoreSecret = %+v, want one view and a PIN", stored)
+	}
+
+	if _, err := client.RetrieveSecret(ctx, &zeepassv1.RetrieveSecretRequest{Id: stored.GetId(), Pin: "1357"}); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("wrong PIN: got %v, want PermissionDenied", err)
+	}
+	got, err := client.RetrieveSecret(ctx, &zeepassv1.RetrieveSecretRequest{Id: stored.GetId(), Pin: "2468"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.GetText() != "over grpc" || got.GetViewsRemaining() != 0 {
+		t.Errorf("RetrieveSecret = %+v", got)
+	}
+
+	if _, err := client.RetrieveSecret(ctx, &zeepassv1.RetrieveSecretRequest{Id: stored.GetId(), Pin: "2468"}); status.Code(err) != codes.NotFound {
+		t.Errorf("second retrieval: got %v, want NotFound", err)
+	}
+}
+
+func TestGRPCWrongPassphrasesLockOut(t *testing.T) {
+	t.Setenv("PIN_MAX_ATTEMPTS", "2")
+	client := newTestGRPCClient(t)
+	ctx := withAPIKey(testGRPCKey)
+	storeTestMessage(t, "grpc-passphrase", "secret", "", "correct horse", 3)
+
+	for i := 0; i < 2; i++ {
+		_, err := client.RetrieveSecret(ctx, &zeepassv1.RetrieveSecretRequest{Id: "grpc-passphrase", Passphrase: "wrong"})
+		if status.Code(err) != codes.PermissionDenied {
+			t.Fatalf("attempt %d: got %v, want PermissionDenied", i+1, err)
+		}
+	}
+	_, err := client.RetrieveSecret(ctx, &zeepassv1.RetrieveSecretRequest{Id: "grpc-passphrase", Passphrase: "correct horse"})
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("after the attempt cap: got %v, want ResourceExhausted", err)
+	}
+}
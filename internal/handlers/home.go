@@ -1,70 +1,48 @@
 package handlers
 
 import (
-	"html/template"
-	"log"
 	"net/http"
 
 	"github.com/anazri/zeepass/internal/models"
+	"github.com/anazri/zeepass/internal/services"
 )
 
 func HomeHandler(w http.ResponseWriter, r *http.Request) {
-	tmpl, err := template.ParseFiles("templates/index.html")
-	if err != nil {
-		http.Error(w, "Error loading template", http.StatusInternalServerError)
-		log.Printf("Template error: %v", err)
-		return
-	}
-
 	data := models.PageData{
 		Title: "ZeePass - Encrypt your data easily",
 	}
 
-	err = tmpl.Execute(w, data)
-	if err != nil {
-		http.Error(w, "Error executing template", http.StatusInternalServerError)
-		log.Printf("Template execution error: %v", err)
-	}
+	renderTemplate(w, "index.html", data)
 }
 
 func TextEncryptionHandler(w http.ResponseWriter, r *http.Request) {
-	tmpl, err := template.ParseFiles("templates/text-encryption.html")
-	if err != nil {
-		http.Error(w, "Error loading template", http.StatusInternalServerError)
-		log.Printf("Template error: %v", err)
-		return
-	}
-
 	data := models.PageData{
-		Title: "Text Encryption - ZeePass",
+		Title:            "Text Encryption - ZeePass",
+		ConfirmLifetimes: confirmationLifetimes(),
+		LifetimeOptions:  lifetimeSelectOptions(),
 	}
 
-	err = tmpl.Execute(w, data)
-	if err != nil {
-		http.Error(w, "Error executing template", http.StatusInternalServerError)
-		log.Printf("Template execution error: %v", err)
-	}
+	renderTemplate(w, "text-encryption.html", data)
 }
 
 func FileEncryptionHandler(w http.ResponseWriter, r *http.Request) {
-	tmpl, err := template.ParseFiles("templates/file-encryption.html")
-	if err != nil {
-		http.Error(w, "Error loading template", http.StatusInternalServerError)
-		log.Printf("Template error: %v", err)
-		return
-	}
-
 	data := models.PageData{
-		Title: "File Encryption - ZeePass",
+		Title:            "File Encryption - ZeePass",
+		ConfirmLifetimes: confirmationLifetimes(),
+		LifetimeOptions:  lifetimeSelectOptions(),
 	}
 
-	err = tmpl.Execute(w, data)
-	if err != nil {
-		http.Error(w, "Error executing template", http.StatusInternalServerError)
-		log.Printf("Template execution error: %v", err)
-	}
+	renderTemplate(w, "file-encryption.html", data)
 }
 
 func StaticHandler(w http.ResponseWriter, r *http.Request) {
 	http.StripPrefix("/static/", http.FileServer(http.Dir("static/"))).ServeHTTP(w, r)
-}
\ No newline at end of file
+}
+// lifetimeSelectOptions lists the lifetimes allowed by the configured maximum.
+func lifetimeSelectOptions() []models.SelectOption {
+	options := []models.SelectOption{}
+	for _, option := range services.LifetimeOptions() {
+		options = append(options, models.SelectOption{Value: option.Value, Label: option.Label})
+	}
+	return options
+}
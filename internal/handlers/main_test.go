@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"log"
+	"os"
+	"testing"
+
+	"github.com/anazri/zeepass/internal/services"
+)
+
+// TestMain runs the handler tests against the in-memory storage backend with
+// the development encryption key.
+func TestMain(m *testing.M) {
+	os.Setenv("ZEEPASS_DEV_MODE", "1")
+	os.Setenv("STORAGE_BACKEND", "memory")
+	if err := services.InitEncryptionKey(); err != nil {
+		log.Fatal(err)
+	}
+	if err := services.InitStorage(); err != nil {
+		log.Fatal(err)
+	}
+	os.Exit(m.Run())
+}
@@ -2,32 +2,37 @@ package handlers
 
 import (
 	"encoding/json"
-	"html/template"
+	"fmt"
 	"log"
+	"math"
 	"net/http"
+	"os"
 	"strconv"
+	"strings"
 
 	"github.com/anazri/zeepass/internal/models"
 	"github.com/anazri/zeepass/internal/services"
 )
 
 func PasswordGeneratorHandler(w http.ResponseWriter, r *http.Request) {
-	tmpl, err := template.ParseFiles("templates/password-generator.html")
-	if err != nil {
-		http.Error(w, "Error loading template", http.StatusInternalServerError)
-		log.Printf("Template error: %v", err)
-		return
-	}
-
 	data := models.PageData{
-		Title: "Password Generator - ZeePass",
+		Title:            "Password Generator - ZeePass",
+		ConfirmLifetimes: confirmationLifetimes(),
+		LifetimeOptions:  lifetimeSelectOptions(),
 	}
-
-	err = tmpl.Execute(w, data)
-	if err != nil {
-		http.Error(w, "Error executing template", http.StatusInternalServerError)
-		log.Printf("Template execution error: %v", err)
+	data.PasswordMinLength, data.PasswordMaxLength = services.PasswordLengthLimits()
+	data.PINMinLength, _ = services.PINLengthLimits()
+	defaults := services.DefaultPasswordOptions()
+	data.PasswordDefaults = models.PasswordDefaults{
+		Length:       defaults.Length,
+		Type:         defaults.Type,
+		UseNumbers:   defaults.UseNumbers,
+		UseUppercase: defaults.UseUppercase,
+		UseLowercase: defaults.UseLowercase,
+		UseSymbols:   defaults.UseSymbols,
 	}
+
+	renderTemplate(w, "password-generator.html", data)
 }
 
 func GeneratePasswordHandler(w http.ResponseWriter, r *http.Request) {
@@ -37,36 +42,104 @@ func GeneratePasswordHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Parse JSON request
-	var opts services.PasswordOptions
-	if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+	var req struct {
+		services.PasswordOptions
+		CheckPwned bool `json:"check_pwned"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		// Try to parse as form data for backward compatibility
 		if err := r.ParseForm(); err != nil {
 			http.Error(w, "Error parsing request", http.StatusBadRequest)
 			return
 		}
 
-		// Parse form values
-		lengthStr := r.FormValue("length")
-		length, err := strconv.Atoi(lengthStr)
-		if err != nil {
-			length = 12 // default
-		}
+		// Parse form values, a missing length is filled in from the defaults
+		length, _ := strconv.Atoi(r.FormValue("length"))
 
-		opts = services.PasswordOptions{
+		req.CheckPwned = r.FormValue("check_pwned") == "true"
+		opts := services.PasswordOptions{
 			Length:       length,
 			UseNumbers:   r.FormValue("use_numbers") == "true",
 			UseUppercase: r.FormValue("use_uppercase") == "true",
 			UseLowercase: r.FormValue("use_lowercase") == "true",
 			UseSymbols:   r.FormValue("use_symbols") == "true",
 			Type:         r.FormValue("type"),
+			Preset:       r.FormValue("preset"),
+			Checksum:     r.FormValue("checksum") == "true",
+			Separator:    r.FormValue("separator"),
+			Capitalize:   r.FormValue("capitalize") == "true",
+			AddNumber:    r.FormValue("add_number") == "true",
 		}
+		if words, err := strconv.Atoi(r.FormValue("words")); err == nil {
+			opts.Words = words
+		}
+		req.PasswordOptions = opts
+	}
+	services.ApplyPasswordDefaults(&req.PasswordOptions)
 
-		// Default to numbers if nothing selected
-		if !opts.UseNumbers && !opts.UseUppercase && !opts.UseLowercase && !opts.UseSymbols {
-			opts.UseNumbers = true
+	writeGeneratedPassword(w, req.PasswordOptions, req.CheckPwned)
+}
+
+// PasswordAPIHandler generates a password from a named compliance preset,
+// e.g. GET /api/password?preset=pci.
+func PasswordAPIHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	preset := r.URL.Query().Get("preset")
+	if preset == "" {
+		http.Error(w, "Missing preset (available: "+strings.Join(services.PasswordPresetNames(), ", ")+")", http.StatusBadRequest)
+		return
+	}
+
+	writeGeneratedPassword(w, services.PasswordOptions{Preset: preset}, false)
+}
+
+// validatePasswordOptions checks opts before anything is generated and
+// returns the named preset, if any. Errors are meant for a 400 response.
+func validatePasswordOptions(opts services.PasswordOptions) (services.PasswordPreset, error) {
+	var preset services.PasswordPreset
+	if opts.Preset != "" {
+		var ok bool
+		preset, ok = services.GetPasswordPreset(opts.Preset)
+		if !ok {
+			return preset, fmt.Errorf("unknown preset (available: %s)", strings.Join(services.PasswordPresetNames(), ", "))
 		}
 	}
 
+	if err := services.ValidatePasswordLength(opts); err != nil {
+		return preset, err
+	}
+	if opts.Type == "passphrase" {
+		if err := services.ValidatePassphraseSeparator(opts.Separator); err != nil {
+			return preset, err
+		}
+	}
+	return preset, nil
+}
+
+// passwordEntropy reports the entropy of a generated password in bits,
+// rounded to one decimal: exact for passphrases, estimated otherwise.
+func passwordEntropy(opts services.PasswordOptions, password string) float64 {
+	entropy := services.CalculatePasswordEntropy(password)
+	if opts.Preset == "" && opts.Type == "passphrase" {
+		entropy = services.PassphraseEntropy(opts)
+	}
+	return math.Round(entropy*10) / 10
+}
+
+// writeGeneratedPassword generates a password and writes it as JSON. With
+// checkPwned the password is also looked up in Have I Been Pwned; a failed
+// lookup is reported in the response instead of failing the request.
+func writeGeneratedPassword(w http.ResponseWriter, opts services.PasswordOptions, checkPwned bool) {
+	preset, err := validatePasswordOptions(opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// Generate password
 	password, err := services.GeneratePassword(opts)
 	if err != nil {
@@ -76,15 +149,259 @@ func GeneratePasswordHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Calculate strength
-	strength := services.CalculatePasswordStrength(password)
+	entropy := passwordEntropy(opts, password)
+	strength := services.StrengthForEntropy(entropy)
 
 	// Return JSON response
 	response := map[string]interface{}{
-		"password": password,
-		"strength": strength,
-		"length":   len(password),
+		"password":     password,
+		"strength":     strength,
+		"length":       len(password),
+		"entropy_bits": entropy,
+	}
+	if preset.Name != "" {
+		response["preset"] = preset.Name
+	}
+	if checkPwned {
+		count, err := services.IsPwned(password)
+		if err != nil {
+			log.Printf("Pwned password check failed: %v", err)
+			response["pwned_check_error"] = "breach lookup failed"
+		} else {
+			response["pwned_count"] = count
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// maxBulkPasswords caps the count accepted by GeneratePasswordsHandler.
+const maxBulkPasswords = 100
+
+// generatedPassword is one entry of a bulk generation response.
+type generatedPassword struct {
+	Password    string  `json:"password"`
+	Strength    string  `json:"strength"`
+	EntropyBits float64 `json:"entropy_bits"`
+}
+
+// GeneratePasswordsHandler generates a batch of distinct passwords with the
+// same options, e.g. POST /generate-passwords {"count": 20, "length": 16}.
+func GeneratePasswordsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		services.PasswordOptions
+		Count int `json:"count"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Error parsing request", http.StatusBadRequest)
+		return
+	}
+	if req.Count < 1 || req.Count > maxBulkPasswords {
+		http.Error(w, fmt.Sprintf("count must be between 1 and %d", maxBulkPasswords), http.StatusBadRequest)
+		return
+	}
+	services.ApplyPasswordDefaults(&req.PasswordOptions)
+	if _, err := validatePasswordOptions(req.PasswordOptions); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Retry duplicates a bounded number of times, short PINs can run out
+	passwords := make([]generatedPassword, 0, req.Count)
+	seen := make(map[string]bool, req.Count)
+	for attempts := 0; len(passwords) < req.Count && attempts < req.Count*10; attempts++ {
+		password, err := services.GeneratePassword(req.PasswordOptions)
+		if err != nil {
+			http.Error(w, "Error generating password", http.StatusInternalServerError)
+			log.Printf("Password generation error: %v", err)
+			return
+		}
+		if seen[password] {
+			continue
+		}
+		seen[password] = true
+		entropy := passwordEntropy(req.PasswordOptions, password)
+		passwords = append(passwords, generatedPassword{
+			Password:    password,
+			Strength:    services.StrengthForEntropy(entropy),
+			EntropyBits: entropy,
+		})
+	}
+	if len(passwords) < req.Count {
+		http.Error(w, "Could not generate enough distinct passwords with these options", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"passwords": passwords,
+		"count":     len(passwords),
+	})
+}
+
+// VerifyPassphraseHandler checks the checksum word of a passphrase produced
+// by the passphrase generator, e.g. after it was read out over the phone.
+func VerifyPassphraseHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Passphrase string `json:"passphrase"`
+	}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Error parsing request", http.StatusBadRequest)
+			return
+		}
+	} else {
+		req.Passphrase = r.FormValue("passphrase")
+	}
+
+	valid, err := services.VerifyPassphraseChecksum(req.Passphrase)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	message := "Checksum matches, the passphrase was transcribed correctly"
+	if !valid {
+		message = "Checksum does not match, one or more words are wrong"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"valid":   valid,
+		"message": message,
+	})
+}
+
+// breachCheckRateLimit is the number of bulk breach checks each client may
+// make per minute, set with BREACH_CHECK_RATE_LIMIT (default 5).
+func breachCheckRateLimit() int {
+	if n, err := strconv.Atoi(os.Getenv("BREACH_CHECK_RATE_LIMIT")); err == nil && n > 0 {
+		return n
+	}
+	return 5
+}
+
+var breachCheckLimiter = &clientRateLimiter{windows: make(map[string]*rateWindow)}
+
+// CheckPasswordsHandler checks a batch of passwords against the Have I Been
+// Pwned corpus, e.g. when auditing credentials during a migration. Results are
+// returned in request order; the passwords themselves are never echoed or logged.
+// Batches are capped at services.MaxBreachCheckBatch and requests are rate
+// limited per client, so the endpoint cannot be used to flood the HIBP API.
+func CheckPasswordsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !breachCheckLimiter.allow(getClientIP(r), breachCheckRateLimit()) {
+		w.Header().Set("Retry-After", "60")
+		http.Error(w, "Too many breach checks. Please try again later.", http.StatusTooManyRequests)
+		return
+	}
+
+	var req struct {
+		Passwords []string `json:"passwords"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		http.Error(w, "Error parsing request", http.StatusBadRequest)
+		return
+	}
+	if len(req.Passwords) == 0 {
+		http.Error(w, "No passwords provided", http.StatusBadRequest)
+		return
+	}
+	if len(req.Passwords) > services.MaxBreachCheckBatch {
+		http.Error(w, fmt.Sprintf("At most %d passwords can be checked at once", services.MaxBreachCheckBatch), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	results, err := services.CheckPasswordsBreached(req.Passwords)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"results": results,
+	})
+}
+
+// SharePasswordHandler generates a password and immediately stores it as an
+// encrypted secret, returning both the password and a share link. It accepts
+// the same options as GeneratePasswordHandler plus lifetime (default "once"),
+// pin and confirm_risk.
+func SharePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		services.PasswordOptions
+		Lifetime    string `json:"lifetime"`
+		PIN         string `json:"pin"`
+		ConfirmRisk bool   `json:"confirm_risk"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Error parsing request", http.StatusBadRequest)
+		return
+	}
+	if req.Lifetime == "" {
+		req.Lifetime = "once"
+	}
+	services.ApplyPasswordDefaults(&req.PasswordOptions)
+
+	if message := riskConfirmationMessage(req.Lifetime, req.ConfirmRisk); message != "" {
+		http.Error(w, message, http.StatusBadRequest)
+		return
+	}
+	resolved, err := services.ResolveLifetime(req.Lifetime)
+	if err != nil {
+		http.Error(w, "Lifetime not allowed: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	password, err := services.GeneratePassword(req.PasswordOptions)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id, err := storeTextSecret(password, req.PIN, req.Lifetime, resolved)
+	if err != nil {
+		log.Printf("Error sharing generated password: %v", err)
+		http.Error(w, "Error creating share link", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("Stored generated password as message %s", id)
+
+	entropy := passwordEntropy(req.PasswordOptions, password)
+	response := map[string]interface{}{
+		"password":      password,
+		"strength":      services.StrengthForEntropy(entropy),
+		"length":        len(password),
+		"entropy_bits":  entropy,
+		"share_url":     buildShareURL(r, "/view/"+id),
+		"lifetime":      getResolvedLifetimeDisplay(req.Lifetime, resolved),
+		"pin_protected": req.PIN != "",
+	}
+	if resolved.ExpiresAt != nil {
+		response["expires_at"] = resolved.ExpiresAt
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
-}
\ No newline at end of file
+}
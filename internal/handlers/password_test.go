@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/anazri/zeepass/internal/services"
+)
+
+func checkPasswords(remoteAddr string, passwords []string) int {
+	body, _ := json.Marshal(map[string][]string{"passwords": passwords})
+	r := httptest.NewRequest(http.MethodPost, "/api/check-passwords", strings.NewReader(string(body)))
+	r.RemoteAddr = remoteAddr
+	w := httptest.NewRecorder()
+	CheckPasswordsHandler(w, r)
+	return w.Code
+}
+
+func TestCheckPasswordsLimits(t *testing.T) {
+	var lookups atomic.Int32
+	hibp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lookups.Add(1)
+	}))
+	defer hibp.Close()
+	t.Setenv("HIBP_API_URL", hibp.URL+"/range/")
+	t.Setenv("BREACH_CHECK_RATE_LIMIT", "2")
+	breachCheckLimiter.windows = make(map[string]*rateWindow)
+
+	tooMany := make([]string, services.MaxBreachCheckBatch+1)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprintf("password-%d", i)
+	}
+	if code := checkPasswords("203.0.113.7:5000", tooMany); code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("oversized batch got %d, want 413", code)
+	}
+	if n := lookups.Load(); n != 0 {
+		t.Fatalf("oversized batch made %d HIBP requests", n)
+	}
+
+	if code := checkPasswords("203.0.113.7:5000", []string{"hunter2"}); code != http.StatusOK {
+		t.Fatalf("check got %d, want 200", code)
+	}
+	if code := checkPasswords("203.0.113.7:5000", []string{"hunter2"}); code != http.StatusTooManyRequests {
+		t.Fatalf("check over the limit got %d, want 429", code)
+	}
+	if code := checkPasswords("203.0.113.8:5000", []string{"hunter2"}); code != http.StatusOK {
+		t.Errorf("another client got %d, want 200", code)
+	}
+}
@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"fmt"
+	"log"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// qrImageSize is the width and height of the generated PNG in pixels.
+const qrImageSize = 256
+
+// getQRCodeDisplay embeds the QR code of a share link in the success page so
+// it can be scanned with a phone. The PNG is inlined as a data: URI; serving
+// it from a URL would put the secret link in query strings, access logs and
+// browser history.
+func getQRCodeDisplay(shareURL string) string {
+	png, err := qrcode.Encode(shareURL, qrcode.Medium, qrImageSize)
+	if err != nil {
+		log.Printf("QR code error: %v", err)
+		return ""
+	}
+	return fmt.Sprintf(`<div class="mb-4 text-center">
+				<img src="data:image/png;base64,%s" alt="QR code of the link" width="192" height="192" class="mx-auto border border-gray-200 rounded">
+				<p class="text-xs text-gray-500 mt-1">Scan to open the link on a phone</p>
+			</div>`, base64.StdEncoding.EncodeToString(png))
+}
@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestQRCodeDisplayInlinesPNG(t *testing.T) {
+	link := "https://zeepass.example.com/view/abc123"
+	display := getQRCodeDisplay(link)
+
+	if strings.Contains(display, "abc123") {
+		t.Fatal("share link appears in the markup")
+	}
+	m := regexp.MustCompile(`src="data:image/png;base64,([A-Za-z0-9+/=]+)"`).FindStringSubmatch(display)
+	if m == nil {
+		t.Fatalf("no inline PNG in %q", display)
+	}
+	png, err := base64.StdEncoding.DecodeString(m[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(png, []byte("\x89PNG\r\n\x1a\n")) {
+		t.Error("data URI is not a PNG")
+	}
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"strings"
+)
+
+// RecoverPanics wraps the server's handlers so that a panic while serving a
+// request is logged with its stack trace and answered with a 500, instead of
+// the connection being dropped. API requests get a JSON error, pages an HTML
+// one. Nothing is written when the handler had already started its response
+// or hijacked the connection (WebSocket upgrades), since the client could not
+// tell the error from the data it was receiving.
+func RecoverPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := &recoveryResponseWriter{ResponseWriter: w}
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+			if err == http.ErrAbortHandler {
+				// Deliberate abort, net/http closes the connection quietly
+				panic(err)
+			}
+
+			log.Printf("Panic serving %s %s: %v\n%s", r.Method, r.URL.Path, err, debug.Stack())
+			if rw.hijacked || rw.wroteHeader {
+				return
+			}
+			writeInternalError(w, r)
+		}()
+
+		next.ServeHTTP(rw, r)
+	})
+}
+
+// writeInternalError sends a generic 500 in the format the client expects.
+func writeInternalError(w http.ResponseWriter, r *http.Request) {
+	w.Header().Del("Content-Disposition")
+	w.Header().Del("Content-Length")
+	w.Header().Set("Cache-Control", "no-store")
+
+	if strings.HasPrefix(r.URL.Path, "/api/") || strings.Contains(r.Header.Get("Accept"), "application/json") {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusInternalServerError)
+	renderViewStatus(w, viewStatusPage{
+		Title:   "Something Went Wrong",
+		Heading: "Something Went Wrong",
+		Message: "The server ran into an unexpected error. Please try again.",
+		Retry:   true,
+	})
+}
+
+// recoveryResponseWriter notes whether the response was started or the
+// connection hijacked. It passes Flush and Hijack through, which SSE and
+// WebSocket handlers rely on.
+type recoveryResponseWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+	hijacked    bool
+}
+
+func (rw *recoveryResponseWriter) WriteHeader(status int) {
+	rw.wroteHeader = true
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *recoveryResponseWriter) Write(p []byte) (int, error) {
+	rw.wroteHeader = true
+	return rw.ResponseWriter.Write(p)
+}
+
+func (rw *recoveryResponseWriter) Flush() {
+	if flusher, ok := rw.ResponseWriter.(http.Flusher); ok {
+		rw.wroteHeader = true
+		flusher.Flush()
+	}
+}
+
+func (rw *recoveryResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := rw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	conn, buf, err := hijacker.Hijack()
+	if err == nil {
+		rw.hijacked = true
+	}
+	return conn, buf, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (rw *recoveryResponseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
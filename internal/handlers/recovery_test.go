@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRecoverPanics(t *testing.T) {
+	panicking := RecoverPanics(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Disposition", "attachment")
+		panic("boom")
+	}))
+
+	t.Run("api", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		panicking.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/check-passwords", nil))
+		if w.Code != http.StatusInternalServerError {
+			t.Fatalf("status = %d, want 500", w.Code)
+		}
+		var body map[string]string
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body["error"] == "" {
+			t.Errorf("body = %q, want a JSON error", w.Body.String())
+		}
+		if w.Header().Get("Content-Disposition") != "" {
+			t.Error("Content-Disposition kept on the error response")
+		}
+	})
+
+	t.Run("page", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		panicking.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/view/abc", nil))
+		if w.Code != http.StatusInternalServerError {
+			t.Fatalf("status = %d, want 500", w.Code)
+		}
+		if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") || !strings.Contains(w.Body.String(), "Something Went Wrong") {
+			t.Errorf("got %q, want the HTML error page", w.Body.String())
+		}
+	})
+}
+
+func TestRecoverPanicsAfterResponseStarted(t *testing.T) {
+	handler := RecoverPanics(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("partial"))
+		panic("boom")
+	}))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/download/abc", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "partial" {
+		t.Errorf("got %d %q, want the partial response left alone", w.Code, w.Body.String())
+	}
+}
+
+func TestRecoverPanicsRepanicsAbortHandler(t *testing.T) {
+	handler := RecoverPanics(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+	defer func() {
+		if err := recover(); err != http.ErrAbortHandler {
+			t.Errorf("recovered %v, want http.ErrAbortHandler", err)
+		}
+	}()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	t.Error("ErrAbortHandler was swallowed")
+}
+
+func TestRecoverPanicsPassesFlusherThrough(t *testing.T) {
+	handler := RecoverPanics(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := w.(http.Flusher); !ok {
+			t.Error("wrapped writer is not a Flusher")
+		}
+		if err := http.NewResponseController(w).Flush(); err != nil {
+			t.Errorf("Flush: %v", err)
+		}
+	}))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/sse/chat", nil))
+	if !w.Flushed {
+		t.Error("response was not flushed")
+	}
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// defaultReportOnlyPolicy is the strict policy observed in report-only mode:
+// it only allows same-origin resources, so every CDN script or inline block
+// the pages still rely on shows up as a violation.
+const defaultReportOnlyPolicy = "default-src 'self'; script-src 'self'; style-src 'self'; img-src 'self' data:; " +
+	"connect-src 'self'; font-src 'self'; object-src 'none'; base-uri 'self'; form-action 'self'; frame-ancestors 'none'"
+
+// maxCSPReportSize caps the body accepted by CSPReportHandler.
+const maxCSPReportSize = 64 * 1024
+
+// SecurityHeaders wraps the server's handlers and sets response headers that
+// apply to every page. With CSP_REPORT_ONLY=true it also sends a
+// Content-Security-Policy-Report-Only header (CSP_REPORT_ONLY_POLICY, or a
+// strict same-origin default) whose violations are posted to /csp-report.
+func SecurityHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Referrer-Policy", "no-referrer")
+
+		if os.Getenv("CSP_REPORT_ONLY") == "true" {
+			policy := os.Getenv("CSP_REPORT_ONLY_POLICY")
+			if policy == "" {
+				policy = defaultReportOnlyPolicy
+			}
+			w.Header().Set("Reporting-Endpoints", `csp-endpoint="/csp-report"`)
+			w.Header().Set("Content-Security-Policy-Report-Only", policy+"; report-uri /csp-report; report-to csp-endpoint")
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// cspViolation holds the fields of a violation report used for grouping.
+// Browsers send either the legacy report-uri format or Reporting API reports.
+type cspViolation struct {
+	DocumentURI        string `json:"document-uri"`
+	BlockedURI         string `json:"blocked-uri"`
+	ViolatedDirective  string `json:"violated-directive"`
+	EffectiveDirective string `json:"effective-directive"`
+}
+
+type reportingAPIReport struct {
+	Type string `json:"type"`
+	Body struct {
+		DocumentURL        string `json:"documentURL"`
+		BlockedURL         string `json:"blockedURL"`
+		EffectiveDirective string `json:"effectiveDirective"`
+	} `json:"body"`
+}
+
+// CSPReportHandler collects Content-Security-Policy violation reports. Reports
+// are grouped by directive, blocked origin and page so that a page load with
+// many violations, or many visitors hitting the same one, does not flood the
+// log: each group is logged the first time and then at 10, 100, 1000, ...
+// occurrences.
+func CSPReportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxCSPReportSize+1))
+	if err != nil || len(body) > maxCSPReportSize {
+		http.Error(w, "Report too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	violations, err := parseCSPReports(body)
+	if err != nil {
+		http.Error(w, "Error parsing report", http.StatusBadRequest)
+		return
+	}
+	for _, v := range violations {
+		cspReports.record(v)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func parseCSPReports(body []byte) ([]cspViolation, error) {
+	trimmed := strings.TrimSpace(string(body))
+
+	// Reporting API: an array of reports, possibly of other types
+	if strings.HasPrefix(trimmed, "[") {
+		var reports []reportingAPIReport
+		if err := json.Unmarshal(body, &reports); err != nil {
+			return nil, err
+		}
+		var violations []cspViolation
+		for _, report := range reports {
+			if report.Type != "csp-violation" {
+				continue
+			}
+			violations = append(violations, cspViolation{
+				DocumentURI:        report.Body.DocumentURL,
+				BlockedURI:         report.Body.BlockedURL,
+				EffectiveDirective: report.Body.EffectiveDirective,
+			})
+		}
+		return violations, nil
+	}
+
+	// report-uri: a single {"csp-report": {...}} object
+	var legacy struct {
+		Report cspViolation `json:"csp-report"`
+	}
+	if err := json.Unmarshal(body, &legacy); err != nil {
+		return nil, err
+	}
+	return []cspViolation{legacy.Report}, nil
+}
+
+// maxCSPReportGroups bounds the number of distinct violation groups tracked.
+const maxCSPReportGroups = 1000
+
+type cspReportAggregator struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+var cspReports = &cspReportAggregator{counts: make(map[string]int)}
+
+// record counts a violation and logs it when its group reaches a power of ten.
+func (a *cspReportAggregator) record(v cspViolation) {
+	directive := v.EffectiveDirective
+	if directive == "" {
+		directive = v.ViolatedDirective
+	}
+	key := directive + " " + reportOrigin(v.BlockedURI) + " on " + reportPath(v.DocumentURI)
+
+	a.mu.Lock()
+	count, known := a.counts[key]
+	if !known && len(a.counts) >= maxCSPReportGroups {
+		a.mu.Unlock()
+		return
+	}
+	count++
+	a.counts[key] = count
+	a.mu.Unlock()
+
+	if isPowerOfTen(count) {
+		log.Printf("CSP violation (report-only, seen %d times): %s", count, key)
+	}
+}
+
+// reportOrigin reduces a blocked URI to its origin, or keeps keywords such as
+// "inline" and "eval" as they are.
+func reportOrigin(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Host == "" {
+		return uri
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// reportPath drops the query and fragment of the page URI, which may carry
+// secret ids or keys.
+func reportPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return ""
+	}
+	path := u.Path
+	if strings.HasPrefix(path, "/view/") {
+		path = "/view/"
+	} else if strings.HasPrefix(path, "/view-file/") {
+		path = "/view-file/"
+	}
+	return path
+}
+
+func isPowerOfTen(n int) bool {
+	for n >= 10 && n%10 == 0 {
+		n /= 10
+	}
+	return n == 1
+}
@@ -2,31 +2,21 @@ package handlers
 
 import (
 	"encoding/json"
-	"html/template"
+	"fmt"
 	"log"
 	"net/http"
+	"strings"
 
 	"github.com/anazri/zeepass/internal/models"
 	"github.com/anazri/zeepass/internal/services"
 )
 
 func SSHKeyHandler(w http.ResponseWriter, r *http.Request) {
-	tmpl, err := template.ParseFiles("templates/ssh-key.html")
-	if err != nil {
-		http.Error(w, "Error loading template", http.StatusInternalServerError)
-		log.Printf("Template error: %v", err)
-		return
-	}
-
 	data := models.PageData{
 		Title: "SSH Key - ZeePass",
 	}
 
-	err = tmpl.Execute(w, data)
-	if err != nil {
-		http.Error(w, "Error executing template", http.StatusInternalServerError)
-		log.Printf("Template execution error: %v", err)
-	}
+	renderTemplate(w, "ssh-key.html", data)
 }
 
 func GenerateSSHKeyHandler(w http.ResponseWriter, r *http.Request) {
@@ -56,7 +46,53 @@ func GenerateSSHKeyHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// ?download=zip returns the pair as an archive instead of JSON
+	if r.URL.Query().Get("download") == "zip" {
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, keyPair.ArchiveName()))
+		w.Header().Set("Cache-Control", "no-store")
+		if err := keyPair.WriteArchive(w); err != nil {
+			log.Printf("SSH key archive error: %v", err)
+		}
+		return
+	}
+
 	// Return JSON response
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(keyPair)
-}
\ No newline at end of file
+}
+// InspectSSHKeyHandler reports the type, size, fingerprints and comment of a
+// pasted public key: POST /ssh-key/inspect with {"public_key": "<authorized_keys
+// line>"} or a public_key form field.
+func InspectSSHKeyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		PublicKey string `json:"public_key"`
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, 64*1024)
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Error parsing request", http.StatusBadRequest)
+			return
+		}
+	} else {
+		req.PublicKey = r.FormValue("public_key")
+	}
+	if strings.TrimSpace(req.PublicKey) == "" {
+		http.Error(w, "public_key is required", http.StatusBadRequest)
+		return
+	}
+
+	info, err := services.InspectPublicKey(req.PublicKey)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(info)
+}
@@ -1,26 +1,17 @@
 package handlers
 
-import (
-	"html/template"
-	"net/http"
-)
+import "net/http"
 
 func SurveyHandler(w http.ResponseWriter, r *http.Request) {
+	// Campaign links carry ?source=..., which the form passes on to /feedback
 	data := struct {
-		Title string
+		Title  string
+		Source string
 	}{
-		Title: "Survey - ZeePass Feedback",
-	}
-
-	tmpl, err := template.ParseFiles("templates/survey.html")
-	if err != nil {
-		http.Error(w, "Error loading template", http.StatusInternalServerError)
-		return
+		Title:  "Survey - ZeePass Feedback",
+		Source: sanitizeSurveySource(r.URL.Query().Get("source")),
 	}
 
 	w.Header().Set("Content-Type", "text/html")
-	if err := tmpl.Execute(w, data); err != nil {
-		http.Error(w, "Error executing template", http.StatusInternalServerError)
-		return
-	}
+	renderTemplate(w, "survey.html", data)
 }
\ No newline at end of file
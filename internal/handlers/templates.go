@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+
+	"github.com/anazri/zeepass"
+)
+
+// pageTemplates holds every page template, parsed once from the templates
+// embedded in the binary. Templates are looked up by file name, and shared
+// definitions such as "expiry" are available to all of them.
+var pageTemplates = template.Must(template.ParseFS(zeepass.Templates, "templates/*.html"))
+
+// renderTemplate executes the page template name, e.g. "index.html".
+func renderTemplate(w http.ResponseWriter, name string, data interface{}) {
+	tmpl := pageTemplates.Lookup(name)
+	if tmpl == nil {
+		http.Error(w, "Error loading template", http.StatusInternalServerError)
+		log.Printf("Template error: no template named %q", name)
+		return
+	}
+
+	if err := tmpl.Execute(w, data); err != nil {
+		http.Error(w, "Error executing template", http.StatusInternalServerError)
+		log.Printf("Template execution error: %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// Every view page renders from its template, with the values the handlers
+// pass in escaped.
+func TestViewTemplatesRender(t *testing.T) {
+	const marker = `<b id="injected">`
+	expiry := viewExpiry{Show: true, ExpiresAt: "2030-01-01T00:00:00Z", Views: "1 of 3"}
+	tests := []struct {
+		name string
+		data interface{}
+		want string
+	}{
+		{"view-status.html", viewStatusPage{Title: "Gone", Heading: "Message Not Found", Message: marker, Icon: "expired", Retry: true}, "Message Not Found"},
+		{"view-prompt.html", viewPromptPage{Title: "Protected", Heading: "Enter PIN", Prompt: marker, HintQuestion: marker, PIN: true, Passphrase: true, ViewToken: "tok", Button: "Open", Expiry: expiry}, `name="view_token" value="tok"`},
+		{"view-message.html", viewMessagePage{Content: marker, OneTime: true, ViewsLeft: "2 views left", Expiry: expiry}, "2 views left"},
+		{"view-client.html", viewClientPage{ID: "abc123", Expiry: expiry}, "abc123"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			renderTemplate(w, tt.name, tt.data)
+			body := w.Body.String()
+			if w.Code != 200 {
+				t.Fatalf("status %d: %s", w.Code, body)
+			}
+			if !strings.Contains(body, tt.want) {
+				t.Errorf("page does not contain %q", tt.want)
+			}
+			if strings.Contains(body, marker) {
+				t.Errorf("unescaped markup in page")
+			}
+		})
+	}
+}
+
+var hostilePayloads = []string{
+	`</pre><script>alert(1)</script>`,
+	`</script><script>alert(document.cookie)</script>`,
+	`"; alert(1); //`,
+	`<!--<script>`,
+	`<img src=x onerror=alert(1)>`,
+}
+
+// The decrypted text is shown in a <pre> and copied from an inline script,
+// so it must be escaped for both the HTML and the JavaScript context.
+func TestViewMessageEscapesContent(t *testing.T) {
+	messageText := regexp.MustCompile(`const messageText = (.*);`)
+	for _, payload := range hostilePayloads {
+		w := httptest.NewRecorder()
+		renderTemplate(w, "view-message.html", viewMessagePage{
+			Content: payload,
+			Attachment: &viewAttachment{
+				FileName: payload,
+				Size:     "1 KB",
+				URL:      "/download/x",
+			},
+		})
+		body := w.Body.String()
+
+		if n := strings.Count(body, "<script"); n != 2 {
+			t.Errorf("%q: page has %d <script tags, want the 2 of the template", payload, n)
+		}
+		if n := strings.Count(body, "</script>"); n != 2 {
+			t.Errorf("%q: page has %d </script> tags, want 2", payload, n)
+		}
+		if strings.Contains(body, "onerror=") && !strings.Contains(body, "&lt;img src=x onerror=alert(1)&gt;") {
+			t.Errorf("%q: unescaped markup in page", payload)
+		}
+
+		m := messageText.FindStringSubmatch(body)
+		if m == nil {
+			t.Fatalf("%q: copy script not found", payload)
+		}
+		var copied string
+		if err := json.Unmarshal([]byte(m[1]), &copied); err != nil {
+			t.Fatalf("%q: script value %s is not a plain string literal: %v", payload, m[1], err)
+		}
+		if copied != payload {
+			t.Errorf("copy script holds %q, want %q", copied, payload)
+		}
+	}
+}
+
+func TestViewMessageEscapesContentEndToEnd(t *testing.T) {
+	storeTestMessage(t, "xss-benign", "hello", "", "", 1)
+	scripts := strings.Count(submitView("xss-benign", nil).Body.String(), "<script")
+
+	for i, payload := range hostilePayloads {
+		id := "xss-" + string(rune('a'+i))
+		storeTestMessage(t, id, payload, "", "", 1)
+		w := submitView(id, nil)
+		body := w.Body.String()
+		if !strings.Contains(body, "const messageText") {
+			t.Fatalf("%q: decrypted page not shown (status %d)", payload, w.Code)
+		}
+		if n := strings.Count(body, "<script"); n != scripts {
+			t.Errorf("%q: page has %d <script tags, want %d", payload, n, scripts)
+		}
+		if strings.ContainsAny(payload, "<>") && strings.Contains(body, payload) {
+			t.Errorf("%q: payload reached the page unescaped", payload)
+		}
+	}
+}
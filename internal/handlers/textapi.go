@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/anazri/zeepass/internal/models"
+)
+
+// encryptTextRequest is the JSON body accepted by /encrypt-text for
+// programmatic use. MaxViews zero keeps the lifetime's view limit.
+type encryptTextRequest struct {
+	Text        string `json:"text"`
+	PIN         string `json:"pin"`
+	Lifetime    string `json:"lifetime"`
+	TTL         string `json:"ttl"`
+	MaxViews    int    `json:"max_views"`
+	ConfirmRisk bool   `json:"confirm_risk"`
+}
+
+// wantsJSON reports whether a request to an HTML form endpoint should be
+// answered with JSON: it sends a JSON body or asks for JSON in Accept.
+func wantsJSON(r *http.Request) bool {
+	return strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") ||
+		strings.Contains(r.Header.Get("Accept"), "application/json")
+}
+
+// encryptTextJSON stores a text secret from a JSON body and answers with an
+// EncryptionResponse, the scriptable counterpart of the encryption form.
+// Passphrases, security questions and attachments stay form-only.
+func encryptTextJSON(w http.ResponseWriter, r *http.Request) {
+	var req encryptTextRequest
+	// Leave room for JSON escaping of the text
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, int64(maxTextSize())*2+4096))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		writeEncryptionError(w, http.StatusBadRequest, "Malformed JSON payload: "+err.Error())
+		return
+	}
+
+	req.Text = strings.TrimSpace(req.Text)
+	if req.Text == "" {
+		writeEncryptionError(w, http.StatusBadRequest, "text is required")
+		return
+	}
+	if len(req.Text) > maxTextSize() {
+		writeEncryptionError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("text is too large (max %d bytes)", maxTextSize()))
+		return
+	}
+	if len(req.PIN) > 50 {
+		writeEncryptionError(w, http.StatusBadRequest, "pin is too long (max 50 characters)")
+		return
+	}
+	if req.Lifetime == "" {
+		req.Lifetime = "once"
+	}
+
+	if message := lifetimeRiskMessage(req.Lifetime, req.TTL, req.ConfirmRisk); message != "" {
+		writeEncryptionError(w, http.StatusBadRequest, message)
+		return
+	}
+	lifetime, resolved, err := resolveRequestLifetime(req.Lifetime, req.TTL)
+	if err != nil {
+		writeEncryptionError(w, http.StatusBadRequest, "lifetime not allowed: "+err.Error())
+		return
+	}
+	if req.MaxViews != 0 {
+		if message := setMaxViews(&resolved, req.MaxViews); message != "" {
+			writeEncryptionError(w, http.StatusBadRequest, message)
+			return
+		}
+	}
+
+	id, err := storeTextSecret(req.Text, req.PIN, lifetime, resolved)
+	if err != nil {
+		log.Printf("Error storing JSON text secret: %v", err)
+		writeEncryptionError(w, http.StatusInternalServerError, "error storing encrypted data")
+		return
+	}
+	log.Printf("Successfully stored encrypted data for ID: %s", id)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(models.EncryptionResponse{
+		Success: true,
+		ID:      id,
+		URL:     buildShareURL(r, "/view/"+id),
+		Message: "Text encrypted successfully, lifetime: " + getResolvedLifetimeDisplay(lifetime, resolved),
+	})
+}
+
+func writeEncryptionError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(models.EncryptionResponse{Error: message})
+}
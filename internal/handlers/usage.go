@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/anazri/zeepass/internal/services"
+)
+
+// maxUsageBuckets bounds the buckets returned by UsageStatsHandler to what
+// is retained for each window.
+var maxUsageBuckets = map[string]int{"hour": 192, "day": 400}
+
+// CountToolUsage wraps a tool's handler so every request to it is counted
+// for UsageStatsHandler when USAGE_STATS=true.
+func CountToolUsage(tool string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		services.RecordToolUsage(tool)
+		next(w, r)
+	}
+}
+
+// UsageStatsHandler reports how often each tool was used, per hour or per
+// day, for capacity planning, e.g. GET /admin/usage?window=day&buckets=30.
+// It is an admin endpoint, served behind RequireAdmin.
+func UsageStatsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !services.UsageStatsEnabled() {
+		http.Error(w, "Usage stats are disabled (set USAGE_STATS=true)", http.StatusNotFound)
+		return
+	}
+
+	window := r.URL.Query().Get("window")
+	if window == "" {
+		window = "hour"
+	}
+	maxBuckets, ok := maxUsageBuckets[window]
+	if !ok {
+		http.Error(w, "window must be hour or day", http.StatusBadRequest)
+		return
+	}
+	buckets := 24
+	if window == "day" {
+		buckets = 30
+	}
+	if value := r.URL.Query().Get("buckets"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxBuckets {
+			http.Error(w, fmt.Sprintf("buckets must be between 1 and %d", maxBuckets), http.StatusBadRequest)
+			return
+		}
+		buckets = n
+	}
+
+	usage, err := services.ToolUsage(window, buckets, time.Now())
+	if err != nil {
+		http.Error(w, "Error reading usage stats", http.StatusInternalServerError)
+		return
+	}
+	totals := make(map[string]int, len(services.UsageTools))
+	for _, bucket := range usage {
+		for tool, count := range bucket.Counts {
+			totals[tool] += count
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"window":  window,
+		"buckets": usage,
+		"totals":  totals,
+	})
+}
@@ -1,18 +1,94 @@
 package handlers
 
 import (
-	"fmt"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"html/template"
+	"io"
 	"log"
+	"math/rand/v2"
 	"net/http"
+	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/anazri/zeepass/internal/models"
 	"github.com/anazri/zeepass/internal/services"
 )
 
+// viewStatusPage is a simple status page of the view handlers, such as a
+// missing secret or a wrong PIN. Icon is "expired", "viewed" or empty for an
+// error; Retry links back to the form instead of home.
+type viewStatusPage struct {
+	Title   string
+	Heading string
+	Message string
+	Icon    string
+	Retry   bool
+}
+
+var invalidPINPage = viewStatusPage{
+	Title:   "Invalid PIN",
+	Heading: "Invalid PIN",
+	Message: "The PIN you entered is incorrect.",
+	Retry:   true,
+}
+
+// viewPromptPage asks for what is needed to open a protected message or file.
+type viewPromptPage struct {
+	Title        string
+	Heading      string
+	Prompt       string
+	HintQuestion string
+	Passphrase   bool
+	PIN          bool
+	ViewToken    string
+	Button       string
+	Expiry       viewExpiry
+}
+
+// viewMessagePage shows a decrypted message. AttachmentBlocked replaces the
+// download link when the malware scanner rejected the attachment. ViewsLeft
+// tells the viewer of a multi-view message how many reads are left.
+type viewMessagePage struct {
+	Content           string
+	Attachment        *viewAttachment
+	AttachmentBlocked string
+	OneTime           bool
+	ViewsLeft         string
+	Expiry            viewExpiry
+}
+
+// viewClientPage decrypts a client-encrypted message in the browser with the
+// key from the URL fragment.
+type viewClientPage struct {
+	ID     string
+	Expiry viewExpiry
+}
+
+type viewAttachment struct {
+	FileName string
+	Size     string
+	URL      template.URL
+}
+
+// viewExpiry feeds the "expiry" block of templates/view-expiry.html.
+type viewExpiry struct {
+	Show      bool
+	ExpiresAt string
+	Views     string
+}
+
+func renderViewStatus(w http.ResponseWriter, page viewStatusPage) {
+	renderTemplate(w, "view-status.html", page)
+}
+
 func ViewEncryptedHandler(w http.ResponseWriter, r *http.Request) {
+	w = withMinResponseTime(w)
+
 	pathParts := strings.Split(r.URL.Path, "/")
 	if len(pathParts) < 3 {
 		http.NotFound(w, r)
@@ -20,24 +96,20 @@ func ViewEncryptedHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	id := pathParts[2]
 
+	if r.Method == http.MethodHead {
+		handleViewHead(w, r, id)
+		return
+	}
+
 	log.Printf("[%s %s] Attempting to retrieve message with ID: %s", r.Method, r.RemoteAddr, id)
-	data, err := services.GetEncryptedData(id)
+	data, err := services.GetStorage().GetMessage(id)
 	if err != nil {
 		log.Printf("Failed to retrieve message ID %s: %v", id, err)
-		html := `
-		<!DOCTYPE html>
-		<html><head><title>Message Not Found - ZeePass</title>
-		<script src="https://cdn.tailwindcss.com"></script></head>
-		<body class="bg-gray-50 flex items-center justify-center min-h-screen">
-			<div class="bg-white p-8 rounded-lg shadow-md text-center max-w-md">
-				<div class="text-red-500 mb-4"><svg class="w-16 h-16 mx-auto" fill="currentColor" viewBox="0 0 20 20"><path fill-rule="evenodd" d="M18 10a8 8 0 11-16 0 8 8 0 0116 0zm-7 4a1 1 0 11-2 0 1 1 0 012 0zm-1-9a1 1 0 00-1 1v4a1 1 0 102 0V6a1 1 0 00-1-1z" clip-rule="evenodd"/></svg></div>
-				<h2 class="text-2xl font-bold text-gray-800 mb-4">Message Not Found</h2>
-				<p class="text-gray-600 mb-6">This encrypted message does not exist or has expired.</p>
-				<a href="/" class="bg-blue-600 text-white px-6 py-2 rounded-lg hover:bg-blue-700 transition">Go Home</a>
-			</div>
-		</body></html>
-		`
-		w.Write([]byte(html))
+		renderViewStatus(w, viewStatusPage{
+			Title:   "Message Not Found",
+			Heading: "Message Not Found",
+			Message: "This encrypted message does not exist or has expired.",
+		})
 		return
 	}
 
@@ -47,171 +119,500 @@ func ViewEncryptedHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if data.ExpiresAt != nil && time.Now().After(*data.ExpiresAt) {
-		services.DeleteEncryptedData(id)
-		html := `
-		<!DOCTYPE html>
-		<html><head><title>Message Expired - ZeePass</title>
-		<script src="https://cdn.tailwindcss.com"></script></head>
-		<body class="bg-gray-50 flex items-center justify-center min-h-screen">
-			<div class="bg-white p-8 rounded-lg shadow-md text-center max-w-md">
-				<div class="text-orange-500 mb-4"><svg class="w-16 h-16 mx-auto" fill="currentColor" viewBox="0 0 20 20"><path fill-rule="evenodd" d="M10 18a8 8 0 100-16 8 8 0 000 16zm1-12a1 1 0 10-2 0v4a1 1 0 00.293.707l2.828 2.829a1 1 0 101.415-1.415L11 9.586V6z" clip-rule="evenodd"/></svg></div>
-				<h2 class="text-2xl font-bold text-gray-800 mb-4">Message Expired</h2>
-				<p class="text-gray-600 mb-6">This encrypted message has expired and is no longer available.</p>
-				<a href="/" class="bg-blue-600 text-white px-6 py-2 rounded-lg hover:bg-blue-700 transition">Go Home</a>
-			</div>
-		</body></html>
-		`
-		w.Write([]byte(html))
+		services.GetStorage().DeleteMessage(id)
+		renderViewStatus(w, viewStatusPage{
+			Title:   "Message Expired",
+			Heading: "Message Expired",
+			Message: "This encrypted message has expired and is no longer available.",
+			Icon:    "expired",
+		})
 		return
 	}
 
 	if data.ViewCount >= data.MaxViews {
-		services.DeleteEncryptedData(id)
-		html := `
-		<!DOCTYPE html>
-		<html><head><title>Message No Longer Available - ZeePass</title>
-		<script src="https://cdn.tailwindcss.com"></script></head>
-		<body class="bg-gray-50 flex items-center justify-center min-h-screen">
-			<div class="bg-white p-8 rounded-lg shadow-md text-center max-w-md">
-				<div class="text-gray-500 mb-4"><svg class="w-16 h-16 mx-auto" fill="currentColor" viewBox="0 0 20 20"><path d="M10 12a2 2 0 100-4 2 2 0 000 4z"/><path fill-rule="evenodd" d="M.458 10C1.732 5.943 5.522 3 10 3s8.268 2.943 9.542 7c-1.274 4.057-5.064 7-9.542 7S1.732 14.057.458 10zM14 10a4 4 0 11-8 0 4 4 0 018 0z" clip-rule="evenodd"/></svg></div>
-				<h2 class="text-2xl font-bold text-gray-800 mb-4">Message Already Viewed</h2>
-				<p class="text-gray-600 mb-6">This message was configured to be viewed once and has already been accessed.</p>
-				<a href="/" class="bg-blue-600 text-white px-6 py-2 rounded-lg hover:bg-blue-700 transition">Go Home</a>
-			</div>
-		</body></html>
-		`
-		w.Write([]byte(html))
+		services.GetStorage().DeleteMessage(id)
+		showMessageAlreadyViewed(w)
 		return
 	}
 
-	if data.PIN != "" {
-		html := fmt.Sprintf(`
-		<!DOCTYPE html>
-		<html><head><title>Enter PIN - ZeePass</title>
-		<script src="https://cdn.tailwindcss.com"></script></head>
-		<body class="bg-gray-50 flex items-center justify-center min-h-screen">
-			<div class="bg-white p-8 rounded-lg shadow-md max-w-md w-full">
-				<div class="text-center mb-6">
-					<div class="w-16 h-16 bg-blue-100 rounded-full flex items-center justify-center mx-auto mb-4">
-						<svg class="w-8 h-8 text-blue-600" fill="currentColor" viewBox="0 0 20 20">
-							<path fill-rule="evenodd" d="M5 9V7a5 5 0 0110 0v2a2 2 0 012 2v5a2 2 0 01-2 2H5a2 2 0 01-2-2v-5a2 2 0 012-2zm8-2v2H7V7a3 3 0 016 0z" clip-rule="evenodd"/>
-						</svg>
-					</div>
-					<h2 class="text-2xl font-bold text-gray-800 mb-2">Protected Message</h2>
-					<p class="text-gray-600">This message is protected with a PIN. Enter the PIN to view the content.</p>
-				</div>
-				<form method="POST">
-					<div class="mb-4">
-						<label class="block text-sm font-medium text-gray-700 mb-2">PIN</label>
-						<input type="password" name="pin" required class="w-full px-3 py-2 border border-gray-300 rounded-lg focus:ring-2 focus:ring-blue-500 focus:border-transparent outline-none" placeholder="Enter PIN">
-					</div>
-					<button type="submit" class="w-full bg-blue-600 text-white py-2 rounded-lg hover:bg-blue-700 transition">View Message</button>
-				</form>
-			</div>
-		</body></html>
-		`)
-		w.Write([]byte(html))
-		return
-	}
-
-	showDecryptedMessageWithData(w, r, id, data)
+	if data.ClientEncrypted {
+		// The page fetches and decrypts the blob itself, so opening it does
+		// not count a view
+		w.Header().Set("Cache-Control", "no-store")
+		renderTemplate(w, "view-client.html", viewClientPage{
+			ID:     id,
+			Expiry: expiryInfo(data.ExpiresAt, data.MaxViews, data.ViewCount),
+		})
+		return
+	}
+
+	if data.PIN != "" || data.PassphraseSalt != "" || data.HintQuestion != "" {
+		page := viewPromptPage{
+			Title:        "Protected Message",
+			Heading:      "Protected Message",
+			Prompt:       "This message is protected with a PIN. Enter the PIN to view the content.",
+			HintQuestion: data.HintQuestion,
+			Passphrase:   data.PassphraseSalt != "",
+			PIN:          data.PIN != "",
+			Button:       "View Message",
+			Expiry:       expiryInfo(data.ExpiresAt, data.MaxViews, data.ViewCount),
+		}
+		if data.HintQuestion != "" {
+			page.Prompt = "Answer the sender's security question to view the content."
+		}
+		if data.PassphraseSalt != "" {
+			page.Prompt = "This message is encrypted with a passphrase. Enter the passphrase to view the content."
+		}
+		if data.PINEachView {
+			w.Header().Set("Cache-Control", "no-store")
+			page.ViewToken = viewTokens.issue(id)
+		}
+		renderTemplate(w, "view-prompt.html", page)
+		return
+	}
+
+	if data.MaxViews == 1 && isLinkPreviewBot(r) {
+		w.Header().Set("Cache-Control", "no-store")
+		renderTemplate(w, "view-prompt.html", viewPromptPage{
+			Title:   "Secret Message",
+			Heading: "Secret Message",
+			Prompt:  "This message can only be viewed once. Reveal it when you are ready to read it.",
+			Button:  "Reveal Message",
+			Expiry:  expiryInfo(data.ExpiresAt, data.MaxViews, data.ViewCount),
+		})
+		return
+	}
+
+	showDecryptedMessageWithData(w, r, id, data, services.GetEncryptionKey())
+}
+
+// linkPreviewAgents are User-Agent fragments of chat apps and social sites
+// that fetch pasted links to build a preview. Matched case-insensitively.
+var linkPreviewAgents = []string{
+	"slackbot", "slack-imgproxy", "whatsapp", "facebookexternalhit", "facebot",
+	"twitterbot", "telegrambot", "discordbot", "linkedinbot", "skypeuripreview",
+	"microsoftpreview", "teams", "applebot", "googlebot", "bingbot", "redditbot",
+	"mattermost", "embedly", "iframely", "pinterest", "vkshare", "viber",
+	"snapchat", "signal", "line/", "bitlybot", "preview",
+}
+
+// isLinkPreviewBot reports whether a request looks like a link preview fetch
+// rather than a person opening the link. One-time secrets are not revealed
+// to these on GET, so an unfurled link keeps its single view.
+func isLinkPreviewBot(r *http.Request) bool {
+	agent := strings.ToLower(r.UserAgent())
+	if agent == "" {
+		return true
+	}
+	for _, fragment := range linkPreviewAgents {
+		if strings.Contains(agent, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// handleViewHead reports whether a message link is still usable without
+// consuming a view or returning content. The status is sent in
+// X-ZeePass-Status (active, expired or consumed) and the expiry, if any, in
+// X-ZeePass-Expires. Unknown IDs get a plain 404. Requests are rate limited
+// per client to make enumerating IDs impractical.
+func handleViewHead(w http.ResponseWriter, r *http.Request, id string) {
+	if !viewHeadLimiter.allow(getClientIP(r), viewHeadRateLimit()) {
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+		return
+	}
+
+	data, err := services.GetStorage().GetMessage(id)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	if data.ExpiresAt != nil {
+		w.Header().Set("X-ZeePass-Expires", data.ExpiresAt.UTC().Format(time.RFC3339))
+	}
+
+	switch {
+	case data.ExpiresAt != nil && time.Now().After(*data.ExpiresAt):
+		w.Header().Set("X-ZeePass-Status", "expired")
+		w.WriteHeader(http.StatusGone)
+	case data.ViewCount >= data.MaxViews:
+		w.Header().Set("X-ZeePass-Status", "consumed")
+		w.WriteHeader(http.StatusGone)
+	default:
+		w.Header().Set("X-ZeePass-Status", "active")
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// secretStatus is the JSON body of GET /status/{id}. ViewsRemaining is null
+// for secrets limited only by their lifetime.
+type secretStatus struct {
+	Exists         bool `json:"exists"`
+	Expired        bool `json:"expired"`
+	ViewsRemaining *int `json:"views_remaining"`
+	PINProtected   bool `json:"pin_protected"`
+}
+
+// SecretStatusHandler reports the state of a message link as JSON without
+// decrypting it or counting a view, so senders can check whether it has been
+// read. Unknown IDs get a 404 with exists false. It shares the per-client
+// rate limit of HEAD /view/{id} and, like the view pages, is padded to
+// VIEW_MIN_RESPONSE_MS so timing does not tell whether an ID exists.
+func SecretStatusHandler(w http.ResponseWriter, r *http.Request) {
+	w = withMinResponseTime(w)
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/status/")
+	if id == "" || strings.Contains(id, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	if !viewHeadLimiter.allow(getClientIP(r), viewHeadRateLimit()) {
+		w.Header().Set("Retry-After", "60")
+		http.Error(w, "Too many requests", http.StatusTooManyRequests)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+
+	data, err := services.GetStorage().GetMessage(id)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(secretStatus{})
+		return
+	}
+
+	status := secretStatus{
+		Exists:       true,
+		Expired:      data.ExpiresAt != nil && time.Now().After(*data.ExpiresAt),
+		PINProtected: data.PIN != "",
+	}
+	if data.MaxViews < services.UnlimitedViews {
+		remaining := max(data.MaxViews-data.ViewCount, 0)
+		status.ViewsRemaining = &remaining
+	}
+	json.NewEncoder(w).Encode(status)
+}
+
+// viewHeadRateLimit is the number of status checks each client may make per
+// minute, set with VIEW_HEAD_RATE_LIMIT (default 30).
+func viewHeadRateLimit() int {
+	if n, err := strconv.Atoi(os.Getenv("VIEW_HEAD_RATE_LIMIT")); err == nil && n > 0 {
+		return n
+	}
+	return 30
+}
+
+var viewHeadLimiter = &clientRateLimiter{windows: make(map[string]*rateWindow)}
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+// clientRateLimiter is a fixed-window, per-client request counter. The
+// window is one minute unless period is set.
+type clientRateLimiter struct {
+	mu      sync.Mutex
+	windows map[string]*rateWindow
+	period  time.Duration
+}
+
+// allow counts a request from client and reports whether it is within limit
+// requests per window.
+func (l *clientRateLimiter) allow(client string, limit int) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	period := l.period
+	if period == 0 {
+		period = time.Minute
+	}
+
+	now := time.Now()
+	window := l.windows[client]
+	if window == nil || now.Sub(window.start) >= period {
+		if window == nil && len(l.windows) >= 10000 {
+			// Drop finished windows so the map cannot grow without bound
+			for key, w := range l.windows {
+				if now.Sub(w.start) >= period {
+					delete(l.windows, key)
+				}
+			}
+		}
+		window = &rateWindow{start: now}
+		l.windows[client] = window
+	}
+	window.count++
+	return window.count <= limit
 }
 
 func handleDecryptMessageWithData(w http.ResponseWriter, r *http.Request, id string, data *models.EncryptedData) {
+	// Only the browser holding the key can open these
+	if data.ClientEncrypted {
+		http.Redirect(w, r, r.URL.Path, http.StatusSeeOther)
+		return
+	}
+
+	// Each view of such a message needs a fresh prompt, a resubmitted form
+	// goes back to it
+	if data.PINEachView && !viewTokens.consume(id, r.FormValue("view_token")) {
+		http.Redirect(w, r, r.URL.Path, http.StatusSeeOther)
+		return
+	}
+
 	pin := r.FormValue("pin")
+	guarded := data.PIN != "" || data.HintQuestion != "" || data.PassphraseSalt != ""
+	if guarded && pinLockedOut("message", id) {
+		showTooManyAttempts(w)
+		return
+	}
 
-	if data.PIN != "" && services.HashPIN(pin) != data.PIN {
-		html := `
-		<!DOCTYPE html>
-		<html><head><title>Invalid PIN - ZeePass</title>
-		<script src="https://cdn.tailwindcss.com"></script></head>
-		<body class="bg-gray-50 flex items-center justify-center min-h-screen">
-			<div class="bg-white p-8 rounded-lg shadow-md text-center max-w-md">
-				<div class="text-red-500 mb-4"><svg class="w-16 h-16 mx-auto" fill="currentColor" viewBox="0 0 20 20"><path fill-rule="evenodd" d="M18 10a8 8 0 11-16 0 8 8 0 0116 0zm-7 4a1 1 0 11-2 0 1 1 0 012 0zm-1-9a1 1 0 00-1 1v4a1 1 0 102 0V6a1 1 0 00-1-1z" clip-rule="evenodd"/></svg></div>
-				<h2 class="text-2xl font-bold text-gray-800 mb-4">Invalid PIN</h2>
-				<p class="text-gray-600 mb-6">The PIN you entered is incorrect.</p>
-				<a href="javascript:history.back()" class="bg-blue-600 text-white px-6 py-2 rounded-lg hover:bg-blue-700 transition">Try Again</a>
-			</div>
-		</body></html>
-		`
-		w.Write([]byte(html))
+	if data.PIN != "" && !services.VerifyPIN(pin, data.PIN) {
+		if recordFailedPIN("message", id, data.ExpiresAt) {
+			showAttemptsExhausted(w, "message")
+			return
+		}
+		renderViewStatus(w, invalidPINPage)
 		return
 	}
 
-	showDecryptedMessageWithData(w, r, id, data)
+	if data.HintQuestion != "" && !services.VerifyHintAnswer(r.FormValue("hint_answer"), data.HintAnswerHash) {
+		if recordFailedPIN("message", id, data.ExpiresAt) {
+			showAttemptsExhausted(w, "message")
+			return
+		}
+		renderViewStatus(w, viewStatusPage{
+			Title:   "Incorrect Answer",
+			Heading: "Incorrect Answer",
+			Message: "The answer to the security question is incorrect.",
+			Retry:   true,
+		})
+		return
+	}
+	if guarded && data.PassphraseSalt == "" {
+		clearFailedPINs("message", id)
+	}
+
+	key := services.GetEncryptionKey()
+	if data.PassphraseSalt != "" {
+		salt, err := base64.StdEncoding.DecodeString(data.PassphraseSalt)
+		if err == nil {
+			key, err = services.DeriveKeyFromPassphrase(r.FormValue("passphrase"), salt)
+		}
+		if err != nil {
+			showIncorrectPassphrase(w)
+			return
+		}
+	}
+
+	showDecryptedMessageWithData(w, r, id, data, key)
+}
+
+// showIncorrectPassphrase is shown when the passphrase fails to decrypt a
+// message. The view is not counted so the recipient can try again.
+func showIncorrectPassphrase(w http.ResponseWriter) {
+	renderViewStatus(w, viewStatusPage{
+		Title:   "Incorrect Passphrase",
+		Heading: "Incorrect Passphrase",
+		Message: "The passphrase you entered could not decrypt this message.",
+		Retry:   true,
+	})
+}
+
+func showDecryptedMessageWithData(w http.ResponseWriter, r *http.Request, id string, data *models.EncryptedData, key []byte) {
+	// Decrypt before counting the view so a wrong passphrase does not burn it
+	decryptedText, err := services.Decrypt(data.Content, key, id)
+	if err != nil {
+		if data.PassphraseSalt != "" {
+			// Wrong passphrases count towards the lockout like wrong PINs,
+			// which also bounds the scrypt work a guesser can cause
+			if recordFailedPIN("message", id, data.ExpiresAt) {
+				showAttemptsExhausted(w, "message")
+				return
+			}
+			showIncorrectPassphrase(w)
+			return
+		}
+		http.Error(w, "Error decrypting message", http.StatusInternalServerError)
+		return
+	}
+	if data.PassphraseSalt != "" {
+		clearFailedPINs("message", id)
+	}
+	decryptedText, err = decompressMessageText(id, data, decryptedText)
+	if err != nil {
+		http.Error(w, "Error decrypting message", http.StatusInternalServerError)
+		return
+	}
+
+	page := viewMessagePage{
+		Content: decryptedText,
+		OneTime: data.MaxViews == 1,
+	}
+	if data.Attachment != nil {
+		attachment, err := services.DecryptFile(data.Attachment.Content, key, attachmentAAD(id))
+		if err != nil {
+			log.Printf("Error decrypting attachment of message %s: %v", id, err)
+			http.Error(w, "Error decrypting message", http.StatusInternalServerError)
+			return
+		}
+		page.Attachment = attachmentDownload(data.Attachment, attachment)
+		if scanner := services.DownloadScanner(); scanner != nil {
+			page.AttachmentBlocked = scanFile(scanner, bytes.NewReader(attachment), data.Attachment.FileName)
+		}
+		services.WipeBytes(attachment)
+	}
+
+	// Claim the view atomically before revealing anything, so concurrent
+	// requests cannot both read a one-time secret. Decryption comes first
+	// only so that a wrong passphrase does not consume a view.
+	permitted, err := claimMessageView(id, data)
+	if err != nil {
+		http.Error(w, "Error retrieving message", http.StatusInternalServerError)
+		return
+	}
+	if !permitted {
+		showMessageAlreadyViewed(w)
+		return
+	}
+
+	page.Expiry = expiryInfo(data.ExpiresAt, data.MaxViews, data.ViewCount)
+	if data.MaxViews > 1 && data.MaxViews < services.UnlimitedViews {
+		page.ViewsLeft = viewsRemainingNotice(data.MaxViews-data.ViewCount, "message")
+	}
+
+	// Keep the decrypted page out of browser caches and history
+	w.Header().Set("Cache-Control", "no-store")
+	renderTemplate(w, "view-message.html", page)
+}
+
+// decompressMessageText inflates the decrypted text of a compressed message.
+// Other messages are returned unchanged.
+func decompressMessageText(id string, data *models.EncryptedData, text string) (string, error) {
+	if !data.Compressed {
+		return text, nil
+	}
+	raw := []byte(text)
+	inflated, err := services.DecompressText(raw, int64(data.OriginalSize))
+	services.WipeBytes(raw)
+	if err != nil {
+		log.Printf("Error decompressing message %s: %v", id, err)
+		return "", err
+	}
+	text = string(inflated)
+	services.WipeBytes(inflated)
+	return text, nil
 }
 
-func showDecryptedMessageWithData(w http.ResponseWriter, r *http.Request, id string, data *models.EncryptedData) {
-	data.ViewCount++
+// claimMessageView counts a view of a message and reports whether it was
+// still available. One-time secrets are burned with a single GETDEL; others
+// are deleted once they reach their view limit.
+func claimMessageView(id string, data *models.EncryptedData) (bool, error) {
+	if data.MaxViews == 1 {
+		if _, err := services.ConsumeEncryptedData(id); err != nil {
+			if err != services.ErrNotFound {
+				log.Printf("Error consuming message %s: %v", id, err)
+				return false, err
+			}
+			return false, nil
+		}
+		data.ViewCount = 1
+		return true, nil
+	}
+
+	views, permitted, err := services.GetStorage().ClaimMessageView(id, data)
+	if err != nil {
+		log.Printf("Error recording view of message %s: %v", id, err)
+		return false, err
+	}
+	if !permitted {
+		return false, nil
+	}
+	data.ViewCount = views
 
 	if data.ViewCount >= data.MaxViews {
-		err := services.DeleteEncryptedData(id)
+		err := services.GetStorage().DeleteMessage(id)
 		if err != nil {
 			log.Printf("Error deleting message after max views: %v", err)
 		}
 	} else {
-		err := services.StoreEncryptedData(id, data)
+		err := services.GetStorage().StoreMessage(id, data)
 		if err != nil {
 			log.Printf("Error updating view count in Redis: %v", err)
 		}
 	}
+	return true, nil
+}
 
-	decryptedText, err := services.Decrypt(data.Content, services.GetEncryptionKey())
-	if err != nil {
-		http.Error(w, "Error decrypting message", http.StatusInternalServerError)
-		return
+// attachmentDownload describes the download link for a note's decrypted
+// attachment. The file is embedded in the page as a data URI so it is
+// released together with the note and needs no second request.
+func attachmentDownload(meta *models.EncryptedAttachment, content []byte) *viewAttachment {
+	mimeType := meta.MimeType
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	return &viewAttachment{
+		FileName: meta.FileName,
+		Size:     formatFileSize(meta.FileSize),
+		URL:      template.URL("data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(content)),
 	}
+}
 
-	html := fmt.Sprintf(`
-	<!DOCTYPE html>
-	<html><head><title>Encrypted Message - ZeePass</title>
-	<script src="https://cdn.tailwindcss.com"></script></head>
-	<body class="bg-gray-50 min-h-screen py-8">
-		<div class="max-w-4xl mx-auto px-4">
-			<div class="bg-white rounded-lg shadow-md overflow-hidden">
-				<div class="bg-green-500 text-white p-4">
-					<div class="flex items-center space-x-2">
-						<svg class="w-6 h-6" fill="currentColor" viewBox="0 0 20 20"><path fill-rule="evenodd" d="M5 9V7a5 5 0 0110 0v2a2 2 0 012 2v5a2 2 0 01-2 2H5a2 2 0 01-2-2v-5a2 2 0 012-2zm8-2v2H7V7a3 3 0 016 0z" clip-rule="evenodd"/></svg>
-						<h1 class="text-xl font-bold">Decrypted Message</h1>
-					</div>
-				</div>
-				<div class="p-6">
-					<div class="mb-4">
-						<label class="block text-sm font-medium text-gray-700 mb-2">Message Content</label>
-						<div class="bg-gray-50 p-4 rounded-lg border">
-							<pre class="whitespace-pre-wrap text-gray-800">%s</pre>
-						</div>
-					</div>
-					%s
-					<div class="flex justify-between items-center mt-6">
-						<button onclick="copyMessage()" class="bg-blue-600 text-white px-4 py-2 rounded-lg hover:bg-blue-700 transition">Copy Message</button>
-						<a href="/" class="bg-gray-600 text-white px-4 py-2 rounded-lg hover:bg-gray-700 transition">Create New Message</a>
-					</div>
-				</div>
-			</div>
-		</div>
-		<script>
-			function copyMessage() {
-				const messageText = %s;
-				navigator.clipboard.writeText(messageText).then(() => {
-					alert('Message copied to clipboard!');
-				});
-			}
-		</script>
-	</body></html>
-	`, decryptedText, getWarningMessage(data), strconv.Quote(decryptedText))
+// showMessageAlreadyViewed is shown once a message has used up its views.
+func showMessageAlreadyViewed(w http.ResponseWriter) {
+	renderViewStatus(w, viewStatusPage{
+		Title:   "Message No Longer Available",
+		Heading: "Message Already Viewed",
+		Message: "This message was configured to be viewed once and has already been accessed.",
+		Icon:    "viewed",
+	})
+}
 
-	w.Write([]byte(html))
+// viewsRemainingNotice describes how many more views a secret allows after
+// the current one, e.g. "2 views remaining".
+func viewsRemainingNotice(remaining int, kind string) string {
+	switch {
+	case remaining <= 0:
+		return "This was the last view, the " + kind + " has now been destroyed."
+	case remaining == 1:
+		return "1 view remaining before the " + kind + " is destroyed."
+	default:
+		return strconv.Itoa(remaining) + " views remaining before the " + kind + " is destroyed."
+	}
 }
 
-func getWarningMessage(data *models.EncryptedData) string {
-	if data.MaxViews == 1 {
-		return `<div class="bg-red-100 border border-red-400 text-red-700 px-4 py-3 rounded mb-4">⚠️ <strong>Warning:</strong> This message will be permanently deleted after viewing.</div>`
+// expiryInfo describes the expiry time and remaining views of a secret. The
+// expiry is passed as a UTC RFC 3339 timestamp and the browser converts it to
+// local time and ticks the countdown down. Set SHOW_EXPIRY_COUNTDOWN=false to
+// hide it.
+func expiryInfo(expiresAt *time.Time, maxViews, viewCount int) viewExpiry {
+	if os.Getenv("SHOW_EXPIRY_COUNTDOWN") == "false" {
+		return viewExpiry{}
+	}
+
+	info := viewExpiry{Show: true, Views: "Unlimited"}
+	if maxViews < services.UnlimitedViews {
+		info.Views = strconv.Itoa(max(maxViews-viewCount, 0))
 	}
-	return ""
+	if expiresAt != nil {
+		info.ExpiresAt = expiresAt.UTC().Format(time.RFC3339)
+	}
+	return info
 }
 
 func ViewEncryptedFileHandler(w http.ResponseWriter, r *http.Request) {
+	w = withMinResponseTime(w)
+
 	pathParts := strings.Split(r.URL.Path, "/")
 	if len(pathParts) < 3 {
 		http.NotFound(w, r)
@@ -220,23 +621,14 @@ func ViewEncryptedFileHandler(w http.ResponseWriter, r *http.Request) {
 	id := pathParts[2]
 
 	log.Printf("[%s %s] Attempting to retrieve file with ID: %s", r.Method, r.RemoteAddr, id)
-	data, err := services.GetEncryptedFileData(id)
+	data, err := services.GetStorage().GetFile(id)
 	if err != nil {
 		log.Printf("Failed to retrieve file ID %s: %v", id, err)
-		html := `
-		<!DOCTYPE html>
-		<html><head><title>File Not Found - ZeePass</title>
-		<script src="https://cdn.tailwindcss.com"></script></head>
-		<body class="bg-gray-50 flex items-center justify-center min-h-screen">
-			<div class="bg-white p-8 rounded-lg shadow-md text-center max-w-md">
-				<div class="text-red-500 mb-4"><svg class="w-16 h-16 mx-auto" fill="currentColor" viewBox="0 0 20 20"><path fill-rule="evenodd" d="M18 10a8 8 0 11-16 0 8 8 0 0116 0zm-7 4a1 1 0 11-2 0 1 1 0 012 0zm-1-9a1 1 0 00-1 1v4a1 1 0 102 0V6a1 1 0 00-1-1z" clip-rule="evenodd"/></svg></div>
-				<h2 class="text-2xl font-bold text-gray-800 mb-4">File Not Found</h2>
-				<p class="text-gray-600 mb-6">This encrypted file does not exist or has expired.</p>
-				<a href="/" class="bg-blue-600 text-white px-6 py-2 rounded-lg hover:bg-blue-700 transition">Go Home</a>
-			</div>
-		</body></html>
-		`
-		w.Write([]byte(html))
+		renderViewStatus(w, viewStatusPage{
+			Title:   "File Not Found",
+			Heading: "File Not Found",
+			Message: "This encrypted file does not exist or has expired.",
+		})
 		return
 	}
 
@@ -246,126 +638,200 @@ func ViewEncryptedFileHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if data.ExpiresAt != nil && time.Now().After(*data.ExpiresAt) {
-		services.DeleteEncryptedFileData(id)
-		html := `
-		<!DOCTYPE html>
-		<html><head><title>File Expired - ZeePass</title>
-		<script src="https://cdn.tailwindcss.com"></script></head>
-		<body class="bg-gray-50 flex items-center justify-center min-h-screen">
-			<div class="bg-white p-8 rounded-lg shadow-md text-center max-w-md">
-				<div class="text-orange-500 mb-4"><svg class="w-16 h-16 mx-auto" fill="currentColor" viewBox="0 0 20 20"><path fill-rule="evenodd" d="M10 18a8 8 0 100-16 8 8 0 000 16zm1-12a1 1 0 10-2 0v4a1 1 0 00.293.707l2.828 2.829a1 1 0 101.415-1.415L11 9.586V6z" clip-rule="evenodd"/></svg></div>
-				<h2 class="text-2xl font-bold text-gray-800 mb-4">File Expired</h2>
-				<p class="text-gray-600 mb-6">This encrypted file has expired and is no longer available.</p>
-				<a href="/" class="bg-blue-600 text-white px-6 py-2 rounded-lg hover:bg-blue-700 transition">Go Home</a>
-			</div>
-		</body></html>
-		`
-		w.Write([]byte(html))
+		services.GetStorage().DeleteFile(id)
+		renderViewStatus(w, viewStatusPage{
+			Title:   "File Expired",
+			Heading: "File Expired",
+			Message: "This encrypted file has expired and is no longer available.",
+			Icon:    "expired",
+		})
 		return
 	}
 
 	if data.ViewCount >= data.MaxViews {
-		services.DeleteEncryptedFileData(id)
-		html := `
-		<!DOCTYPE html>
-		<html><head><title>File No Longer Available - ZeePass</title>
-		<script src="https://cdn.tailwindcss.com"></script></head>
-		<body class="bg-gray-50 flex items-center justify-center min-h-screen">
-			<div class="bg-white p-8 rounded-lg shadow-md text-center max-w-md">
-				<div class="text-gray-500 mb-4"><svg class="w-16 h-16 mx-auto" fill="currentColor" viewBox="0 0 20 20"><path d="M10 12a2 2 0 100-4 2 2 0 000 4z"/><path fill-rule="evenodd" d="M.458 10C1.732 5.943 5.522 3 10 3s8.268 2.943 9.542 7c-1.274 4.057-5.064 7-9.542 7S1.732 14.057.458 10zM14 10a4 4 0 11-8 0 4 4 0 018 0z" clip-rule="evenodd"/></svg></div>
-				<h2 class="text-2xl font-bold text-gray-800 mb-4">File Already Downloaded</h2>
-				<p class="text-gray-600 mb-6">This file was configured to be downloaded once and has already been accessed.</p>
-				<a href="/" class="bg-blue-600 text-white px-6 py-2 rounded-lg hover:bg-blue-700 transition">Go Home</a>
-			</div>
-		</body></html>
-		`
-		w.Write([]byte(html))
+		services.GetStorage().DeleteFile(id)
+		showFileAlreadyDownloaded(w)
 		return
 	}
 
 	if data.PIN != "" {
-		html := fmt.Sprintf(`
-		<!DOCTYPE html>
-		<html><head><title>Enter PIN - ZeePass</title>
-		<script src="https://cdn.tailwindcss.com"></script></head>
-		<body class="bg-gray-50 flex items-center justify-center min-h-screen">
-			<div class="bg-white p-8 rounded-lg shadow-md max-w-md w-full">
-				<div class="text-center mb-6">
-					<div class="w-16 h-16 bg-blue-100 rounded-full flex items-center justify-center mx-auto mb-4">
-						<svg class="w-8 h-8 text-blue-600" fill="currentColor" viewBox="0 0 20 20">
-							<path fill-rule="evenodd" d="M5 9V7a5 5 0 0110 0v2a2 2 0 012 2v5a2 2 0 01-2 2H5a2 2 0 01-2-2v-5a2 2 0 012-2zm8-2v2H7V7a3 3 0 016 0z" clip-rule="evenodd"/>
-						</svg>
-					</div>
-					<h2 class="text-2xl font-bold text-gray-800 mb-2">Protected File</h2>
-					<p class="text-gray-600">This file is protected with a PIN. Enter the PIN to download the file.</p>
-				</div>
-				<form method="POST">
-					<div class="mb-4">
-						<label class="block text-sm font-medium text-gray-700 mb-2">PIN</label>
-						<input type="password" name="pin" required class="w-full px-3 py-2 border border-gray-300 rounded-lg focus:ring-2 focus:ring-blue-500 focus:border-transparent outline-none" placeholder="Enter PIN">
-					</div>
-					<button type="submit" class="w-full bg-blue-600 text-white py-2 rounded-lg hover:bg-blue-700 transition">Download File</button>
-				</form>
-			</div>
-		</body></html>
-		`)
-		w.Write([]byte(html))
+		renderTemplate(w, "view-prompt.html", viewPromptPage{
+			Title:   "Enter PIN",
+			Heading: "Protected File",
+			Prompt:  "This file is protected with a PIN. Enter the PIN to download the file.",
+			PIN:     true,
+			Button:  "Download File",
+			Expiry:  expiryInfo(data.ExpiresAt, data.MaxViews, data.ViewCount),
+		})
+		return
+	}
+
+	if data.MaxViews == 1 && isLinkPreviewBot(r) {
+		w.Header().Set("Cache-Control", "no-store")
+		renderTemplate(w, "view-prompt.html", viewPromptPage{
+			Title:   "Secret File",
+			Heading: "Secret File",
+			Prompt:  "This file can only be downloaded once. Download it when you are ready to save it.",
+			Button:  "Download File",
+			Expiry:  expiryInfo(data.ExpiresAt, data.MaxViews, data.ViewCount),
+		})
 		return
 	}
 
 	downloadDecryptedFileWithData(w, r, id, data)
 }
 
+// showFileAlreadyDownloaded is shown once a file has used up its downloads.
+func showFileAlreadyDownloaded(w http.ResponseWriter) {
+	renderViewStatus(w, viewStatusPage{
+		Title:   "File No Longer Available",
+		Heading: "File Already Downloaded",
+		Message: "This file was configured to be downloaded once and has already been accessed.",
+		Icon:    "viewed",
+	})
+}
+
 func handleDecryptFileWithData(w http.ResponseWriter, r *http.Request, id string, data *models.EncryptedFileData) {
 	pin := r.FormValue("pin")
 
-	if data.PIN != "" && services.HashPIN(pin) != data.PIN {
-		html := `
-		<!DOCTYPE html>
-		<html><head><title>Invalid PIN - ZeePass</title>
-		<script src="https://cdn.tailwindcss.com"></script></head>
-		<body class="bg-gray-50 flex items-center justify-center min-h-screen">
-			<div class="bg-white p-8 rounded-lg shadow-md text-center max-w-md">
-				<div class="text-red-500 mb-4"><svg class="w-16 h-16 mx-auto" fill="currentColor" viewBox="0 0 20 20"><path fill-rule="evenodd" d="M18 10a8 8 0 11-16 0 8 8 0 0116 0zm-7 4a1 1 0 11-2 0 1 1 0 012 0zm-1-9a1 1 0 00-1 1v4a1 1 0 102 0V6a1 1 0 00-1-1z" clip-rule="evenodd"/></svg></div>
-				<h2 class="text-2xl font-bold text-gray-800 mb-4">Invalid PIN</h2>
-				<p class="text-gray-600 mb-6">The PIN you entered is incorrect.</p>
-				<a href="javascript:history.back()" class="bg-blue-600 text-white px-6 py-2 rounded-lg hover:bg-blue-700 transition">Try Again</a>
-			</div>
-		</body></html>
-		`
-		w.Write([]byte(html))
-		return
+	if data.PIN != "" {
+		if pinLockedOut("file", id) {
+			showTooManyAttempts(w)
+			return
+		}
+		if !services.VerifyPIN(pin, data.PIN) {
+			if recordFailedPIN("file", id, data.ExpiresAt) {
+				showAttemptsExhausted(w, "file")
+				return
+			}
+			renderViewStatus(w, invalidPINPage)
+			return
+		}
+		clearFailedPINs("file", id)
 	}
 
 	downloadDecryptedFileWithData(w, r, id, data)
 }
 
 func downloadDecryptedFileWithData(w http.ResponseWriter, r *http.Request, id string, data *models.EncryptedFileData) {
-	data.ViewCount++
+	// Scan before the download is claimed so a blocked file keeps its views
+	if scanner := services.DownloadScanner(); scanner != nil {
+		if message := scanStoredFile(scanner, id, data); message != "" {
+			showFileBlocked(w, message)
+			return
+		}
+	}
+
+	// Claim the download atomically so concurrent requests cannot both
+	// download a one-time file
+	views, permitted, err := services.GetStorage().ClaimFileView(id, data)
+	if err != nil {
+		log.Printf("Error recording download of file %s: %v", id, err)
+		http.Error(w, "Error retrieving file", http.StatusInternalServerError)
+		return
+	}
+	if !permitted {
+		showFileAlreadyDownloaded(w)
+		return
+	}
+	data.ViewCount = views
+	if data.MaxViews < services.UnlimitedViews {
+		w.Header().Set("X-ZeePass-Views-Remaining", strconv.Itoa(max(data.MaxViews-data.ViewCount, 0)))
+	}
+
+	if !serveFileDownload(w, r, id, data) {
+		if err := services.GetStorage().ReleaseFileView(id); err != nil {
+			log.Printf("Error releasing download of file %s: %v", id, err)
+		}
+		return
+	}
 
 	if data.ViewCount >= data.MaxViews {
-		err := services.DeleteEncryptedFileData(id)
+		err := services.GetStorage().DeleteFile(id)
 		if err != nil {
 			log.Printf("Error deleting file after max views: %v", err)
 		}
 	} else {
-		err := services.StoreEncryptedFileData(id, data)
+		err := services.GetStorage().StoreFile(id, data)
 		if err != nil {
 			log.Printf("Error updating view count in Redis: %v", err)
 		}
 	}
+}
 
-	decryptedData, err := services.DecryptFile(data.Content, services.GetEncryptionKey())
+// scanStoredFile decrypts a stored file into scanner. Stream ciphertexts are
+// piped through chunk by chunk so the plaintext is never held in full.
+func scanStoredFile(scanner services.FileScanner, id string, data *models.EncryptedFileData) string {
+	if services.IsStreamCiphertext(data.Content) {
+		pr, pw := io.Pipe()
+		go func() {
+			pw.CloseWithError(services.DecryptStream(pw, bytes.NewReader(data.Content), services.GetEncryptionKey(), id))
+		}()
+		message := scanFile(scanner, pr, data.FileName)
+		pr.Close()
+		return message
+	}
+
+	decryptedData, err := services.DecryptFile(data.Content, services.GetEncryptionKey(), id)
 	if err != nil {
-		http.Error(w, "Error decrypting file", http.StatusInternalServerError)
-		return
+		log.Printf("Error decrypting file %s for scanning: %v", id, err)
+		return "The file could not be checked for malware, please try again later"
 	}
+	defer services.WipeBytes(decryptedData)
+	return scanFile(scanner, bytes.NewReader(decryptedData), data.FileName)
+}
 
-	// Set headers for file download
-	w.Header().Set("Content-Type", data.MimeType)
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", data.FileName))
-	w.Header().Set("Content-Length", fmt.Sprintf("%d", len(decryptedData)))
+// showFileBlocked is shown instead of a download the malware scanner rejected.
+func showFileBlocked(w http.ResponseWriter, message string) {
+	renderViewStatus(w, viewStatusPage{
+		Title:   "Download Blocked",
+		Heading: "Download Blocked",
+		Message: message,
+	})
+}
 
-	// Write file data
-	w.Write(decryptedData)
-}
\ No newline at end of file
+// withMinResponseTime holds back the response until VIEW_MIN_RESPONSE_MS
+// (plus up to VIEW_RESPONSE_JITTER_MS of random jitter, default a tenth of the
+// minimum) has passed since the request started. Padding every response to
+// the same duration hides whether an ID exists or a PIN was correct from
+// timing. It is off unless VIEW_MIN_RESPONSE_MS is set.
+func withMinResponseTime(w http.ResponseWriter) http.ResponseWriter {
+	minimum, err := strconv.Atoi(os.Getenv("VIEW_MIN_RESPONSE_MS"))
+	if err != nil || minimum <= 0 {
+		return w
+	}
+	jitter, err := strconv.Atoi(os.Getenv("VIEW_RESPONSE_JITTER_MS"))
+	if err != nil || jitter < 0 {
+		jitter = minimum / 10
+	}
+
+	delay := time.Duration(minimum) * time.Millisecond
+	if jitter > 0 {
+		delay += rand.N(time.Duration(jitter) * time.Millisecond)
+	}
+	return &delayedResponseWriter{ResponseWriter: w, deadline: time.Now().Add(delay)}
+}
+
+// delayedResponseWriter sleeps until deadline before the first header or
+// body byte is written.
+type delayedResponseWriter struct {
+	http.ResponseWriter
+	deadline time.Time
+	once     sync.Once
+}
+
+func (d *delayedResponseWriter) wait() {
+	d.once.Do(func() {
+		time.Sleep(time.Until(d.deadline))
+	})
+}
+
+func (d *delayedResponseWriter) WriteHeader(statusCode int) {
+	d.wait()
+	d.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (d *delayedResponseWriter) Write(p []byte) (int, error) {
+	d.wait()
+	return d.ResponseWriter.Write(p)
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSecretStatusPaddedToMinResponseTime(t *testing.T) {
+	t.Setenv("VIEW_MIN_RESPONSE_MS", "50")
+	t.Setenv("VIEW_RESPONSE_JITTER_MS", "")
+	viewHeadLimiter.windows = make(map[string]*rateWindow)
+
+	start := time.Now()
+	w := httptest.NewRecorder()
+	SecretStatusHandler(w, httptest.NewRequest(http.MethodGet, "/status/missing", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404", w.Code)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("unknown ID answered after %v, want at least 50ms", elapsed)
+	}
+}
+
+func TestViewHeadRateLimit(t *testing.T) {
+	t.Setenv("VIEW_HEAD_RATE_LIMIT", "2")
+	t.Setenv("VIEW_MIN_RESPONSE_MS", "")
+	viewHeadLimiter.windows = make(map[string]*rateWindow)
+
+	head := func(remoteAddr string) int {
+		r := httptest.NewRequest(http.MethodHead, "/view/missing", nil)
+		r.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		ViewEncryptedHandler(w, r)
+		return w.Code
+	}
+	for i := 0; i < 2; i++ {
+		if code := head("203.0.113.7:5000"); code != http.StatusNotFound {
+			t.Fatalf("check %d got %d, want 404", i+1, code)
+		}
+	}
+	if code := head("203.0.113.7:5000"); code != http.StatusTooManyRequests {
+		t.Fatalf("third check got %d, want 429", code)
+	}
+	if code := head("203.0.113.8:5000"); code != http.StatusNotFound {
+		t.Errorf("another client got %d, want 404", code)
+	}
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// viewTokenTTL is how long a PIN prompt stays valid for messages that ask for
+// the PIN on every view.
+const viewTokenTTL = 10 * time.Minute
+
+// maxViewTokens caps the number of outstanding tokens. Once it is reached the
+// oldest token is dropped for each new one, so reloading prompts in a loop
+// cannot grow the store without bound.
+const maxViewTokens = 10000
+
+// viewTokenSweepInterval is how often expired tokens are purged.
+const viewTokenSweepInterval = time.Minute
+
+type viewToken struct {
+	id      string
+	expires time.Time
+}
+
+// viewTokenStore hands out single-use tokens with each PIN prompt of a
+// message created with "ask for the PIN on every view". A view must present
+// an unused token, so refreshing or resubmitting the decrypted page leads back
+// to the prompt instead of reusing the PIN the browser sent before. Tokens
+// are kept in memory, so the prompt and the submission must reach the same
+// instance.
+type viewTokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]viewToken
+	order  []string // Tokens in the order they were issued, oldest first; may include spent ones
+	max    int
+}
+
+var viewTokens = newViewTokenStore(maxViewTokens)
+
+// newViewTokenStore returns an empty store holding at most max tokens and
+// starts the sweeper that removes expired ones.
+func newViewTokenStore(max int) *viewTokenStore {
+	s := &viewTokenStore{tokens: make(map[string]viewToken), max: max}
+	go s.sweep(viewTokenSweepInterval)
+	return s
+}
+
+// issue returns a new token for one view of message id.
+func (s *viewTokenStore) issue(id string) string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	token := hex.EncodeToString(b)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.tokens) >= s.max && len(s.order) > 0 {
+		delete(s.tokens, s.order[0])
+		s.order = s.order[1:]
+	}
+	if len(s.order) > 2*s.max {
+		s.compact()
+	}
+	s.tokens[token] = viewToken{id: id, expires: time.Now().Add(viewTokenTTL)}
+	s.order = append(s.order, token)
+	return token
+}
+
+// consume reports whether token was issued for message id and has not been
+// used or expired. The token is spent either way.
+func (s *viewTokenStore) consume(id, token string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.tokens[token]
+	if !ok {
+		return false
+	}
+	delete(s.tokens, token)
+	return v.id == id && time.Now().Before(v.expires)
+}
+
+// removeExpired drops expired tokens. All tokens live for viewTokenTTL, so
+// they expire in the order they were issued.
+func (s *viewTokenStore) removeExpired() {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.order) > 0 {
+		v, ok := s.tokens[s.order[0]]
+		if ok && now.Before(v.expires) {
+			break
+		}
+		delete(s.tokens, s.order[0])
+		s.order = s.order[1:]
+	}
+	s.compact()
+}
+
+// compact removes spent tokens from the issue order. The caller must hold
+// s.mu.
+func (s *viewTokenStore) compact() {
+	live := make([]string, 0, len(s.tokens))
+	for _, token := range s.order {
+		if _, ok := s.tokens[token]; ok {
+			live = append(live, token)
+		}
+	}
+	s.order = live
+}
+
+func (s *viewTokenStore) sweep(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.removeExpired()
+	}
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestViewTokenSingleUse(t *testing.T) {
+	s := newViewTokenStore(10)
+	token := s.issue("msg")
+	if s.consume("other", token) {
+		t.Fatal("token accepted for another message")
+	}
+	token = s.issue("msg")
+	if !s.consume("msg", token) {
+		t.Fatal("fresh token rejected")
+	}
+	if s.consume("msg", token) {
+		t.Fatal("token accepted twice")
+	}
+}
+
+func TestViewTokenStoreEvictsOldest(t *testing.T) {
+	s := newViewTokenStore(3)
+	var tokens []string
+	for i := 0; i < 5; i++ {
+		tokens = append(tokens, s.issue("msg"))
+	}
+	if len(s.tokens) != 3 {
+		t.Fatalf("store holds %d tokens, want 3", len(s.tokens))
+	}
+	for i, token := range tokens {
+		if got, want := s.consume("msg", token), i >= 2; got != want {
+			t.Errorf("token %d usable = %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestViewTokenStoreSpentTokensDoNotGrowOrder(t *testing.T) {
+	s := newViewTokenStore(3)
+	for i := 0; i < 100; i++ {
+		s.consume("msg", s.issue("msg"))
+	}
+	if len(s.order) > 2*s.max+1 {
+		t.Errorf("issue order holds %d entries", len(s.order))
+	}
+}
+
+func TestViewTokenRemoveExpired(t *testing.T) {
+	s := newViewTokenStore(10)
+	old := s.issue("msg")
+	s.tokens[old] = viewToken{id: "msg", expires: time.Now().Add(-time.Second)}
+	fresh := s.issue("msg")
+	spent := s.issue("msg")
+	s.consume("msg", spent)
+
+	s.removeExpired()
+	if _, ok := s.tokens[old]; ok {
+		t.Error("expired token kept")
+	}
+	if len(s.order) != 1 || s.order[0] != fresh {
+		t.Errorf("order = %v, want only the live token", s.order)
+	}
+}
@@ -0,0 +1,370 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anazri/zeepass/internal/services"
+)
+
+const (
+	// maxIdempotencyKeyLength bounds the Idempotency-Key header.
+	maxIdempotencyKeyLength = 128
+	// idempotencyTTL is how long a webhook response is replayed for a key.
+	idempotencyTTL = 24 * time.Hour
+)
+
+// webhookMessageRequest is the JSON body accepted by WebhookMessageHandler.
+type webhookMessageRequest struct {
+	Text        string `json:"text"`
+	Lifetime    string `json:"lifetime"`
+	PIN         string `json:"pin"`
+	ConfirmRisk bool   `json:"confirm_risk"`
+	// Verify reads the message back after storing it and reports the
+	// outcome as "verified" in the response.
+	Verify bool `json:"verify"`
+	// FailureCallbackURL is notified with a POST when verification fails.
+	FailureCallbackURL string `json:"failure_callback_url"`
+}
+
+// WebhookMessageHandler lets external systems such as CI pipelines create a
+// secure link with POST /api/webhook/messages. Callers authenticate with an
+// API key from WEBHOOK_API_KEYS (Authorization: Bearer <key>) and may send an
+// Idempotency-Key header so a retried request returns the original link
+// instead of creating a second one.
+func WebhookMessageHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	caller, ok := webhookCaller(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="zeepass"`)
+		http.Error(w, "Invalid or missing API key", http.StatusUnauthorized)
+		return
+	}
+
+	if !webhookLimiter.allow(caller, webhookRateLimit()) {
+		w.Header().Set("Retry-After", "60")
+		http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+		return
+	}
+
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		http.Error(w, "Content-Type must be application/json", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	// Leave room for JSON escaping of the text
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, int64(maxTextSize())*2+4096))
+	if err != nil {
+		http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	idempotencyKey := r.Header.Get("Idempotency-Key")
+	if len(idempotencyKey) > maxIdempotencyKeyLength {
+		http.Error(w, "Idempotency-Key is too long", http.StatusBadRequest)
+		return
+	}
+	if idempotencyKey != "" {
+		key := caller + "\x00" + idempotencyKey
+		entry, state := webhookIdempotency.reserve(key, sha256.Sum256(body))
+		switch state {
+		case idempotencyReplay:
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(entry.status)
+			w.Write(entry.body)
+			return
+		case idempotencyMismatch:
+			http.Error(w, "Idempotency-Key was already used with a different payload", http.StatusUnprocessableEntity)
+			return
+		case idempotencyInFlight:
+			http.Error(w, "A request with this Idempotency-Key is in progress", http.StatusConflict)
+			return
+		}
+		rec := &bufferedResponse{header: make(http.Header), status: http.StatusOK}
+		createWebhookMessage(rec, r, caller, body)
+		webhookIdempotency.complete(key, rec)
+		rec.copyTo(w)
+		return
+	}
+
+	createWebhookMessage(w, r, caller, body)
+}
+
+func createWebhookMessage(w http.ResponseWriter, r *http.Request, caller string, body []byte) {
+	var req webhookMessageRequest
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		http.Error(w, "Malformed JSON payload: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if decoder.More() {
+		http.Error(w, "Malformed JSON payload: trailing data", http.StatusBadRequest)
+		return
+	}
+
+	req.Text = strings.TrimSpace(req.Text)
+	if req.Text == "" {
+		http.Error(w, "text is required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Text) > maxTextSize() {
+		http.Error(w, "text is too large (max "+strconv.Itoa(maxTextSize())+" bytes)", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if len(req.PIN) > 50 {
+		http.Error(w, "pin is too long (max 50 characters)", http.StatusBadRequest)
+		return
+	}
+	if req.Lifetime == "" {
+		req.Lifetime = "once"
+	}
+	if req.FailureCallbackURL != "" {
+		if !req.Verify {
+			http.Error(w, "failure_callback_url requires verify", http.StatusBadRequest)
+			return
+		}
+		if err := validateCallbackURL(req.FailureCallbackURL); err != nil {
+			http.Error(w, "failure_callback_url not allowed: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if message := riskConfirmationMessage(req.Lifetime, req.ConfirmRisk); message != "" {
+		http.Error(w, message, http.StatusBadRequest)
+		return
+	}
+	resolved, err := services.ResolveLifetime(req.Lifetime)
+	if err != nil {
+		http.Error(w, "Lifetime not allowed: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id, err := storeTextSecret(req.Text, req.PIN, req.Lifetime, resolved)
+	if err != nil {
+		log.Printf("Error creating webhook message for %s: %v", caller, err)
+		http.Error(w, "Error creating share link", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("Webhook caller %s created message %s", caller, id)
+
+	response := map[string]interface{}{
+		"id":            id,
+		"share_url":     buildShareURL(r, "/view/"+id),
+		"lifetime":      getResolvedLifetimeDisplay(req.Lifetime, resolved),
+		"max_views":     resolved.MaxViews,
+		"pin_protected": req.PIN != "",
+	}
+	if resolved.ExpiresAt != nil {
+		response["expires_at"] = resolved.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+	if req.Verify {
+		err := services.VerifyMessageStored(id)
+		response["verified"] = err == nil
+		if err != nil {
+			log.Printf("Webhook message %s failed read-back verification: %v", id, err)
+			if req.FailureCallbackURL != "" {
+				go notifyVerificationFailure(req.FailureCallbackURL, id)
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// webhookCaller matches the request's bearer token against WEBHOOK_API_KEYS
+// and returns the caller's name.
+func webhookCaller(r *http.Request) (string, bool) {
+	return apiKeyCaller(r, "WEBHOOK_API_KEYS")
+}
+
+// apiKeyCaller matches the request's bearer token against the API keys in
+// the given environment variable, a comma separated list of name=key pairs,
+// and returns the caller's name.
+func apiKeyCaller(r *http.Request, keysEnv string) (string, bool) {
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	token = strings.TrimSpace(token)
+	if !found || token == "" {
+		return "", false
+	}
+
+	caller := ""
+	for _, entry := range strings.Split(os.Getenv(keysEnv), ",") {
+		name, key, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || name == "" || key == "" {
+			continue
+		}
+		// Compare against every key so timing does not reveal which matched
+		if services.SecureCompare(token, key) && caller == "" {
+			caller = name
+		}
+	}
+	return caller, caller != ""
+}
+
+// validateCallbackURL only accepts http(s) URLs whose host is listed in
+// WEBHOOK_CALLBACK_HOSTS, so callers cannot make the server send requests
+// to arbitrary internal addresses.
+func validateCallbackURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("must be an absolute http or https URL")
+	}
+	for _, host := range strings.Split(os.Getenv("WEBHOOK_CALLBACK_HOSTS"), ",") {
+		if host = strings.TrimSpace(host); host != "" && strings.EqualFold(host, u.Hostname()) {
+			return nil
+		}
+	}
+	return fmt.Errorf("host %s is not in WEBHOOK_CALLBACK_HOSTS", u.Hostname())
+}
+
+// callbackClient does not follow redirects: an allowed callback host could
+// otherwise bounce the request to an internal address.
+var callbackClient = &http.Client{
+	Timeout: 10 * time.Second,
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// notifyVerificationFailure tells the creator that a message it was given a
+// link for could not be read back. The body carries only the message ID.
+func notifyVerificationFailure(callbackURL, id string) {
+	payload, _ := json.Marshal(map[string]interface{}{
+		"event":    "message.verification_failed",
+		"id":       id,
+		"verified": false,
+	})
+	resp, err := callbackClient.Post(callbackURL, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		log.Printf("Error sending verification failure callback for message %s: %v", id, err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Printf("Verification failure callback for message %s returned status %d", id, resp.StatusCode)
+	}
+}
+
+// webhookRateLimit is the number of webhook requests each API key may make
+// per minute, set with WEBHOOK_RATE_LIMIT (default 60).
+func webhookRateLimit() int {
+	if n, err := strconv.Atoi(os.Getenv("WEBHOOK_RATE_LIMIT")); err == nil && n > 0 {
+		return n
+	}
+	return 60
+}
+
+var webhookLimiter = &clientRateLimiter{windows: make(map[string]*rateWindow)}
+
+// bufferedResponse captures a response so it can be stored for idempotent
+// replays before being written to the client.
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header         { return b.header }
+func (b *bufferedResponse) Write(p []byte) (int, error) { return b.body.Write(p) }
+func (b *bufferedResponse) WriteHeader(status int)      { b.status = status }
+
+func (b *bufferedResponse) copyTo(w http.ResponseWriter) {
+	for name, values := range b.header {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(b.status)
+	w.Write(b.body.Bytes())
+}
+
+type idempotencyState int
+
+const (
+	idempotencyNew idempotencyState = iota
+	idempotencyReplay
+	idempotencyMismatch
+	idempotencyInFlight
+)
+
+type idempotencyEntry struct {
+	bodyHash [sha256.Size]byte
+	created  time.Time
+	done     bool
+	status   int
+	body     []byte
+}
+
+// idempotencyStore remembers webhook responses per caller and key for
+// idempotencyTTL. It is kept in memory, so retries must reach the same
+// instance to be deduplicated.
+type idempotencyStore struct {
+	mu      sync.Mutex
+	entries map[string]*idempotencyEntry
+}
+
+var webhookIdempotency = &idempotencyStore{entries: make(map[string]*idempotencyEntry)}
+
+// reserve claims key for a new request, or reports how an earlier request
+// with the same key should be answered.
+func (s *idempotencyStore) reserve(key string, bodyHash [sha256.Size]byte) (*idempotencyEntry, idempotencyState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for k, entry := range s.entries {
+		if now.Sub(entry.created) > idempotencyTTL {
+			delete(s.entries, k)
+		}
+	}
+
+	if entry, ok := s.entries[key]; ok {
+		switch {
+		case entry.bodyHash != bodyHash:
+			return nil, idempotencyMismatch
+		case !entry.done:
+			return nil, idempotencyInFlight
+		default:
+			return entry, idempotencyReplay
+		}
+	}
+
+	s.entries[key] = &idempotencyEntry{bodyHash: bodyHash, created: now}
+	return nil, idempotencyNew
+}
+
+// complete stores a successful response for replay. Failed requests release
+// the key so the caller can retry with the same one.
+func (s *idempotencyStore) complete(key string, rec *bufferedResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if rec.status != http.StatusCreated {
+		delete(s.entries, key)
+		return
+	}
+	entry := s.entries[key]
+	if entry == nil {
+		return
+	}
+	entry.done = true
+	entry.status = rec.status
+	entry.body = append([]byte(nil), rec.body.Bytes()...)
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestVerificationCallbackDoesNotFollowRedirects(t *testing.T) {
+	var internalHits atomic.Int32
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		internalHits.Add(1)
+	}))
+	defer internal.Close()
+
+	var callbackHits atomic.Int32
+	allowed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		callbackHits.Add(1)
+		http.Redirect(w, r, internal.URL+"/admin", http.StatusTemporaryRedirect)
+	}))
+	defer allowed.Close()
+
+	notifyVerificationFailure(allowed.URL+"/hook", "msg-id")
+	if callbackHits.Load() != 1 {
+		t.Fatalf("callback received %d requests, want 1", callbackHits.Load())
+	}
+	if n := internalHits.Load(); n != 0 {
+		t.Errorf("redirect target received %d requests", n)
+	}
+}
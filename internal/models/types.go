@@ -4,6 +4,33 @@ import "time"
 
 type PageData struct {
 	Title string
+	// ConfirmLifetimes lists the lifetimes that need an explicit risk confirmation.
+	ConfirmLifetimes []string
+	// LifetimeOptions are the lifetimes offered in the encryption forms.
+	LifetimeOptions []SelectOption
+	// PasswordMinLength and PasswordMaxLength bound the password generator.
+	PasswordMinLength int
+	PasswordMaxLength int
+	// PINMinLength is the shortest length offered for the PIN type.
+	PINMinLength int
+	// PasswordDefaults preselects the password generator options.
+	PasswordDefaults PasswordDefaults
+}
+
+// PasswordDefaults are the deployment's password generator defaults.
+type PasswordDefaults struct {
+	Length       int
+	Type         string
+	UseNumbers   bool
+	UseUppercase bool
+	UseLowercase bool
+	UseSymbols   bool
+}
+
+// SelectOption is an option of a <select> element.
+type SelectOption struct {
+	Value string
+	Label string
 }
 
 type EncryptedData struct {
@@ -15,6 +42,36 @@ type EncryptedData struct {
 	ExpiresAt *time.Time `json:"expires_at,omitempty"`
 	ViewCount int        `json:"view_count"`
 	MaxViews  int        `json:"max_views"`
+	// PassphraseSalt is set when Content is encrypted with a key derived from
+	// a user passphrase; the passphrase itself is never stored.
+	PassphraseSalt string `json:"passphrase_salt,omitempty"`
+	// Compressed is set when the plaintext was deflated before encryption;
+	// OriginalSize is its size in bytes before compression.
+	Compressed   bool `json:"compressed,omitempty"`
+	OriginalSize int  `json:"original_size,omitempty"`
+	// HintQuestion is an optional security question shown on the view page;
+	// the recipient must answer it before the message is revealed.
+	// HintAnswerHash is the hash of the normalized answer.
+	HintQuestion   string `json:"hint_question,omitempty"`
+	HintAnswerHash string `json:"hint_answer_hash,omitempty"`
+	// PINEachView asks for the PIN again on every view of a multi-view
+	// message, with no reuse of an earlier submission.
+	PINEachView bool `json:"pin_each_view,omitempty"`
+	// Attachment is an optional file shared together with the note. It has
+	// no ID, expiry or PIN of its own and is released with the note.
+	Attachment *EncryptedAttachment `json:"attachment,omitempty"`
+	// ClientEncrypted is set when the browser encrypted the message with a
+	// key kept in the share URL fragment. Content is then an opaque blob the
+	// server stores and returns but can never decrypt.
+	ClientEncrypted bool `json:"client_encrypted,omitempty"`
+}
+
+// EncryptedAttachment is a file attached to a text note.
+type EncryptedAttachment struct {
+	Content  []byte `json:"content"`
+	FileName string `json:"file_name"`
+	FileSize int64  `json:"file_size"`
+	MimeType string `json:"mime_type"`
 }
 
 type EncryptionRequest struct {
@@ -0,0 +1,1077 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        v5.29.3
+// source: zeepass/v1/zeepass.proto
+
+package zeepassv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type EncryptRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Plaintext     []byte                 `protobuf:"bytes,1,opt,name=plaintext,proto3" json:"plaintext,omitempty"`
+	Context       string                 `protobuf:"bytes,2,opt,name=context,proto3" json:"context,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EncryptRequest) Reset() {
+	*x = EncryptRequest{}
+	mi := &file_zeepass_v1_zeepass_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EncryptRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EncryptRequest) ProtoMessage() {}
+
+func (x *EncryptRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_zeepass_v1_zeepass_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EncryptRequest.ProtoReflect.Descriptor instead.
+func (*EncryptRequest) Descriptor() ([]byte, []int) {
+	return file_zeepass_v1_zeepass_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *EncryptRequest) GetPlaintext() []byte {
+	if x != nil {
+		return x.Plaintext
+	}
+	return nil
+}
+
+func (x *EncryptRequest) GetContext() string {
+	if x != nil {
+		return x.Context
+	}
+	return ""
+}
+
+type EncryptResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Ciphertext    []byte                 `protobuf:"bytes,1,opt,name=ciphertext,proto3" json:"ciphertext,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EncryptResponse) Reset() {
+	*x = EncryptResponse{}
+	mi := &file_zeepass_v1_zeepass_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EncryptResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EncryptResponse) ProtoMessage() {}
+
+func (x *EncryptResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_zeepass_v1_zeepass_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EncryptResponse.ProtoReflect.Descriptor instead.
+func (*EncryptResponse) Descriptor() ([]byte, []int) {
+	return file_zeepass_v1_zeepass_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *EncryptResponse) GetCiphertext() []byte {
+	if x != nil {
+		return x.Ciphertext
+	}
+	return nil
+}
+
+type DecryptRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Ciphertext    []byte                 `protobuf:"bytes,1,opt,name=ciphertext,proto3" json:"ciphertext,omitempty"`
+	Context       string                 `protobuf:"bytes,2,opt,name=context,proto3" json:"context,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DecryptRequest) Reset() {
+	*x = DecryptRequest{}
+	mi := &file_zeepass_v1_zeepass_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DecryptRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DecryptRequest) ProtoMessage() {}
+
+func (x *DecryptRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_zeepass_v1_zeepass_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DecryptRequest.ProtoReflect.Descriptor instead.
+func (*DecryptRequest) Descriptor() ([]byte, []int) {
+	return file_zeepass_v1_zeepass_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *DecryptRequest) GetCiphertext() []byte {
+	if x != nil {
+		return x.Ciphertext
+	}
+	return nil
+}
+
+func (x *DecryptRequest) GetContext() string {
+	if x != nil {
+		return x.Context
+	}
+	return ""
+}
+
+type DecryptResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Plaintext     []byte                 `protobuf:"bytes,1,opt,name=plaintext,proto3" json:"plaintext,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DecryptResponse) Reset() {
+	*x = DecryptResponse{}
+	mi := &file_zeepass_v1_zeepass_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DecryptResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DecryptResponse) ProtoMessage() {}
+
+func (x *DecryptResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_zeepass_v1_zeepass_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DecryptResponse.ProtoReflect.Descriptor instead.
+func (*DecryptResponse) Descriptor() ([]byte, []int) {
+	return file_zeepass_v1_zeepass_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *DecryptResponse) GetPlaintext() []byte {
+	if x != nil {
+		return x.Plaintext
+	}
+	return nil
+}
+
+type StoreSecretRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Text  string                 `protobuf:"bytes,1,opt,name=text,proto3" json:"text,omitempty"`
+	// once (default), 1h, 24h, 7d, 30d or never
+	Lifetime string `protobuf:"bytes,2,opt,name=lifetime,proto3" json:"lifetime,omitempty"`
+	Pin      string `protobuf:"bytes,3,opt,name=pin,proto3" json:"pin,omitempty"`
+	// Required for lifetimes listed in CONFIRM_LIFETIMES
+	ConfirmRisk   bool `protobuf:"varint,4,opt,name=confirm_risk,json=confirmRisk,proto3" json:"confirm_risk,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StoreSecretRequest) Reset() {
+	*x = StoreSecretRequest{}
+	mi := &file_zeepass_v1_zeepass_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StoreSecretRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StoreSecretRequest) ProtoMessage() {}
+
+func (x *StoreSecretRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_zeepass_v1_zeepass_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StoreSecretRequest.ProtoReflect.Descriptor instead.
+func (*StoreSecretRequest) Descriptor() ([]byte, []int) {
+	return file_zeepass_v1_zeepass_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *StoreSecretRequest) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *StoreSecretRequest) GetLifetime() string {
+	if x != nil {
+		return x.Lifetime
+	}
+	return ""
+}
+
+func (x *StoreSecretRequest) GetPin() string {
+	if x != nil {
+		return x.Pin
+	}
+	return ""
+}
+
+func (x *StoreSecretRequest) GetConfirmRisk() bool {
+	if x != nil {
+		return x.ConfirmRisk
+	}
+	return false
+}
+
+type StoreSecretResponse struct {
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	Id           string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	ShareUrl     string                 `protobuf:"bytes,2,opt,name=share_url,json=shareUrl,proto3" json:"share_url,omitempty"`
+	Lifetime     string                 `protobuf:"bytes,3,opt,name=lifetime,proto3" json:"lifetime,omitempty"`
+	MaxViews     int32                  `protobuf:"varint,4,opt,name=max_views,json=maxViews,proto3" json:"max_views,omitempty"`
+	PinProtected bool                   `protobuf:"varint,5,opt,name=pin_protected,json=pinProtected,proto3" json:"pin_protected,omitempty"`
+	// RFC 3339, empty when the secret does not expire
+	ExpiresAt     string `protobuf:"bytes,6,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StoreSecretResponse) Reset() {
+	*x = StoreSecretResponse{}
+	mi := &file_zeepass_v1_zeepass_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StoreSecretResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StoreSecretResponse) ProtoMessage() {}
+
+func (x *StoreSecretResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_zeepass_v1_zeepass_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StoreSecretResponse.ProtoReflect.Descriptor instead.
+func (*StoreSecretResponse) Descriptor() ([]byte, []int) {
+	return file_zeepass_v1_zeepass_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *StoreSecretResponse) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *StoreSecretResponse) GetShareUrl() string {
+	if x != nil {
+		return x.ShareUrl
+	}
+	return ""
+}
+
+func (x *StoreSecretResponse) GetLifetime() string {
+	if x != nil {
+		return x.Lifetime
+	}
+	return ""
+}
+
+func (x *StoreSecretResponse) GetMaxViews() int32 {
+	if x != nil {
+		return x.MaxViews
+	}
+	return 0
+}
+
+func (x *StoreSecretResponse) GetPinProtected() bool {
+	if x != nil {
+		return x.PinProtected
+	}
+	return false
+}
+
+func (x *StoreSecretResponse) GetExpiresAt() string {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return ""
+}
+
+type RetrieveSecretRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Pin           string                 `protobuf:"bytes,2,opt,name=pin,proto3" json:"pin,omitempty"`
+	Passphrase    string                 `protobuf:"bytes,3,opt,name=passphrase,proto3" json:"passphrase,omitempty"`
+	HintAnswer    string                 `protobuf:"bytes,4,opt,name=hint_answer,json=hintAnswer,proto3" json:"hint_answer,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RetrieveSecretRequest) Reset() {
+	*x = RetrieveSecretRequest{}
+	mi := &file_zeepass_v1_zeepass_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RetrieveSecretRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RetrieveSecretRequest) ProtoMessage() {}
+
+func (x *RetrieveSecretRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_zeepass_v1_zeepass_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RetrieveSecretRequest.ProtoReflect.Descriptor instead.
+func (*RetrieveSecretRequest) Descriptor() ([]byte, []int) {
+	return file_zeepass_v1_zeepass_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *RetrieveSecretRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *RetrieveSecretRequest) GetPin() string {
+	if x != nil {
+		return x.Pin
+	}
+	return ""
+}
+
+func (x *RetrieveSecretRequest) GetPassphrase() string {
+	if x != nil {
+		return x.Passphrase
+	}
+	return ""
+}
+
+func (x *RetrieveSecretRequest) GetHintAnswer() string {
+	if x != nil {
+		return x.HintAnswer
+	}
+	return ""
+}
+
+type Attachment struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	FileName      string                 `protobuf:"bytes,1,opt,name=file_name,json=fileName,proto3" json:"file_name,omitempty"`
+	MimeType      string                 `protobuf:"bytes,2,opt,name=mime_type,json=mimeType,proto3" json:"mime_type,omitempty"`
+	Content       []byte                 `protobuf:"bytes,3,opt,name=content,proto3" json:"content,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Attachment) Reset() {
+	*x = Attachment{}
+	mi := &file_zeepass_v1_zeepass_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Attachment) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Attachment) ProtoMessage() {}
+
+func (x *Attachment) ProtoReflect() protoreflect.Message {
+	mi := &file_zeepass_v1_zeepass_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Attachment.ProtoReflect.Descriptor instead.
+func (*Attachment) Descriptor() ([]byte, []int) {
+	return file_zeepass_v1_zeepass_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Attachment) GetFileName() string {
+	if x != nil {
+		return x.FileName
+	}
+	return ""
+}
+
+func (x *Attachment) GetMimeType() string {
+	if x != nil {
+		return x.MimeType
+	}
+	return ""
+}
+
+func (x *Attachment) GetContent() []byte {
+	if x != nil {
+		return x.Content
+	}
+	return nil
+}
+
+type RetrieveSecretResponse struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Text           string                 `protobuf:"bytes,1,opt,name=text,proto3" json:"text,omitempty"`
+	Attachment     *Attachment            `protobuf:"bytes,2,opt,name=attachment,proto3" json:"attachment,omitempty"`
+	ViewsRemaining int32                  `protobuf:"varint,3,opt,name=views_remaining,json=viewsRemaining,proto3" json:"views_remaining,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *RetrieveSecretResponse) Reset() {
+	*x = RetrieveSecretResponse{}
+	mi := &file_zeepass_v1_zeepass_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RetrieveSecretResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RetrieveSecretResponse) ProtoMessage() {}
+
+func (x *RetrieveSecretResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_zeepass_v1_zeepass_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RetrieveSecretResponse.ProtoReflect.Descriptor instead.
+func (*RetrieveSecretResponse) Descriptor() ([]byte, []int) {
+	return file_zeepass_v1_zeepass_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *RetrieveSecretResponse) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *RetrieveSecretResponse) GetAttachment() *Attachment {
+	if x != nil {
+		return x.Attachment
+	}
+	return nil
+}
+
+func (x *RetrieveSecretResponse) GetViewsRemaining() int32 {
+	if x != nil {
+		return x.ViewsRemaining
+	}
+	return 0
+}
+
+type GeneratePasswordRequest struct {
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	Length       int32                  `protobuf:"varint,1,opt,name=length,proto3" json:"length,omitempty"`
+	UseNumbers   bool                   `protobuf:"varint,2,opt,name=use_numbers,json=useNumbers,proto3" json:"use_numbers,omitempty"`
+	UseUppercase bool                   `protobuf:"varint,3,opt,name=use_uppercase,json=useUppercase,proto3" json:"use_uppercase,omitempty"`
+	UseLowercase bool                   `protobuf:"varint,4,opt,name=use_lowercase,json=useLowercase,proto3" json:"use_lowercase,omitempty"`
+	UseSymbols   bool                   `protobuf:"varint,5,opt,name=use_symbols,json=useSymbols,proto3" json:"use_symbols,omitempty"`
+	// random (default), memorable, pin or passphrase
+	Type          string `protobuf:"bytes,6,opt,name=type,proto3" json:"type,omitempty"`
+	Preset        string `protobuf:"bytes,7,opt,name=preset,proto3" json:"preset,omitempty"`
+	Words         int32  `protobuf:"varint,8,opt,name=words,proto3" json:"words,omitempty"`
+	Checksum      bool   `protobuf:"varint,9,opt,name=checksum,proto3" json:"checksum,omitempty"`
+	Separator     string `protobuf:"bytes,10,opt,name=separator,proto3" json:"separator,omitempty"`
+	Capitalize    bool   `protobuf:"varint,11,opt,name=capitalize,proto3" json:"capitalize,omitempty"`
+	AddNumber     bool   `protobuf:"varint,12,opt,name=add_number,json=addNumber,proto3" json:"add_number,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GeneratePasswordRequest) Reset() {
+	*x = GeneratePasswordRequest{}
+	mi := &file_zeepass_v1_zeepass_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GeneratePasswordRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GeneratePasswordRequest) ProtoMessage() {}
+
+func (x *GeneratePasswordRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_zeepass_v1_zeepass_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GeneratePasswordRequest.ProtoReflect.Descriptor instead.
+func (*GeneratePasswordRequest) Descriptor() ([]byte, []int) {
+	return file_zeepass_v1_zeepass_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *GeneratePasswordRequest) GetLength() int32 {
+	if x != nil {
+		return x.Length
+	}
+	return 0
+}
+
+func (x *GeneratePasswordRequest) GetUseNumbers() bool {
+	if x != nil {
+		return x.UseNumbers
+	}
+	return false
+}
+
+func (x *GeneratePasswordRequest) GetUseUppercase() bool {
+	if x != nil {
+		return x.UseUppercase
+	}
+	return false
+}
+
+func (x *GeneratePasswordRequest) GetUseLowercase() bool {
+	if x != nil {
+		return x.UseLowercase
+	}
+	return false
+}
+
+func (x *GeneratePasswordRequest) GetUseSymbols() bool {
+	if x != nil {
+		return x.UseSymbols
+	}
+	return false
+}
+
+func (x *GeneratePasswordRequest) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *GeneratePasswordRequest) GetPreset() string {
+	if x != nil {
+		return x.Preset
+	}
+	return ""
+}
+
+func (x *GeneratePasswordRequest) GetWords() int32 {
+	if x != nil {
+		return x.Words
+	}
+	return 0
+}
+
+func (x *GeneratePasswordRequest) GetChecksum() bool {
+	if x != nil {
+		return x.Checksum
+	}
+	return false
+}
+
+func (x *GeneratePasswordRequest) GetSeparator() string {
+	if x != nil {
+		return x.Separator
+	}
+	return ""
+}
+
+func (x *GeneratePasswordRequest) GetCapitalize() bool {
+	if x != nil {
+		return x.Capitalize
+	}
+	return false
+}
+
+func (x *GeneratePasswordRequest) GetAddNumber() bool {
+	if x != nil {
+		return x.AddNumber
+	}
+	return false
+}
+
+type GeneratePasswordResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Password      string                 `protobuf:"bytes,1,opt,name=password,proto3" json:"password,omitempty"`
+	Strength      string                 `protobuf:"bytes,2,opt,name=strength,proto3" json:"strength,omitempty"`
+	EntropyBits   float64                `protobuf:"fixed64,3,opt,name=entropy_bits,json=entropyBits,proto3" json:"entropy_bits,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GeneratePasswordResponse) Reset() {
+	*x = GeneratePasswordResponse{}
+	mi := &file_zeepass_v1_zeepass_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GeneratePasswordResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GeneratePasswordResponse) ProtoMessage() {}
+
+func (x *GeneratePasswordResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_zeepass_v1_zeepass_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GeneratePasswordResponse.ProtoReflect.Descriptor instead.
+func (*GeneratePasswordResponse) Descriptor() ([]byte, []int) {
+	return file_zeepass_v1_zeepass_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *GeneratePasswordResponse) GetPassword() string {
+	if x != nil {
+		return x.Password
+	}
+	return ""
+}
+
+func (x *GeneratePasswordResponse) GetStrength() string {
+	if x != nil {
+		return x.Strength
+	}
+	return ""
+}
+
+func (x *GeneratePasswordResponse) GetEntropyBits() float64 {
+	if x != nil {
+		return x.EntropyBits
+	}
+	return 0
+}
+
+type GenerateSSHKeyRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// rsa, ed25519 or ecdsa
+	Type       string `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
+	Length     int32  `protobuf:"varint,2,opt,name=length,proto3" json:"length,omitempty"`
+	Passphrase string `protobuf:"bytes,3,opt,name=passphrase,proto3" json:"passphrase,omitempty"`
+	Comment    string `protobuf:"bytes,4,opt,name=comment,proto3" json:"comment,omitempty"`
+	// pem (default) or openssh
+	Format        string `protobuf:"bytes,5,opt,name=format,proto3" json:"format,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GenerateSSHKeyRequest) Reset() {
+	*x = GenerateSSHKeyRequest{}
+	mi := &file_zeepass_v1_zeepass_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GenerateSSHKeyRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GenerateSSHKeyRequest) ProtoMessage() {}
+
+func (x *GenerateSSHKeyRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_zeepass_v1_zeepass_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GenerateSSHKeyRequest.ProtoReflect.Descriptor instead.
+func (*GenerateSSHKeyRequest) Descriptor() ([]byte, []int) {
+	return file_zeepass_v1_zeepass_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *GenerateSSHKeyRequest) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *GenerateSSHKeyRequest) GetLength() int32 {
+	if x != nil {
+		return x.Length
+	}
+	return 0
+}
+
+func (x *GenerateSSHKeyRequest) GetPassphrase() string {
+	if x != nil {
+		return x.Passphrase
+	}
+	return ""
+}
+
+func (x *GenerateSSHKeyRequest) GetComment() string {
+	if x != nil {
+		return x.Comment
+	}
+	return ""
+}
+
+func (x *GenerateSSHKeyRequest) GetFormat() string {
+	if x != nil {
+		return x.Format
+	}
+	return ""
+}
+
+type GenerateSSHKeyResponse struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	PrivateKey     string                 `protobuf:"bytes,1,opt,name=private_key,json=privateKey,proto3" json:"private_key,omitempty"`
+	PublicKey      string                 `protobuf:"bytes,2,opt,name=public_key,json=publicKey,proto3" json:"public_key,omitempty"`
+	Fingerprint    string                 `protobuf:"bytes,3,opt,name=fingerprint,proto3" json:"fingerprint,omitempty"`
+	FingerprintMd5 string                 `protobuf:"bytes,4,opt,name=fingerprint_md5,json=fingerprintMd5,proto3" json:"fingerprint_md5,omitempty"`
+	// Randomart of the SHA256 fingerprint, as ssh-keygen -lv prints it
+	Randomart     string `protobuf:"bytes,5,opt,name=randomart,proto3" json:"randomart,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GenerateSSHKeyResponse) Reset() {
+	*x = GenerateSSHKeyResponse{}
+	mi := &file_zeepass_v1_zeepass_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GenerateSSHKeyResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GenerateSSHKeyResponse) ProtoMessage() {}
+
+func (x *GenerateSSHKeyResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_zeepass_v1_zeepass_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GenerateSSHKeyResponse.ProtoReflect.Descriptor instead.
+func (*GenerateSSHKeyResponse) Descriptor() ([]byte, []int) {
+	return file_zeepass_v1_zeepass_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *GenerateSSHKeyResponse) GetPrivateKey() string {
+	if x != nil {
+		return x.PrivateKey
+	}
+	return ""
+}
+
+func (x *GenerateSSHKeyResponse) GetPublicKey() string {
+	if x != nil {
+		return x.PublicKey
+	}
+	return ""
+}
+
+func (x *GenerateSSHKeyResponse) GetFingerprint() string {
+	if x != nil {
+		return x.Fingerprint
+	}
+	return ""
+}
+
+func (x *GenerateSSHKeyResponse) GetFingerprintMd5() string {
+	if x != nil {
+		return x.FingerprintMd5
+	}
+	return ""
+}
+
+func (x *GenerateSSHKeyResponse) GetRandomart() string {
+	if x != nil {
+		return x.Randomart
+	}
+	return ""
+}
+
+var File_zeepass_v1_zeepass_proto protoreflect.FileDescriptor
+
+const file_zeepass_v1_zeepass_proto_rawDesc = "" +
+	"\n" +
+	"\x18zeepass/v1/zeepass.proto\x12\n" +
+	"zeepass.v1\"H\n" +
+	"\x0eEncryptRequest\x12\x1c\n" +
+	"\tplaintext\x18\x01 \x01(\fR\tplaintext\x12\x18\n" +
+	"\acontext\x18\x02 \x01(\tR\acontext\"1\n" +
+	"\x0fEncryptResponse\x12\x1e\n" +
+	"\n" +
+	"ciphertext\x18\x01 \x01(\fR\n" +
+	"ciphertext\"J\n" +
+	"\x0eDecryptRequest\x12\x1e\n" +
+	"\n" +
+	"ciphertext\x18\x01 \x01(\fR\n" +
+	"ciphertext\x12\x18\n" +
+	"\acontext\x18\x02 \x01(\tR\acontext\"/\n" +
+	"\x0fDecryptResponse\x12\x1c\n" +
+	"\tplaintext\x18\x01 \x01(\fR\tplaintext\"y\n" +
+	"\x12StoreSecretRequest\x12\x12\n" +
+	"\x04text\x18\x01 \x01(\tR\x04text\x12\x1a\n" +
+	"\blifetime\x18\x02 \x01(\tR\blifetime\x12\x10\n" +
+	"\x03pin\x18\x03 \x01(\tR\x03pin\x12!\n" +
+	"\fconfirm_risk\x18\x04 \x01(\bR\vconfirmRisk\"\xbf\x01\n" +
+	"\x13StoreSecretResponse\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x1b\n" +
+	"\tshare_url\x18\x02 \x01(\tR\bshareUrl\x12\x1a\n" +
+	"\blifetime\x18\x03 \x01(\tR\blifetime\x12\x1b\n" +
+	"\tmax_views\x18\x04 \x01(\x05R\bmaxViews\x12#\n" +
+	"\rpin_protected\x18\x05 \x01(\bR\fpinProtected\x12\x1d\n" +
+	"\n" +
+	"expires_at\x18\x06 \x01(\tR\texpiresAt\"z\n" +
+	"\x15RetrieveSecretRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x10\n" +
+	"\x03pin\x18\x02 \x01(\tR\x03pin\x12\x1e\n" +
+	"\n" +
+	"passphrase\x18\x03 \x01(\tR\n" +
+	"passphrase\x12\x1f\n" +
+	"\vhint_answer\x18\x04 \x01(\tR\n" +
+	"hintAnswer\"`\n" +
+	"\n" +
+	"Attachment\x12\x1b\n" +
+	"\tfile_name\x18\x01 \x01(\tR\bfileName\x12\x1b\n" +
+	"\tmime_type\x18\x02 \x01(\tR\bmimeType\x12\x18\n" +
+	"\acontent\x18\x03 \x01(\fR\acontent\"\x8d\x01\n" +
+	"\x16RetrieveSecretResponse\x12\x12\n" +
+	"\x04text\x18\x01 \x01(\tR\x04text\x126\n" +
+	"\n" +
+	"attachment\x18\x02 \x01(\v2\x16.zeepass.v1.AttachmentR\n" +
+	"attachment\x12'\n" +
+	"\x0fviews_remaining\x18\x03 \x01(\x05R\x0eviewsRemaining\"\xf8\x02\n" +
+	"\x17GeneratePasswordRequest\x12\x16\n" +
+	"\x06length\x18\x01 \x01(\x05R\x06length\x12\x1f\n" +
+	"\vuse_numbers\x18\x02 \x01(\bR\n" +
+	"useNumbers\x12#\n" +
+	"\ruse_uppercase\x18\x03 \x01(\bR\fuseUppercase\x12#\n" +
+	"\ruse_lowercase\x18\x04 \x01(\bR\fuseLowercase\x12\x1f\n" +
+	"\vuse_symbols\x18\x05 \x01(\bR\n" +
+	"useSymbols\x12\x12\n" +
+	"\x04type\x18\x06 \x01(\tR\x04type\x12\x16\n" +
+	"\x06preset\x18\a \x01(\tR\x06preset\x12\x14\n" +
+	"\x05words\x18\b \x01(\x05R\x05words\x12\x1a\n" +
+	"\bchecksum\x18\t \x01(\bR\bchecksum\x12\x1c\n" +
+	"\tseparator\x18\n" +
+	" \x01(\tR\tseparator\x12\x1e\n" +
+	"\n" +
+	"capitalize\x18\v \x01(\bR\n" +
+	"capitalize\x12\x1d\n" +
+	"\n" +
+	"add_number\x18\f \x01(\bR\taddNumber\"u\n" +
+	"\x18GeneratePasswordResponse\x12\x1a\n" +
+	"\bpassword\x18\x01 \x01(\tR\bpassword\x12\x1a\n" +
+	"\bstrength\x18\x02 \x01(\tR\bstrength\x12!\n" +
+	"\fentropy_bits\x18\x03 \x01(\x01R\ventropyBits\"\x95\x01\n" +
+	"\x15GenerateSSHKeyRequest\x12\x12\n" +
+	"\x04type\x18\x01 \x01(\tR\x04type\x12\x16\n" +
+	"\x06length\x18\x02 \x01(\x05R\x06length\x12\x1e\n" +
+	"\n" +
+	"passphrase\x18\x03 \x01(\tR\n" +
+	"passphrase\x12\x18\n" +
+	"\acomment\x18\x04 \x01(\tR\acomment\x12\x16\n" +
+	"\x06format\x18\x05 \x01(\tR\x06format\"\xc1\x01\n" +
+	"\x16GenerateSSHKeyResponse\x12\x1f\n" +
+	"\vprivate_key\x18\x01 \x01(\tR\n" +
+	"privateKey\x12\x1d\n" +
+	"\n" +
+	"public_key\x18\x02 \x01(\tR\tpublicKey\x12 \n" +
+	"\vfingerprint\x18\x03 \x01(\tR\vfingerprint\x12'\n" +
+	"\x0ffingerprint_md5\x18\x04 \x01(\tR\x0efingerprintMd5\x12\x1c\n" +
+	"\trandomart\x18\x05 \x01(\tR\trandomart2\xf2\x03\n" +
+	"\aZeePass\x12B\n" +
+	"\aEncrypt\x12\x1a.zeepass.v1.EncryptRequest\x1a\x1b.zeepass.v1.EncryptResponse\x12B\n" +
+	"\aDecrypt\x12\x1a.zeepass.v1.DecryptRequest\x1a\x1b.zeepass.v1.DecryptResponse\x12N\n" +
+	"\vStoreSecret\x12\x1e.zeepass.v1.StoreSecretRequest\x1a\x1f.zeepass.v1.StoreSecretResponse\x12W\n" +
+	"\x0eRetrieveSecret\x12!.zeepass.v1.RetrieveSecretRequest\x1a\".zeepass.v1.RetrieveSecretResponse\x12]\n" +
+	"\x10GeneratePassword\x12#.zeepass.v1.GeneratePasswordRequest\x1a$.zeepass.v1.GeneratePasswordResponse\x12W\n" +
+	"\x0eGenerateSSHKey\x12!.zeepass.v1.GenerateSSHKeyRequest\x1a\".zeepass.v1.GenerateSSHKeyResponseB>Z<github.com/anazri/zeepass/internal/proto/zeepassv1;zeepassv1b\x06proto3"
+
+var (
+	file_zeepass_v1_zeepass_proto_rawDescOnce sync.Once
+	file_zeepass_v1_zeepass_proto_rawDescData []byte
+)
+
+func file_zeepass_v1_zeepass_proto_rawDescGZIP() []byte {
+	file_zeepass_v1_zeepass_proto_rawDescOnce.Do(func() {
+		file_zeepass_v1_zeepass_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_zeepass_v1_zeepass_proto_rawDesc), len(file_zeepass_v1_zeepass_proto_rawDesc)))
+	})
+	return file_zeepass_v1_zeepass_proto_rawDescData
+}
+
+var file_zeepass_v1_zeepass_proto_msgTypes = make([]protoimpl.MessageInfo, 13)
+var file_zeepass_v1_zeepass_proto_goTypes = []any{
+	(*EncryptRequest)(nil),           // 0: zeepass.v1.EncryptRequest
+	(*EncryptResponse)(nil),          // 1: zeepass.v1.EncryptResponse
+	(*DecryptRequest)(nil),           // 2: zeepass.v1.DecryptRequest
+	(*DecryptResponse)(nil),          // 3: zeepass.v1.DecryptResponse
+	(*StoreSecretRequest)(nil),       // 4: zeepass.v1.StoreSecretRequest
+	(*StoreSecretResponse)(nil),      // 5: zeepass.v1.StoreSecretResponse
+	(*RetrieveSecretRequest)(nil),    // 6: zeepass.v1.RetrieveSecretRequest
+	(*Attachment)(nil),               // 7: zeepass.v1.Attachment
+	(*RetrieveSecretResponse)(nil),   // 8: zeepass.v1.RetrieveSecretResponse
+	(*GeneratePasswordRequest)(nil),  // 9: zeepass.v1.GeneratePasswordRequest
+	(*GeneratePasswordResponse)(nil), // 10: zeepass.v1.GeneratePasswordResponse
+	(*GenerateSSHKeyRequest)(nil),    // 11: zeepass.v1.GenerateSSHKeyRequest
+	(*GenerateSSHKeyResponse)(nil),   // 12: zeepass.v1.GenerateSSHKeyResponse
+}
+var file_zeepass_v1_zeepass_proto_depIdxs = []int32{
+	7,  // 0: zeepass.v1.RetrieveSecretResponse.attachment:type_name -> zeepass.v1.Attachment
+	0,  // 1: zeepass.v1.ZeePass.Encrypt:input_type -> zeepass.v1.EncryptRequest
+	2,  // 2: zeepass.v1.ZeePass.Decrypt:input_type -> zeepass.v1.DecryptRequest
+	4,  // 3: zeepass.v1.ZeePass.StoreSecret:input_type -> zeepass.v1.StoreSecretRequest
+	6,  // 4: zeepass.v1.ZeePass.RetrieveSecret:input_type -> zeepass.v1.RetrieveSecretRequest
+	9,  // 5: zeepass.v1.ZeePass.GeneratePassword:input_type -> zeepass.v1.GeneratePasswordRequest
+	11, // 6: zeepass.v1.ZeePass.GenerateSSHKey:input_type -> zeepass.v1.GenerateSSHKeyRequest
+	1,  // 7: zeepass.v1.ZeePass.Encrypt:output_type -> zeepass.v1.EncryptResponse
+	3,  // 8: zeepass.v1.ZeePass.Decrypt:output_type -> zeepass.v1.DecryptResponse
+	5,  // 9: zeepass.v1.ZeePass.StoreSecret:output_type -> zeepass.v1.StoreSecretResponse
+	8,  // 10: zeepass.v1.ZeePass.RetrieveSecret:output_type -> zeepass.v1.RetrieveSecretResponse
+	10, // 11: zeepass.v1.ZeePass.GeneratePassword:output_type -> zeepass.v1.GeneratePasswordResponse
+	12, // 12: zeepass.v1.ZeePass.GenerateSSHKey:output_type -> zeepass.v1.GenerateSSHKeyResponse
+	7,  // [7:13] is the sub-list for method output_type
+	1,  // [1:7] is the sub-list for method input_type
+	1,  // [1:1] is the sub-list for extension type_name
+	1,  // [1:1] is the sub-list for extension extendee
+	0,  // [0:1] is the sub-list for field type_name
+}
+
+func init() { file_zeepass_v1_zeepass_proto_init() }
+func file_zeepass_v1_zeepass_proto_init() {
+	if File_zeepass_v1_zeepass_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_zeepass_v1_zeepass_proto_rawDesc), len(file_zeepass_v1_zeepass_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   13,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_zeepass_v1_zeepass_proto_goTypes,
+		DependencyIndexes: file_zeepass_v1_zeepass_proto_depIdxs,
+		MessageInfos:      file_zeepass_v1_zeepass_proto_msgTypes,
+	}.Build()
+	File_zeepass_v1_zeepass_proto = out.File
+	file_zeepass_v1_zeepass_proto_goTypes = nil
+	file_zeepass_v1_zeepass_proto_depIdxs = nil
+}
@@ -0,0 +1,331 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.29.3
+// source: zeepass/v1/zeepass.proto
+
+package zeepassv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	ZeePass_Encrypt_FullMethodName          = "/zeepass.v1.ZeePass/Encrypt"
+	ZeePass_Decrypt_FullMethodName          = "/zeepass.v1.ZeePass/Decrypt"
+	ZeePass_StoreSecret_FullMethodName      = "/zeepass.v1.ZeePass/StoreSecret"
+	ZeePass_RetrieveSecret_FullMethodName   = "/zeepass.v1.ZeePass/RetrieveSecret"
+	ZeePass_GeneratePassword_FullMethodName = "/zeepass.v1.ZeePass/GeneratePassword"
+	ZeePass_GenerateSSHKey_FullMethodName   = "/zeepass.v1.ZeePass/GenerateSSHKey"
+)
+
+// ZeePassClient is the client API for ZeePass service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// ZeePass exposes the encryption tools over gRPC for internal integrators.
+// Every call needs an API key from GRPC_API_KEYS in the "authorization"
+// metadata ("Bearer <key>").
+type ZeePassClient interface {
+	// Encrypt seals data with the server key. The context is bound to the
+	// ciphertext and must be passed again to Decrypt.
+	Encrypt(ctx context.Context, in *EncryptRequest, opts ...grpc.CallOption) (*EncryptResponse, error)
+	Decrypt(ctx context.Context, in *DecryptRequest, opts ...grpc.CallOption) (*DecryptResponse, error)
+	// StoreSecret stores a text secret and returns its share link, like the
+	// webhook API.
+	StoreSecret(ctx context.Context, in *StoreSecretRequest, opts ...grpc.CallOption) (*StoreSecretResponse, error)
+	// RetrieveSecret reads a stored secret and counts a view, like opening
+	// its link.
+	RetrieveSecret(ctx context.Context, in *RetrieveSecretRequest, opts ...grpc.CallOption) (*RetrieveSecretResponse, error)
+	GeneratePassword(ctx context.Context, in *GeneratePasswordRequest, opts ...grpc.CallOption) (*GeneratePasswordResponse, error)
+	GenerateSSHKey(ctx context.Context, in *GenerateSSHKeyRequest, opts ...grpc.CallOption) (*GenerateSSHKeyResponse, error)
+}
+
+type zeePassClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewZeePassClient(cc grpc.ClientConnInterface) ZeePassClient {
+	return &zeePassClient{cc}
+}
+
+func (c *zeePassClient) Encrypt(ctx context.Context, in *EncryptRequest, opts ...grpc.CallOption) (*EncryptResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(EncryptResponse)
+	err := c.cc.Invoke(ctx, ZeePass_Encrypt_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *zeePassClient) Decrypt(ctx context.Context, in *DecryptRequest, opts ...grpc.CallOption) (*DecryptResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DecryptResponse)
+	err := c.cc.Invoke(ctx, ZeePass_Decrypt_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *zeePassClient) StoreSecret(ctx context.Context, in *StoreSecretRequest, opts ...grpc.CallOption) (*StoreSecretResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(StoreSecretResponse)
+	err := c.cc.Invoke(ctx, ZeePass_StoreSecret_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *zeePassClient) RetrieveSecret(ctx context.Context, in *RetrieveSecretRequest, opts ...grpc.CallOption) (*RetrieveSecretResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RetrieveSecretResponse)
+	err := c.cc.Invoke(ctx, ZeePass_RetrieveSecret_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *zeePassClient) GeneratePassword(ctx context.Context, in *GeneratePasswordRequest, opts ...grpc.CallOption) (*GeneratePasswordResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GeneratePasswordResponse)
+	err := c.cc.Invoke(ctx, ZeePass_GeneratePassword_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *zeePassClient) GenerateSSHKey(ctx context.Context, in *GenerateSSHKeyRequest, opts ...grpc.CallOption) (*GenerateSSHKeyResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GenerateSSHKeyResponse)
+	err := c.cc.Invoke(ctx, ZeePass_GenerateSSHKey_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ZeePassServer is the server API for ZeePass service.
+// All implementations must embed UnimplementedZeePassServer
+// for forward compatibility.
+//
+// ZeePass exposes the encryption tools over gRPC for internal integrators.
+// Every call needs an API key from GRPC_API_KEYS in the "authorization"
+// metadata ("Bearer <key>").
+type ZeePassServer interface {
+	// Encrypt seals data with the server key. The context is bound to the
+	// ciphertext and must be passed again to Decrypt.
+	Encrypt(context.Context, *EncryptRequest) (*EncryptResponse, error)
+	Decrypt(context.Context, *DecryptRequest) (*DecryptResponse, error)
+	// StoreSecret stores a text secret and returns its share link, like the
+	// webhook API.
+	StoreSecret(context.Context, *StoreSecretRequest) (*StoreSecretResponse, error)
+	// RetrieveSecret reads a stored secret and counts a view, like opening
+	// its link.
+	RetrieveSecret(context.Context, *RetrieveSecretRequest) (*RetrieveSecretResponse, error)
+	GeneratePassword(context.Context, *GeneratePasswordRequest) (*GeneratePasswordResponse, error)
+	GenerateSSHKey(context.Context, *GenerateSSHKeyRequest) (*GenerateSSHKeyResponse, error)
+	mustEmbedUnimplementedZeePassServer()
+}
+
+// UnimplementedZeePassServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedZeePassServer struct{}
+
+func (UnimplementedZeePassServer) Encrypt(context.Context, *EncryptRequest) (*EncryptResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Encrypt not implemented")
+}
+func (UnimplementedZeePassServer) Decrypt(context.Context, *DecryptRequest) (*DecryptResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Decrypt not implemented")
+}
+func (UnimplementedZeePassServer) StoreSecret(context.Context, *StoreSecretRequest) (*StoreSecretResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StoreSecret not implemented")
+}
+func (UnimplementedZeePassServer) RetrieveSecret(context.Context, *RetrieveSecretRequest) (*RetrieveSecretResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RetrieveSecret not implemented")
+}
+func (UnimplementedZeePassServer) GeneratePassword(context.Context, *GeneratePasswordRequest) (*GeneratePasswordResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GeneratePassword not implemented")
+}
+func (UnimplementedZeePassServer) GenerateSSHKey(context.Context, *GenerateSSHKeyRequest) (*GenerateSSHKeyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GenerateSSHKey not implemented")
+}
+func (UnimplementedZeePassServer) mustEmbedUnimplementedZeePassServer() {}
+func (UnimplementedZeePassServer) testEmbeddedByValue()                 {}
+
+// UnsafeZeePassServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ZeePassServer will
+// result in compilation errors.
+type UnsafeZeePassServer interface {
+	mustEmbedUnimplementedZeePassServer()
+}
+
+func RegisterZeePassServer(s grpc.ServiceRegistrar, srv ZeePassServer) {
+	// If the following call pancis, it indicates UnimplementedZeePassServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&ZeePass_ServiceDesc, srv)
+}
+
+func _ZeePass_Encrypt_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EncryptRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ZeePassServer).Encrypt(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ZeePass_Encrypt_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ZeePassServer).Encrypt(ctx, req.(*EncryptRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ZeePass_Decrypt_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DecryptRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ZeePassServer).Decrypt(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ZeePass_Decrypt_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ZeePassServer).Decrypt(ctx, req.(*DecryptRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ZeePass_StoreSecret_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StoreSecretRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ZeePassServer).StoreSecret(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ZeePass_StoreSecret_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ZeePassServer).StoreSecret(ctx, req.(*StoreSecretRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ZeePass_RetrieveSecret_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RetrieveSecretRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ZeePassServer).RetrieveSecret(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ZeePass_RetrieveSecret_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ZeePassServer).RetrieveSecret(ctx, req.(*RetrieveSecretRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ZeePass_GeneratePassword_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GeneratePasswordRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ZeePassServer).GeneratePassword(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ZeePass_GeneratePassword_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ZeePassServer).GeneratePassword(ctx, req.(*GeneratePasswordRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ZeePass_GenerateSSHKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GenerateSSHKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ZeePassServer).GenerateSSHKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ZeePass_GenerateSSHKey_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ZeePassServer).GenerateSSHKey(ctx, req.(*GenerateSSHKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// ZeePass_ServiceDesc is the grpc.ServiceDesc for ZeePass service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ZeePass_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "zeepass.v1.ZeePass",
+	HandlerType: (*ZeePassServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Encrypt",
+			Handler:    _ZeePass_Encrypt_Handler,
+		},
+		{
+			MethodName: "Decrypt",
+			Handler:    _ZeePass_Decrypt_Handler,
+		},
+		{
+			MethodName: "StoreSecret",
+			Handler:    _ZeePass_StoreSecret_Handler,
+		},
+		{
+			MethodName: "RetrieveSecret",
+			Handler:    _ZeePass_RetrieveSecret_Handler,
+		},
+		{
+			MethodName: "GeneratePassword",
+			Handler:    _ZeePass_GeneratePassword_Handler,
+		},
+		{
+			MethodName: "GenerateSSHKey",
+			Handler:    _ZeePass_GenerateSSHKey_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "zeepass/v1/zeepass.proto",
+}
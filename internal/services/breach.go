@@ -0,0 +1,152 @@
+package services
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultHIBPRangeURL = "https://api.pwnedpasswords.com/range/"
+
+	// MaxBreachCheckBatch is the most passwords accepted in one bulk check.
+	// Each password can cost a range request to the HIBP API, so batches are
+	// kept small.
+	MaxBreachCheckBatch = 20
+
+	// breachCheckConcurrency limits parallel range requests to the HIBP API.
+	breachCheckConcurrency = 4
+)
+
+// hibpClient is shared so keep-alive connections to the API are reused.
+var hibpClient = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		MaxIdleConnsPerHost: breachCheckConcurrency,
+		IdleConnTimeout:     90 * time.Second,
+	},
+}
+
+// BreachResult is the outcome of checking one password against breach data.
+type BreachResult struct {
+	Breached bool   `json:"breached"`
+	Count    int    `json:"count"`
+	Error    string `json:"error,omitempty"`
+}
+
+// hibpRangeURL returns the Pwned Passwords range endpoint, overridable with
+// HIBP_API_URL (e.g. to point at a local mirror).
+func hibpRangeURL() string {
+	if url := os.Getenv("HIBP_API_URL"); url != "" {
+		return strings.TrimSuffix(url, "/") + "/"
+	}
+	return defaultHIBPRangeURL
+}
+
+// IsPwned returns how many times password appears in the Pwned Passwords
+// corpus, zero if it was never seen in a breach. Only the first five hex
+// characters of its SHA-1 hash leave the server (k-anonymity).
+func IsPwned(password string) (int, error) {
+	prefix, suffix := hashForRangeQuery(password)
+	counts, err := fetchPwnedRange(prefix)
+	if err != nil {
+		return 0, err
+	}
+	return counts[suffix], nil
+}
+
+// CheckPasswordsBreached checks each password against breach data and returns
+// one result per input, in the same order. Passwords sharing a hash prefix
+// are served by a single range request, and a failed request only marks the
+// passwords that depended on it.
+func CheckPasswordsBreached(passwords []string) ([]BreachResult, error) {
+	if len(passwords) > MaxBreachCheckBatch {
+		return nil, fmt.Errorf("too many passwords: %d (maximum %d)", len(passwords), MaxBreachCheckBatch)
+	}
+
+	suffixes := make([]string, len(passwords))
+	byPrefix := make(map[string][]int)
+	for i, password := range passwords {
+		prefix, suffix := hashForRangeQuery(password)
+		suffixes[i] = suffix
+		byPrefix[prefix] = append(byPrefix[prefix], i)
+	}
+
+	results := make([]BreachResult, len(passwords))
+	sem := make(chan struct{}, breachCheckConcurrency)
+	var wg sync.WaitGroup
+	for prefix, indexes := range byPrefix {
+		wg.Add(1)
+		go func(prefix string, indexes []int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			counts, err := fetchPwnedRange(prefix)
+			for _, i := range indexes {
+				if err != nil {
+					results[i].Error = "breach lookup failed"
+					continue
+				}
+				results[i].Count = counts[suffixes[i]]
+				results[i].Breached = results[i].Count > 0
+			}
+		}(prefix, indexes)
+	}
+	wg.Wait()
+
+	return results, nil
+}
+
+func hashForRangeQuery(password string) (string, string) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	return hash[:5], hash[5:]
+}
+
+// fetchPwnedRange downloads all hash suffixes for a prefix. Padding is
+// requested so the response size does not hint at the prefix; padded entries
+// have a count of zero.
+func fetchPwnedRange(prefix string) (map[string]int, error) {
+	req, err := http.NewRequest(http.MethodGet, hibpRangeURL()+prefix, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Add-Padding", "true")
+	req.Header.Set("User-Agent", "ZeePass")
+
+	resp, err := hibpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("breach lookup failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("breach lookup failed: unexpected status %d", resp.StatusCode)
+	}
+
+	counts := make(map[string]int)
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		suffix, count, ok := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if !ok {
+			continue
+		}
+		n, err := strconv.Atoi(count)
+		if err != nil || n == 0 {
+			continue
+		}
+		counts[strings.ToUpper(suffix)] = n
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("breach lookup failed: %v", err)
+	}
+	return counts, nil
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+const (
+	CharsetAuto    = "auto"
+	CharsetUTF8    = "utf-8"
+	CharsetUTF16LE = "utf-16le"
+	CharsetUTF16BE = "utf-16be"
+	CharsetLatin1  = "iso-8859-1"
+	CharsetBinary  = "binary"
+)
+
+// DecodeText converts decoded bytes into a UTF-8 string. When charset is empty
+// or "auto" the source encoding is detected from BOMs and byte patterns. The
+// returned charset is the one actually used, or CharsetBinary when the data is
+// not displayable as text (in which case text is empty).
+func DecodeText(data []byte, charset string) (string, string, error) {
+	charset = strings.ToLower(strings.TrimSpace(charset))
+	if charset == "" || charset == CharsetAuto {
+		charset = DetectCharset(data)
+	}
+
+	switch charset {
+	case CharsetUTF8, "utf8":
+		data = bytes.TrimPrefix(data, []byte{0xEF, 0xBB, 0xBF})
+		if !utf8.Valid(data) {
+			return "", CharsetUTF8, fmt.Errorf("data is not valid UTF-8")
+		}
+		return string(data), CharsetUTF8, nil
+	case CharsetUTF16LE, CharsetUTF16BE:
+		return decodeUTF16(data, charset == CharsetUTF16BE), charset, nil
+	case CharsetLatin1, "latin1", "latin-1":
+		return decodeLatin1(data), CharsetLatin1, nil
+	case CharsetBinary:
+		return "", CharsetBinary, nil
+	default:
+		return "", "", fmt.Errorf("unsupported charset: %s (supported: auto, utf-8, utf-16le, utf-16be, iso-8859-1)", charset)
+	}
+}
+
+// DetectCharset guesses the text encoding of data. It recognises UTF-8 and
+// UTF-16 byte order marks, BOM-less UTF-16 of mostly ASCII text, valid UTF-8,
+// and falls back to Latin-1 for 8-bit text. Anything that looks like binary
+// content is reported as CharsetBinary.
+func DetectCharset(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		return CharsetUTF8
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		return CharsetUTF16LE
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		return CharsetUTF16BE
+	}
+
+	if charset := detectUTF16WithoutBOM(data); charset != "" {
+		return charset
+	}
+
+	if looksBinary(data) {
+		return CharsetBinary
+	}
+	if utf8.Valid(data) {
+		return CharsetUTF8
+	}
+	return CharsetLatin1
+}
+
+// detectUTF16WithoutBOM spots UTF-16 encoded ASCII, where every other byte is zero.
+func detectUTF16WithoutBOM(data []byte) string {
+	if len(data) < 4 || len(data)%2 != 0 {
+		return ""
+	}
+
+	evenZeros, oddZeros := 0, 0
+	for i, b := range data {
+		if b != 0 {
+			continue
+		}
+		if i%2 == 0 {
+			evenZeros++
+		} else {
+			oddZeros++
+		}
+	}
+
+	pairs := len(data) / 2
+	switch {
+	case oddZeros*10 >= pairs*9 && evenZeros*10 <= pairs:
+		return CharsetUTF16LE
+	case evenZeros*10 >= pairs*9 && oddZeros*10 <= pairs:
+		return CharsetUTF16BE
+	default:
+		return ""
+	}
+}
+
+// looksBinary reports whether data contains NUL bytes or a high proportion of
+// control characters other than common whitespace.
+func looksBinary(data []byte) bool {
+	if len(data) == 0 {
+		return false
+	}
+
+	control := 0
+	for _, b := range data {
+		if b == 0 {
+			return true
+		}
+		if b < 0x20 && b != '\t' && b != '\n' && b != '\r' && b != '\f' && b != 0x1B {
+			control++
+		}
+	}
+	return control*10 > len(data)
+}
+
+func decodeUTF16(data []byte, bigEndian bool) string {
+	if bigEndian {
+		data = bytes.TrimPrefix(data, []byte{0xFE, 0xFF})
+	} else {
+		data = bytes.TrimPrefix(data, []byte{0xFF, 0xFE})
+	}
+
+	units := make([]uint16, 0, len(data)/2)
+	for i := 0; i+1 < len(data); i += 2 {
+		if bigEndian {
+			units = append(units, uint16(data[i])<<8|uint16(data[i+1]))
+		} else {
+			units = append(units, uint16(data[i+1])<<8|uint16(data[i]))
+		}
+	}
+	return string(utf16.Decode(units))
+}
+
+func decodeLatin1(data []byte) string {
+	runes := make([]rune, len(data))
+	for i, b := range data {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
@@ -2,12 +2,21 @@ package services
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
+	"math/big"
 	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -16,16 +25,19 @@ import (
 
 type ChatService struct {
 	rooms       map[string]*ChatRoom
-	roomMutex   sync.RWMutex
+	roomMutex   sync.RWMutex // Taken before a room's mutex, never while holding one
 	upgrader    websocket.Upgrader
 	redisClient *redis.Client
 	rateLimiter map[string]*RateLimiter
 	limiterMutex sync.RWMutex
+	activeConnections int64
+	draining     chan struct{} // Closed when the server starts shutting down
+	drainOnce    sync.Once
 }
 
 type RateLimiter struct {
-	tokens    int
-	capacity  int
+	tokens    float64 // Fractional so partial refills carry over between calls
+	capacity  int     // Bucket size, also the refill rate in tokens per minute
 	lastRefill time.Time
 	mutex     sync.Mutex
 }
@@ -36,6 +48,9 @@ type ChatRoom struct {
 	Clients   map[*Client]bool   `json:"-"`
 	Messages  []EncryptedMessage `json:"messages"`
 	CreatedAt time.Time          `json:"created_at"`
+	CipherSuite string           `json:"cipher_suite,omitempty"` // Negotiated E2E cipher suite
+	Fingerprints map[string]string `json:"fingerprints,omitempty"` // Latest key fingerprint per user ID
+	PasswordHash string          `json:"-"` // Argon2id hash of the room password, empty for open rooms
 	mutex     sync.RWMutex
 }
 
@@ -45,6 +60,30 @@ type Client struct {
 	UserID   string
 	UserName string
 	Send     chan []byte
+	Algorithms []string  // Cipher suites the client supports, in preference order
+	KeyFingerprint string // Fingerprint of the room key as published by the client
+	PublicKey   string    // X25519 public key (base64) relayed to members for key exchange
+	ReplaySince time.Time // Only messages after this are replayed on join; zero replays the last 50
+	JoinedAt   time.Time
+	done       chan struct{} // Closed by disconnect to make the transport drop the client
+	doneOnce   sync.Once
+}
+
+// newClient returns a client for conn, or for an SSE stream when conn is nil.
+// Send is never closed, so queueing a frame cannot panic; the transport ends
+// the connection once done is closed.
+func newClient(conn *websocket.Conn) *Client {
+	return &Client{
+		Conn: conn,
+		Send: make(chan []byte, 256),
+		done: make(chan struct{}),
+	}
+}
+
+// disconnect asks the client's transport to deliver the frames already
+// queued and then close the connection. It is safe to call more than once.
+func (c *Client) disconnect() {
+	c.doneOnce.Do(func() { close(c.done) })
 }
 
 type EncryptedMessage struct {
@@ -57,6 +96,19 @@ type EncryptedMessage struct {
 	MessageID string    `json:"message_id"`
 	ExpiresAt time.Time `json:"expires_at"`
 	Size      int       `json:"size"`
+	CipherSuite string  `json:"cipher_suite,omitempty"`
+	Roster    []RosterEntry `json:"roster,omitempty"`
+	PublicKey   string  `json:"public_key,omitempty"` // Member's X25519 public key in "key_exchange" frames
+	Users     []string  `json:"users,omitempty"` // Member names, sent with "room_users"
+}
+
+// RosterEntry describes one room member. Mismatch is set when the member's
+// key fingerprint differs from the one most members published, which points
+// at a wrong key or a man in the middle.
+type RosterEntry struct {
+	User           string `json:"user"`
+	KeyFingerprint string `json:"key_fingerprint,omitempty"`
+	Mismatch       bool   `json:"mismatch,omitempty"`
 }
 
 type MessageConfig struct {
@@ -64,6 +116,21 @@ type MessageConfig struct {
 	MessageExpiration time.Duration // Message expiration time
 	RateLimit         int           // Messages per minute per user
 	MaxRoomMessages   int           // Maximum messages stored per room
+	MaxConnections    int           // Maximum concurrent WebSocket connections
+	MaxClientsPerRoom int           // Maximum clients in a single room (CHAT_MAX_CLIENTS_PER_ROOM)
+	IVLengths         []int         // Accepted decoded IV lengths in bytes (CHAT_IV_LENGTHS)
+	MinCiphertextSize int           // Minimum decoded ciphertext size in bytes (CHAT_MIN_CIPHERTEXT_BYTES)
+}
+
+// WSRejection is sent as JSON instead of upgrading when a WebSocket
+// connection is refused, so clients can tell transient failures (retry later)
+// from permanent ones (don't retry).
+type WSRejection struct {
+	Type       string `json:"type"`   // Always "rejected"
+	Reason     string `json:"reason"` // Machine-readable reason code
+	Message    string `json:"message"`
+	Retry      bool   `json:"retry"`
+	RetryAfter int    `json:"retry_after,omitempty"` // Seconds to wait before retrying
 }
 
 type WSMessage struct {
@@ -73,6 +140,33 @@ type WSMessage struct {
 	Encrypted string `json:"encrypted"`
 	IV        string `json:"iv"`
 	Timestamp string `json:"timestamp"`
+	Algorithms []string `json:"algorithms,omitempty"` // Supported cipher suites, sent with "join"
+	KeyFingerprint string `json:"key_fingerprint,omitempty"` // Room key fingerprint, sent with "join" or "key_fingerprint"
+	Password  string `json:"password,omitempty"` // Room password, sent with "join"
+	PublicKey string `json:"public_key,omitempty"` // X25519 public key, sent with "join" or "key_exchange"
+	Since     string `json:"since,omitempty"` // RFC 3339 timestamp of the last message seen, sent with "join" on reconnect
+}
+
+// ErrRoomPassword is returned by JoinRoom when a password-protected room is
+// joined without the correct password. The connection is closed afterwards.
+var ErrRoomPassword = fmt.Errorf("incorrect or missing room password")
+
+// maxKeyFingerprintLength bounds the fingerprint string a client may publish.
+const maxKeyFingerprintLength = 128
+
+const (
+	// wsEnvelopeOverhead is the room for the JSON fields around the encrypted
+	// payload (type, room, user, IV, timestamp, ...) in a WebSocket frame.
+	wsEnvelopeOverhead = 1024
+	// wsHardReadLimit is the largest frame read before the connection is
+	// dropped; smaller oversized frames are discarded with an error frame.
+	wsHardReadLimit = 1 << 20
+)
+
+// wsMessageLimit is the largest frame readPump accepts: a payload of
+// MaxMessageSize plus its envelope.
+func wsMessageLimit() int {
+	return messageConfig.MaxMessageSize + wsEnvelopeOverhead
 }
 
 var chatService *ChatService
@@ -81,19 +175,31 @@ var messageConfig = MessageConfig{
 	MessageExpiration: 24 * time.Hour, // Messages expire after 24 hours
 	RateLimit:         30,          // 30 messages per minute per user
 	MaxRoomMessages:   1000,        // Store max 1000 messages per room
+	MaxConnections:    1000,        // Accept at most 1000 concurrent sockets
+	MaxClientsPerRoom: 50,          // At most 50 participants per room
+	IVLengths:         []int{12},   // AES-GCM nonces
+	MinCiphertextSize: 16,          // At least the AES-GCM authentication tag
 }
 
 func init() {
+	if n, err := strconv.Atoi(os.Getenv("CHAT_MAX_CLIENTS_PER_ROOM")); err == nil && n > 0 {
+		messageConfig.MaxClientsPerRoom = n
+	}
+	if lengths := parseIVLengths(os.Getenv("CHAT_IV_LENGTHS")); len(lengths) > 0 {
+		messageConfig.IVLengths = lengths
+	}
+	if n, err := strconv.Atoi(os.Getenv("CHAT_MIN_CIPHERTEXT_BYTES")); err == nil && n >= 0 {
+		messageConfig.MinCiphertextSize = n
+	}
+	
 	chatService = &ChatService{
 		rooms: make(map[string]*ChatRoom),
 		rateLimiter: make(map[string]*RateLimiter),
+		draining: make(chan struct{}),
 		upgrader: websocket.Upgrader{
 			ReadBufferSize:  1024,
 			WriteBufferSize: 1024,
-			CheckOrigin: func(r *http.Request) bool {
-				// Allow all origins for now (should be more restrictive in production)
-				return true
-			},
+			CheckOrigin:     checkWebSocketOrigin,
 		},
 	}
 	
@@ -105,51 +211,283 @@ func init() {
 	go chatService.expiredMessageCleanup()
 }
 
+// chatRequiresRedis reports whether chat must fail closed when Redis is
+// unavailable (CHAT_REQUIRE_REDIS=true) instead of falling back to memory.
+func chatRequiresRedis() bool {
+	return os.Getenv("CHAT_REQUIRE_REDIS") == "true"
+}
+
 func GetChatService() *ChatService {
 	return chatService
 }
 
-// SetRedisClient sets the Redis client for chat service
+// Shutdown stops admitting chat connections and asks every open one to
+// close: WebSocket clients get a "going away" close frame and SSE streams
+// end, after which each connection leaves its room as on a normal
+// disconnect. It waits until all connections are gone or ctx is done.
+func (cs *ChatService) Shutdown(ctx context.Context) error {
+	cs.drainOnce.Do(func() { close(cs.draining) })
+
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for atomic.LoadInt64(&cs.activeConnections) > 0 {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%d chat connections still open: %v", atomic.LoadInt64(&cs.activeConnections), ctx.Err())
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// SetRedisClient sets the Redis client for chat service and restores the
+// rooms whose metadata is still in Redis, e.g. after a restart.
 func (cs *ChatService) SetRedisClient(client *redis.Client) {
 	cs.redisClient = client
+	cs.loadRoomsFromRedis()
 }
 
-// HandleWebSocket upgrades HTTP connection to WebSocket
+// HandleWebSocket upgrades HTTP connection to WebSocket. Requests with
+// ?probe=1 only report whether a connection would currently be accepted,
+// which lets browsers learn why an upgrade failed.
 func (cs *ChatService) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
-	conn, err := cs.upgrader.Upgrade(w, r, nil)
+	probe := r.URL.Query().Get("probe") == "1"
+
+	if !probe && !websocket.IsWebSocketUpgrade(r) {
+		writeWSRejection(w, http.StatusBadRequest, &WSRejection{
+			Reason:  "not_websocket",
+			Message: "This endpoint only accepts WebSocket connections",
+		})
+		return
+	}
+
+	if status, rejection := cs.checkAdmission(r); rejection != nil {
+		log.Printf("WebSocket connection from %s rejected: %s", r.RemoteAddr, rejection.Reason)
+		writeWSRejection(w, status, rejection)
+		return
+	}
+
+	if probe {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"type": "accepted"})
+		return
+	}
+
+	conn, err := cs.upgrader.Upgrade(w, r, wsResponseHeader(r))
 	if err != nil {
 		log.Printf("WebSocket upgrade error: %v", err)
 		return
 	}
+	atomic.AddInt64(&cs.activeConnections, 1)
 	
-	client := &Client{
-		Conn: conn,
-		Send: make(chan []byte, 256),
-	}
+	client := newClient(conn)
 	
 	// Start goroutines for reading and writing
-	go client.writePump()
+	go client.writePump(cs.draining)
 	go client.readPump(cs)
 }
 
-// CreateRoom creates a new chat room
-func (cs *ChatService) CreateRoom(roomID, roomName string) *ChatRoom {
-	cs.roomMutex.Lock()
-	defer cs.roomMutex.Unlock()
-	
-	room := &ChatRoom{
+// checkAdmission decides whether a new WebSocket connection may be opened.
+// It returns the HTTP status and rejection details when it may not.
+func (cs *ChatService) checkAdmission(r *http.Request) (int, *WSRejection) {
+	select {
+	case <-cs.draining:
+		return http.StatusServiceUnavailable, &WSRejection{
+			Reason:     "shutting_down",
+			Message:    "The chat server is restarting, please reconnect shortly",
+			Retry:      true,
+			RetryAfter: 5,
+		}
+	default:
+	}
+
+	if !cs.upgrader.CheckOrigin(r) {
+		return http.StatusForbidden, &WSRejection{
+			Reason:  "origin_not_allowed",
+			Message: "Connections from this origin are not allowed",
+		}
+	}
+
+	if status, rejection := checkWebSocketToken(r); rejection != nil {
+		return status, rejection
+	}
+
+	if atomic.LoadInt64(&cs.activeConnections) >= int64(messageConfig.MaxConnections) {
+		return http.StatusServiceUnavailable, &WSRejection{
+			Reason:     "server_overloaded",
+			Message:    "The chat server is at capacity, please try again shortly",
+			Retry:      true,
+			RetryAfter: 10,
+		}
+	}
+
+	return http.StatusOK, nil
+}
+
+// checkWebSocketOrigin accepts same-origin requests, requests without an
+// Origin header (non-browser clients) and origins listed in ALLOWED_ORIGINS,
+// e.g. "https://zeepass.example.com,https://chat.example.com". Dev mode
+// accepts every origin.
+func checkWebSocketOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || IsDevMode() {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+
+	normalized := strings.ToLower(u.Scheme + "://" + u.Host)
+	for _, allowed := range strings.Split(os.Getenv("ALLOWED_ORIGINS"), ",") {
+		allowed = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(allowed), "/"))
+		if allowed != "" && allowed == normalized {
+			return true
+		}
+	}
+	return false
+}
+
+// wsTokenProtocol is the Sec-WebSocket-Protocol prefix that carries an
+// access token, e.g. "zeepass.token.<token>". Browsers cannot set headers on
+// WebSocket requests, so embeds pass the token as a subprotocol, alongside
+// wsProtocol which the server selects in its response.
+const (
+	wsProtocol      = "zeepass"
+	wsTokenProtocol = "zeepass.token."
+)
+
+// checkWebSocketToken requires one of the tokens in CHAT_ACCESS_TOKENS
+// (comma separated) from every client once tokens are configured, so only
+// authorized users and embeds can connect. Same-origin requests are not
+// exempt: non-browser clients can send any Origin. The token is read from the
+// "token" query parameter or a "zeepass.token.<token>" subprotocol; the chat
+// page passes on the token it was opened with. Dev mode needs no token. A
+// missing token is rejected with 401, an unknown one with 403.
+func checkWebSocketToken(r *http.Request) (int, *WSRejection) {
+	configured := os.Getenv("CHAT_ACCESS_TOKENS")
+	if strings.TrimSpace(configured) == "" || IsDevMode() {
+		return http.StatusOK, nil
+	}
+
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		for _, protocol := range websocket.Subprotocols(r) {
+			if value, ok := strings.CutPrefix(protocol, wsTokenProtocol); ok {
+				token = value
+				break
+			}
+		}
+	}
+	if token == "" {
+		return http.StatusUnauthorized, &WSRejection{
+			Reason:  "token_required",
+			Message: "An access token is required to connect",
+		}
+	}
+
+	valid := false
+	for _, allowed := range strings.Split(configured, ",") {
+		// Compare against every token so timing does not reveal which matched
+		if allowed = strings.TrimSpace(allowed); allowed != "" && SecureCompare(token, allowed) {
+			valid = true
+		}
+	}
+	if !valid {
+		return http.StatusForbidden, &WSRejection{
+			Reason:  "token_invalid",
+			Message: "The access token is not valid",
+		}
+	}
+	return http.StatusOK, nil
+}
+
+// wsResponseHeader selects the subprotocol for the upgrade response. A
+// browser fails the connection unless the server picks one of the offered
+// subprotocols, so a client that sent its token as a subprotocol gets
+// wsProtocol back, or the token subprotocol if it offered nothing else.
+func wsResponseHeader(r *http.Request) http.Header {
+	selected := ""
+	for _, protocol := range websocket.Subprotocols(r) {
+		if protocol == wsProtocol {
+			selected = protocol
+			break
+		}
+		if selected == "" && strings.HasPrefix(protocol, wsTokenProtocol) {
+			selected = protocol
+		}
+	}
+	if selected == "" {
+		return nil
+	}
+	return http.Header{"Sec-Websocket-Protocol": {selected}}
+}
+
+func writeWSRejection(w http.ResponseWriter, status int, rejection *WSRejection) {
+	rejection.Type = "rejected"
+	if rejection.RetryAfter > 0 {
+		w.Header().Set("Retry-After", fmt.Sprintf("%d", rejection.RetryAfter))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(rejection)
+}
+
+func newChatRoom(roomID, roomName string) *ChatRoom {
+	return &ChatRoom{
 		ID:        roomID,
 		Name:      roomName,
 		Clients:   make(map[*Client]bool),
+		Fingerprints: make(map[string]string),
 		Messages:  make([]EncryptedMessage, 0),
 		CreatedAt: time.Now(),
 	}
+}
+
+// CreateRoom creates a new chat room
+func (cs *ChatService) CreateRoom(roomID, roomName string) *ChatRoom {
+	cs.roomMutex.Lock()
+	defer cs.roomMutex.Unlock()
 	
+	room := newChatRoom(roomID, roomName)
 	cs.rooms[roomID] = room
+	cs.storeRoomMeta(room)
 	log.Printf("Created room: %s (%s)", roomName, roomID)
 	return room
 }
 
+// getOrCreateRoom returns the room, creating it if needed. A room whose
+// metadata another instance (or this one before a restart) wrote to Redis is
+// restored with its name, creation time and password; otherwise a new room
+// takes password. setPassword reports whether this caller set it.
+func (cs *ChatService) getOrCreateRoom(roomID, password string) (*ChatRoom, bool) {
+	cs.roomMutex.Lock()
+	defer cs.roomMutex.Unlock()
+	
+	if room := cs.rooms[roomID]; room != nil {
+		return room, false
+	}
+	
+	if room := cs.loadRoomMeta(roomID); room != nil {
+		cs.rooms[roomID] = room
+		log.Printf("Restored room: %s (%s)", room.Name, roomID)
+		return room, false
+	}
+	
+	room, setPassword := newChatRoom(roomID, "Chat Room"), false
+	if password != "" {
+		room.PasswordHash, setPassword = HashPIN(password), true
+	}
+	cs.rooms[roomID] = room
+	cs.storeRoomMeta(room)
+	log.Printf("Created room: %s (%s)", room.Name, roomID)
+	return room, setPassword
+}
+
 // GetRoom retrieves a room by ID
 func (cs *ChatService) GetRoom(roomID string) *ChatRoom {
 	cs.roomMutex.RLock()
@@ -157,36 +495,139 @@ func (cs *ChatService) GetRoom(roomID string) *ChatRoom {
 	return cs.rooms[roomID]
 }
 
-// JoinRoom adds a client to a room
-func (cs *ChatService) JoinRoom(client *Client, roomID, userID, userName string) error {
+// RoomSummary describes an active room for monitoring.
+type RoomSummary struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	Participants int       `json:"participants"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// ListRooms returns a summary of every room, oldest first.
+func (cs *ChatService) ListRooms() []RoomSummary {
 	cs.roomMutex.RLock()
-	room := cs.rooms[roomID]
+	rooms := make([]*ChatRoom, 0, len(cs.rooms))
+	for _, room := range cs.rooms {
+		rooms = append(rooms, room)
+	}
 	cs.roomMutex.RUnlock()
 	
-	if room == nil {
-		// Room doesn't exist, create it
-		room = cs.CreateRoom(roomID, "Chat Room")
+	summaries := make([]RoomSummary, 0, len(rooms))
+	for _, room := range rooms {
+		room.mutex.RLock()
+		summaries = append(summaries, RoomSummary{
+			ID:           room.ID,
+			Name:         room.Name,
+			Participants: len(room.Clients),
+			CreatedAt:    room.CreatedAt,
+		})
+		room.mutex.RUnlock()
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].CreatedAt.Before(summaries[j].CreatedAt)
+	})
+	return summaries
+}
+
+// JoinRoom adds a client to a room. The first client to join creates the
+// room; if it supplies a password, later clients must present the same one.
+func (cs *ChatService) JoinRoom(client *Client, roomID, userID, userName, password string) error {
+	room, setPassword := cs.getOrCreateRoom(roomID, password)
+	
+	if room.PasswordHash != "" && !setPassword && !VerifyPIN(password, room.PasswordHash) {
+		log.Printf("Rejected join to password-protected room %s", roomID)
+		return ErrRoomPassword
 	}
 	
 	room.mutex.Lock()
 	defer room.mutex.Unlock()
 	
+	if len(room.Clients) >= messageConfig.MaxClientsPerRoom {
+		return fmt.Errorf("room is full (max %d participants)", messageConfig.MaxClientsPerRoom)
+	}
+	
+	client.JoinedAt = time.Now()
+	room.Clients[client] = true
+	
+	// The new member may narrow the set of suites everyone supports
+	suite, ok := negotiateCipherSuite(room.Clients)
+	if !ok {
+		delete(room.Clients, client)
+		return fmt.Errorf("no common cipher suite with room (room uses %s)", room.CipherSuite)
+	}
+	
 	client.Room = room
 	client.UserID = userID
 	client.UserName = userName
-	room.Clients[client] = true
+	if client.KeyFingerprint != "" {
+		room.Fingerprints[userID] = client.KeyFingerprint
+	}
 	
 	log.Printf("User %s (%s) joined room %s", userName, userID, roomID)
 	
+	// Tell the room about a changed suite, or just the newcomer about the current one
+	if suite != room.CipherSuite {
+		room.CipherSuite = suite
+		cs.broadcastCipherSuite(room)
+	} else if suite != "" {
+		client.sendCipherSuite(room)
+	}
+	
 	// Send recent messages to new client
 	go cs.sendRecentMessages(client, room)
 	
 	// Notify other clients
 	cs.broadcastUserJoined(room, userName)
+	cs.broadcastRoster(room)
+	cs.exchangePublicKeys(room, client)
 	
 	return nil
 }
 
+// UpdatePublicKey records a new X25519 public key for the client and relays
+// it to the other members, e.g. after the client generated a fresh key pair.
+func (cs *ChatService) UpdatePublicKey(client *Client, publicKey string) error {
+	if err := validatePublicKey(publicKey); err != nil {
+		return err
+	}
+	room := client.Room
+	if room == nil {
+		return fmt.Errorf("not in a room")
+	}
+	
+	room.mutex.Lock()
+	defer room.mutex.Unlock()
+	
+	client.PublicKey = publicKey
+	frame := keyExchangeFrame(room, client)
+	for member := range room.Clients {
+		if member != client {
+			member.sendFrame(frame)
+		}
+	}
+	return nil
+}
+
+// UpdateKeyFingerprint records a new key fingerprint for the client, e.g.
+// after a key rotation, and shares the updated roster with the room.
+func (cs *ChatService) UpdateKeyFingerprint(client *Client, fingerprint string) error {
+	if err := validateKeyFingerprint(fingerprint); err != nil {
+		return err
+	}
+	room := client.Room
+	if room == nil {
+		return fmt.Errorf("not in a room")
+	}
+	
+	room.mutex.Lock()
+	defer room.mutex.Unlock()
+	
+	client.KeyFingerprint = fingerprint
+	room.Fingerprints[client.UserID] = fingerprint
+	cs.broadcastRoster(room)
+	return nil
+}
+
 // LeaveRoom removes a client from a room
 func (cs *ChatService) LeaveRoom(client *Client) {
 	if client.Room == nil {
@@ -194,25 +635,50 @@ func (cs *ChatService) LeaveRoom(client *Client) {
 	}
 	
 	room := client.Room
+	if cs.removeClient(room, client) {
+		cs.deleteRoomIfEmpty(room)
+	}
+}
+
+// removeClient takes client out of room and tells the remaining members. It
+// reports whether the room is now empty.
+func (cs *ChatService) removeClient(room *ChatRoom, client *Client) bool {
 	room.mutex.Lock()
 	defer room.mutex.Unlock()
 	
 	if _, ok := room.Clients[client]; ok {
 		delete(room.Clients, client)
-		close(client.Send)
+		delete(room.Fingerprints, client.UserID)
+		client.disconnect()
 		
 		log.Printf("User %s left room %s", client.UserName, room.ID)
 		
 		// Notify other clients
 		cs.broadcastUserLeft(room, client.UserName)
+		cs.broadcastRoster(room)
 		
-		// Clean up empty room
-		if len(room.Clients) == 0 {
-			cs.roomMutex.Lock()
-			delete(cs.rooms, room.ID)
-			cs.roomMutex.Unlock()
-			log.Printf("Deleted empty room: %s", room.ID)
+		// The remaining members may now share a more preferred suite
+		if suite, ok := negotiateCipherSuite(room.Clients); ok && suite != room.CipherSuite && suite != "" {
+			room.CipherSuite = suite
+			cs.broadcastCipherSuite(room)
 		}
+		return len(room.Clients) == 0
+	}
+	return false
+}
+
+// deleteRoomIfEmpty removes room from the room list unless someone joined it
+// in the meantime. It must be called without holding room.mutex.
+func (cs *ChatService) deleteRoomIfEmpty(room *ChatRoom) {
+	cs.roomMutex.Lock()
+	defer cs.roomMutex.Unlock()
+	
+	room.mutex.RLock()
+	empty := len(room.Clients) == 0
+	room.mutex.RUnlock()
+	if empty && cs.rooms[room.ID] == room {
+		delete(cs.rooms, room.ID)
+		log.Printf("Deleted empty room: %s", room.ID)
 	}
 }
 
@@ -228,6 +694,10 @@ func (cs *ChatService) BroadcastMessage(room *ChatRoom, message EncryptedMessage
 		return fmt.Errorf("message too large: %d bytes (max: %d)", len(message.Encrypted), messageConfig.MaxMessageSize)
 	}
 	
+	if err := validateEncryptedPayload(message.Encrypted, message.IV); err != nil {
+		return err
+	}
+	
 	room.mutex.Lock()
 	defer room.mutex.Unlock()
 	
@@ -236,21 +706,31 @@ func (cs *ChatService) BroadcastMessage(room *ChatRoom, message EncryptedMessage
 	message.Timestamp = time.Now()
 	message.ExpiresAt = time.Now().Add(messageConfig.MessageExpiration)
 	message.Size = len(message.Encrypted)
+	message.CipherSuite = room.CipherSuite
 	
-	// Store message in Redis (if available)
-	if cs.redisClient != nil {
-		if err := cs.storeMessageInRedis(message); err != nil {
-			log.Printf("Failed to store message in Redis: %v", err)
-			// Continue without Redis - messages will be stored in memory
+	// Store message in Redis (if available). In strict mode the message is
+	// refused rather than kept only in this instance's memory.
+	strict := chatRequiresRedis()
+	if cs.redisClient == nil {
+		if strict {
+			return fmt.Errorf("chat storage unavailable, message not sent")
+		}
+	} else if err := cs.storeMessageInRedis(message); err != nil {
+		log.Printf("Failed to store message in Redis: %v", err)
+		if strict {
+			return fmt.Errorf("chat storage unavailable, message not sent")
 		}
+		// Continue without Redis - messages will be stored in memory
 	}
 	
-	// Also keep in memory for active clients (fallback)
-	room.Messages = append(room.Messages, message)
-	
-	// Keep only recent messages in memory
-	if len(room.Messages) > 100 {
-		room.Messages = room.Messages[len(room.Messages)-100:]
+	if !strict {
+		// Also keep in memory for active clients (fallback)
+		room.Messages = append(room.Messages, message)
+		
+		// Keep only recent messages in memory
+		if len(room.Messages) > 100 {
+			room.Messages = room.Messages[len(room.Messages)-100:]
+		}
 	}
 	
 	// Broadcast to all clients
@@ -266,19 +746,23 @@ func (cs *ChatService) BroadcastMessage(room *ChatRoom, message EncryptedMessage
 		default:
 			// Client channel is full, remove client
 			delete(room.Clients, client)
-			close(client.Send)
+			client.disconnect()
 		}
 	}
 	
 	return nil
 }
 
+// sendRecentMessages replays history to a client that just joined: the last
+// 50 messages, or when client.ReplaySince is set, every stored message after
+// it so a reconnecting client gets what it missed without duplicates.
 func (cs *ChatService) sendRecentMessages(client *Client, room *ChatRoom) {
 	var messages []EncryptedMessage
+	since := client.ReplaySince
 	
 	// Try to get messages from Redis first (if Redis is available)
 	if cs.redisClient != nil {
-		redisMessages, err := cs.getMessagesFromRedis(room.ID, 50)
+		redisMessages, err := cs.getMessagesFromRedis(room.ID, since, 50)
 		if err != nil {
 			log.Printf("Failed to get messages from Redis: %v", err)
 		} else {
@@ -292,12 +776,16 @@ func (cs *ChatService) sendRecentMessages(client *Client, room *ChatRoom) {
 		messages = room.Messages
 		room.mutex.RUnlock()
 		
-		// Send last 50 messages
-		start := 0
-		if len(messages) > 50 {
-			start = len(messages) - 50
+		if !since.IsZero() {
+			messages = messagesAfter(messages, since)
+		} else {
+			// Send last 50 messages
+			start := 0
+			if len(messages) > 50 {
+				start = len(messages) - 50
+			}
+			messages = messages[start:]
 		}
-		messages = messages[start:]
 	}
 	
 	for _, message := range messages {
@@ -319,11 +807,63 @@ func (cs *ChatService) sendRecentMessages(client *Client, room *ChatRoom) {
 	}
 }
 
+// messagesAfter returns the messages with a timestamp strictly after since.
+// messages must be in chronological order.
+func messagesAfter(messages []EncryptedMessage, since time.Time) []EncryptedMessage {
+	i := sort.Search(len(messages), func(i int) bool {
+		return messages[i].Timestamp.After(since)
+	})
+	return messages[i:]
+}
+
+// roomMembers returns the room's named members in join order. The caller must
+// hold room.mutex.
+func roomMembers(room *ChatRoom) []*Client {
+	members := make([]*Client, 0, len(room.Clients))
+	for client := range room.Clients {
+		if client.UserName != "" {
+			members = append(members, client)
+		}
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].JoinedAt.Before(members[j].JoinedAt)
+	})
+	return members
+}
+
+// broadcastRoomUsers sends the current list of member names to everyone in
+// the room, including a client that has just joined. The caller must hold
+// room.mutex.
+func (cs *ChatService) broadcastRoomUsers(room *ChatRoom) {
+	members := roomMembers(room)
+	users := make([]string, 0, len(members))
+	for _, member := range members {
+		users = append(users, member.UserName)
+	}
+	
+	notification := EncryptedMessage{
+		Type:      "room_users",
+		Room:      room.ID,
+		User:      "system",
+		Timestamp: time.Now(),
+		Users:     users,
+	}
+	
+	messageData, _ := json.Marshal(notification)
+	
+	for client := range room.Clients {
+		select {
+		case client.Send <- messageData:
+		default:
+		}
+	}
+}
+
 func (cs *ChatService) broadcastUserJoined(room *ChatRoom, userName string) {
 	notification := EncryptedMessage{
 		Type:      "user_joined",
 		Room:      room.ID,
-		User:      "system",
+		User:      userName,
 		Encrypted: "",
 		IV:        "",
 		Timestamp: time.Now(),
@@ -337,13 +877,14 @@ func (cs *ChatService) broadcastUserJoined(room *ChatRoom, userName string) {
 		default:
 		}
 	}
+	cs.broadcastRoomUsers(room)
 }
 
 func (cs *ChatService) broadcastUserLeft(room *ChatRoom, userName string) {
 	notification := EncryptedMessage{
 		Type:      "user_left",
 		Room:      room.ID,
-		User:      "system",
+		User:      userName,
 		Encrypted: "",
 		IV:        "",
 		Timestamp: time.Now(),
@@ -357,6 +898,279 @@ func (cs *ChatService) broadcastUserLeft(room *ChatRoom, userName string) {
 		default:
 		}
 	}
+	cs.broadcastRoomUsers(room)
+}
+
+// validateKeyFingerprint accepts hex, base64 or digit-group fingerprints. The
+// server only relays them and never sees the key itself.
+func validateKeyFingerprint(fingerprint string) error {
+	if len(fingerprint) > maxKeyFingerprintLength {
+		return fmt.Errorf("key fingerprint too long (max %d characters)", maxKeyFingerprintLength)
+	}
+	for _, r := range fingerprint {
+		switch {
+		case r >= '0' && r <= '9', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		case strings.ContainsRune(" :+/=-", r):
+		default:
+			return fmt.Errorf("key fingerprint contains invalid character %q", r)
+		}
+	}
+	return nil
+}
+
+// validatePublicKey accepts an empty key or a base64 encoded 32-byte X25519
+// public key. The server relays keys without storing them.
+func validatePublicKey(publicKey string) error {
+	if publicKey == "" {
+		return nil
+	}
+	raw, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil || len(raw) != 32 {
+		return fmt.Errorf("public key must be a base64 encoded 32-byte X25519 key")
+	}
+	return nil
+}
+
+func keyExchangeFrame(room *ChatRoom, member *Client) []byte {
+	notification := EncryptedMessage{
+		Type:      "key_exchange",
+		Room:      room.ID,
+		User:      member.UserName,
+		Timestamp: time.Now(),
+		PublicKey: member.PublicKey,
+	}
+	messageData, _ := json.Marshal(notification)
+	return messageData
+}
+
+// exchangePublicKeys sends the newcomer's public key to the other members and
+// replays theirs to the newcomer, so every pair can derive a shared secret.
+// The caller must hold room.mutex.
+func (cs *ChatService) exchangePublicKeys(room *ChatRoom, newcomer *Client) {
+	var frame []byte
+	if newcomer.PublicKey != "" {
+		frame = keyExchangeFrame(room, newcomer)
+	}
+	for _, member := range roomMembers(room) {
+		if member == newcomer {
+			continue
+		}
+		if member.PublicKey != "" {
+			newcomer.sendFrame(keyExchangeFrame(room, member))
+		}
+		if frame != nil {
+			member.sendFrame(frame)
+		}
+	}
+}
+
+// buildRoster lists the room's members in join order with their published
+// key fingerprints. Members whose fingerprint differs from the one most
+// members share are flagged; if no fingerprint has a clear majority, every
+// member with a fingerprint is flagged. The caller must hold room.mutex.
+func buildRoster(room *ChatRoom) []RosterEntry {
+	members := roomMembers(room)
+	
+	counts := make(map[string]int)
+	for _, member := range members {
+		if fp := room.Fingerprints[member.UserID]; fp != "" {
+			counts[fp]++
+		}
+	}
+	majority, best, tied := "", 0, false
+	for fp, n := range counts {
+		switch {
+		case n > best:
+			majority, best, tied = fp, n, false
+		case n == best:
+			tied = true
+		}
+	}
+	if tied {
+		majority = ""
+	}
+	
+	roster := make([]RosterEntry, 0, len(members))
+	for _, member := range members {
+		fp := room.Fingerprints[member.UserID]
+		roster = append(roster, RosterEntry{
+			User:           member.UserName,
+			KeyFingerprint: fp,
+			Mismatch:       fp != "" && len(counts) > 1 && fp != majority,
+		})
+	}
+	return roster
+}
+
+// broadcastRoster sends the member list with key fingerprints to the room.
+// The caller must hold room.mutex.
+func (cs *ChatService) broadcastRoster(room *ChatRoom) {
+	notification := EncryptedMessage{
+		Type:      "roster",
+		Room:      room.ID,
+		User:      "system",
+		Timestamp: time.Now(),
+		Roster:    buildRoster(room),
+	}
+	
+	messageData, _ := json.Marshal(notification)
+	
+	for client := range room.Clients {
+		select {
+		case client.Send <- messageData:
+		default:
+		}
+	}
+}
+
+// validateEncryptedPayload rejects messages whose ciphertext or IV is not
+// standard base64, whose IV has none of the lengths in
+// messageConfig.IVLengths, or whose ciphertext is shorter than
+// MinCiphertextSize. The server cannot decrypt messages, so this only
+// catches payloads no client could decrypt either.
+func validateEncryptedPayload(encrypted, iv string) error {
+	if iv == "" {
+		return fmt.Errorf("invalid message: missing IV")
+	}
+	ivBytes, err := base64.StdEncoding.DecodeString(iv)
+	if err != nil {
+		return fmt.Errorf("invalid message: IV is not valid base64")
+	}
+	validIV := false
+	for _, length := range messageConfig.IVLengths {
+		if len(ivBytes) == length {
+			validIV = true
+			break
+		}
+	}
+	if !validIV {
+		return fmt.Errorf("invalid message: IV must be %s bytes", joinInts(messageConfig.IVLengths, " or "))
+	}
+	
+	if encrypted == "" {
+		return fmt.Errorf("invalid message: missing ciphertext")
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return fmt.Errorf("invalid message: ciphertext is not valid base64")
+	}
+	if len(ciphertext) < messageConfig.MinCiphertextSize {
+		return fmt.Errorf("invalid message: ciphertext shorter than %d bytes", messageConfig.MinCiphertextSize)
+	}
+	return nil
+}
+
+// parseIVLengths parses a comma separated list of byte lengths such as
+// "12,24", ignoring entries that are not positive numbers.
+func parseIVLengths(value string) []int {
+	var lengths []int
+	for _, field := range strings.Split(value, ",") {
+		if n, err := strconv.Atoi(strings.TrimSpace(field)); err == nil && n > 0 {
+			lengths = append(lengths, n)
+		}
+	}
+	return lengths
+}
+
+func joinInts(values []int, separator string) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = strconv.Itoa(v)
+	}
+	return strings.Join(parts, separator)
+}
+
+// negotiateCipherSuite picks the room's cipher suite: the first suite in the
+// earliest member's preference list that every advertising member supports.
+// The server only compares names and never takes part in the encryption.
+// It returns "" when no member advertises suites, and false when the
+// advertised lists have nothing in common.
+func negotiateCipherSuite(clients map[*Client]bool) (string, bool) {
+	var members []*Client
+	for client := range clients {
+		if len(client.Algorithms) > 0 {
+			members = append(members, client)
+		}
+	}
+	if len(members) == 0 {
+		return "", true
+	}
+	
+	first := members[0]
+	for _, member := range members[1:] {
+		if member.JoinedAt.Before(first.JoinedAt) {
+			first = member
+		}
+	}
+	
+	for _, suite := range first.Algorithms {
+		supported := true
+		for _, member := range members {
+			if !containsString(member.Algorithms, suite) {
+				supported = false
+				break
+			}
+		}
+		if supported {
+			return suite, true
+		}
+	}
+	return "", false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// broadcastCipherSuite announces the room's negotiated cipher suite to all
+// members. The caller must hold room.mutex.
+func (cs *ChatService) broadcastCipherSuite(room *ChatRoom) {
+	for client := range room.Clients {
+		client.sendCipherSuite(room)
+	}
+}
+
+func (c *Client) sendCipherSuite(room *ChatRoom) {
+	notification := EncryptedMessage{
+		Type:        "cipher_suite",
+		Room:        room.ID,
+		User:        "system",
+		Timestamp:   time.Now(),
+		CipherSuite: room.CipherSuite,
+	}
+	
+	messageData, _ := json.Marshal(notification)
+	select {
+	case c.Send <- messageData:
+	default:
+	}
+}
+
+// sendFrame queues data for the client, dropping it if the buffer is full.
+func (c *Client) sendFrame(data []byte) {
+	select {
+	case c.Send <- data:
+	default:
+	}
+}
+
+// sendError sends an error frame to the client
+func (c *Client) sendError(message string) {
+	errorMsg := map[string]string{
+		"type": "error",
+		"message": message,
+	}
+	if errorData, err := json.Marshal(errorMsg); err == nil {
+		select {
+		case c.Send <- errorData:
+		default:
+		}
+	}
 }
 
 // Client methods
@@ -364,17 +1178,19 @@ func (c *Client) readPump(cs *ChatService) {
 	defer func() {
 		cs.LeaveRoom(c)
 		c.Conn.Close()
+		atomic.AddInt64(&cs.activeConnections, -1)
 	}()
 	
-	c.Conn.SetReadLimit(512)
+	c.Conn.SetReadLimit(wsHardReadLimit)
 	c.Conn.SetReadDeadline(time.Now().Add(60 * time.Second))
 	c.Conn.SetPongHandler(func(string) error {
 		c.Conn.SetReadDeadline(time.Now().Add(60 * time.Second))
 		return nil
 	})
 	
+	rejected := false
 	for {
-		_, messageData, err := c.Conn.ReadMessage()
+		messageData, tooLarge, err := c.readFrame()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("WebSocket error: %v", err)
@@ -382,47 +1198,121 @@ func (c *Client) readPump(cs *ChatService) {
 			break
 		}
 		
+		if rejected {
+			continue // Wait for writePump to close the connection
+		}
+		if tooLarge {
+			c.sendError(fmt.Sprintf("message too large (max: %d bytes)", messageConfig.MaxMessageSize))
+			continue
+		}
+		
 		var wsMsg WSMessage
 		if err := json.Unmarshal(messageData, &wsMsg); err != nil {
 			log.Printf("Error unmarshaling message: %v", err)
 			continue
 		}
 		
-		// Handle different message types
-		switch wsMsg.Type {
-		case "join":
-			cs.JoinRoom(c, wsMsg.Room, generateUserID(), wsMsg.User)
-		case "message":
-			if c.Room != nil {
-				timestamp, _ := time.Parse(time.RFC3339, wsMsg.Timestamp)
-				encMsg := EncryptedMessage{
-					Type:      "message",
-					Room:      wsMsg.Room,
-					User:      wsMsg.User,
-					Encrypted: wsMsg.Encrypted,
-					IV:        wsMsg.IV,
-					Timestamp: timestamp,
-				}
-				if err := cs.BroadcastMessage(c.Room, encMsg, c.UserID); err != nil {
-					log.Printf("Failed to broadcast message: %v", err)
-					// Send error back to client
-					errorMsg := map[string]string{
-						"type": "error",
-						"message": err.Error(),
-					}
-					if errorData, err := json.Marshal(errorMsg); err == nil {
-						select {
-						case c.Send <- errorData:
-						default:
-						}
-					}
-				}
+		if cs.handleClientMessage(c, wsMsg) {
+			rejected = true
+		}
+	}
+}
+
+// handleClientMessage acts on one frame from a client, whichever transport
+// it arrived on. It reports true when the client was refused and is being
+// disconnected, after which no more frames may be handled for it.
+func (cs *ChatService) handleClientMessage(c *Client, wsMsg WSMessage) bool {
+	switch wsMsg.Type {
+	case "join":
+		if c.Room != nil {
+			c.sendError("already joined a room")
+			return false
+		}
+		if err := validateKeyFingerprint(wsMsg.KeyFingerprint); err != nil {
+			c.sendError(err.Error())
+			return false
+		}
+		if err := validatePublicKey(wsMsg.PublicKey); err != nil {
+			c.sendError(err.Error())
+			return false
+		}
+		c.Algorithms = wsMsg.Algorithms
+		c.KeyFingerprint = wsMsg.KeyFingerprint
+		c.PublicKey = wsMsg.PublicKey
+		c.ReplaySince = time.Time{}
+		if wsMsg.Since != "" {
+			since, err := time.Parse(time.RFC3339Nano, wsMsg.Since)
+			if err != nil {
+				log.Printf("Ignoring invalid replay timestamp %q: %v", wsMsg.Since, err)
+			} else {
+				c.ReplaySince = since
+			}
+		}
+		err := cs.JoinRoom(c, wsMsg.Room, generateUserID(), wsMsg.User, wsMsg.Password)
+		if err == ErrRoomPassword {
+			// Send the error, then let the transport close the connection
+			c.sendError(err.Error())
+			c.disconnect()
+			return true
+		} else if err != nil {
+			log.Printf("Failed to join room %s: %v", wsMsg.Room, err)
+			c.sendError(err.Error())
+		}
+	case "key_fingerprint":
+		if err := cs.UpdateKeyFingerprint(c, wsMsg.KeyFingerprint); err != nil {
+			c.sendError(err.Error())
+		}
+	case "key_exchange":
+		if err := cs.UpdatePublicKey(c, wsMsg.PublicKey); err != nil {
+			c.sendError(err.Error())
+		}
+	case "message":
+		if c.Room != nil {
+			timestamp, _ := time.Parse(time.RFC3339, wsMsg.Timestamp)
+			encMsg := EncryptedMessage{
+				Type:      "message",
+				Room:      wsMsg.Room,
+				User:      wsMsg.User,
+				Encrypted: wsMsg.Encrypted,
+				IV:        wsMsg.IV,
+				Timestamp: timestamp,
+			}
+			if err := cs.BroadcastMessage(c.Room, encMsg, c.UserID); err != nil {
+				log.Printf("Failed to broadcast message: %v", err)
+				// Send error back to client
+				c.sendError(err.Error())
 			}
 		}
 	}
+	return false
+}
+
+// readFrame reads the next WebSocket message. A message over wsMessageLimit
+// is discarded and reported through tooLarge so the connection stays open.
+func (c *Client) readFrame() ([]byte, bool, error) {
+	_, reader, err := c.Conn.NextReader()
+	if err != nil {
+		return nil, false, err
+	}
+	
+	limit := wsMessageLimit()
+	data, err := io.ReadAll(io.LimitReader(reader, int64(limit)+1))
+	if err != nil {
+		return nil, false, err
+	}
+	if len(data) > limit {
+		if _, err := io.Copy(io.Discard, reader); err != nil {
+			return nil, false, err
+		}
+		return nil, true, nil
+	}
+	return data, false, nil
 }
 
-func (c *Client) writePump() {
+// writePump delivers queued frames to the WebSocket. When draining is closed
+// it sends a "going away" close frame and closes the connection, which ends
+// readPump.
+func (c *Client) writePump(draining <-chan struct{}) {
 	ticker := time.NewTicker(54 * time.Second)
 	defer func() {
 		ticker.Stop()
@@ -431,13 +1321,8 @@ func (c *Client) writePump() {
 	
 	for {
 		select {
-		case message, ok := <-c.Send:
+		case message := <-c.Send:
 			c.Conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
-			if !ok {
-				c.Conn.WriteMessage(websocket.CloseMessage, []byte{})
-				return
-			}
-			
 			w, err := c.Conn.NextWriter(websocket.TextMessage)
 			if err != nil {
 				return
@@ -460,6 +1345,24 @@ func (c *Client) writePump() {
 			if err := c.Conn.WriteMessage(websocket.PingMessage, nil); err != nil {
 				return
 			}
+
+		case <-c.done:
+			// Deliver what was queued before the disconnect, such as the
+			// error explaining it, then close
+			c.Conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			for n := len(c.Send); n > 0; n-- {
+				if err := c.Conn.WriteMessage(websocket.TextMessage, <-c.Send); err != nil {
+					return
+				}
+			}
+			c.Conn.WriteMessage(websocket.CloseMessage, []byte{})
+			return
+
+		case <-draining:
+			c.Conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			c.Conn.WriteMessage(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseGoingAway, "server restarting"))
+			return
 		}
 	}
 }
@@ -494,21 +1397,129 @@ func generateUserID() string {
 	return "user-" + generateRandomString(8)
 }
 
+// generateRandomString returns length characters drawn uniformly from an
+// alphanumeric charset using crypto/rand, so IDs cannot be predicted.
 func generateRandomString(length int) string {
 	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	charsetLen := big.NewInt(int64(len(charset)))
 	result := make([]byte, length)
 	for i := range result {
-		result[i] = charset[time.Now().UnixNano()%int64(len(charset))]
+		index, err := rand.Int(rand.Reader, charsetLen)
+		if err != nil {
+			panic(fmt.Sprintf("failed to generate random string: %v", err))
+		}
+		result[i] = charset[index.Int64()]
 	}
 	return string(result)
 }
 
 // Redis storage functions
+
+func chatMessageKey(roomID, messageID string) string {
+	return fmt.Sprintf("%smsg:%s:%s", RedisKeyPrefix(), roomID, messageID)
+}
+
+func chatRoomKey(roomID string) string {
+	return fmt.Sprintf("%sroom:%s:messages", RedisKeyPrefix(), roomID)
+}
+
+func chatRoomMetaKey(roomID string) string {
+	return fmt.Sprintf("%sroom:%s:meta", RedisKeyPrefix(), roomID)
+}
+
+// storeRoomMeta records a room's name, creation time and password hash in
+// Redis so rooms survive restarts and other instances enforce the same
+// password. It expires along with the room's messages.
+func (cs *ChatService) storeRoomMeta(room *ChatRoom) {
+	if cs.redisClient == nil {
+		return
+	}
+	ctx := context.Background()
+	metaKey := chatRoomMetaKey(room.ID)
+	fields := map[string]interface{}{
+		"name":       room.Name,
+		"created_at": room.CreatedAt.Format(time.RFC3339Nano),
+	}
+	if room.PasswordHash != "" {
+		fields["password_hash"] = room.PasswordHash
+	}
+	if err := cs.redisClient.HSet(ctx, metaKey, fields).Err(); err != nil {
+		log.Printf("Failed to store room metadata in Redis: %v", err)
+		return
+	}
+	cs.redisClient.Expire(ctx, metaKey, messageConfig.MessageExpiration)
+}
+
+// loadRoomMeta rebuilds a room from its Redis metadata, or returns nil if
+// there is none.
+func (cs *ChatService) loadRoomMeta(roomID string) *ChatRoom {
+	if cs.redisClient == nil {
+		return nil
+	}
+	fields, err := cs.redisClient.HGetAll(context.Background(), chatRoomMetaKey(roomID)).Result()
+	if err != nil {
+		log.Printf("Failed to load room metadata from Redis: %v", err)
+		return nil
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	
+	name := fields["name"]
+	if name == "" {
+		name = "Chat Room"
+	}
+	room := newChatRoom(roomID, name)
+	room.PasswordHash = fields["password_hash"]
+	if createdAt, err := time.Parse(time.RFC3339Nano, fields["created_at"]); err == nil {
+		room.CreatedAt = createdAt
+	}
+	return room
+}
+
+// loadRoomsFromRedis repopulates the room map from the metadata in Redis.
+func (cs *ChatService) loadRoomsFromRedis() {
+	if cs.redisClient == nil {
+		return
+	}
+	ctx := context.Background()
+	prefix := RedisKeyPrefix() + "room:"
+	
+	var roomIDs []string
+	iter := cs.redisClient.Scan(ctx, 0, prefix+"*:meta", 100).Iterator()
+	for iter.Next(ctx) {
+		roomID := strings.TrimSuffix(strings.TrimPrefix(iter.Val(), prefix), ":meta")
+		if roomID != "" {
+			roomIDs = append(roomIDs, roomID)
+		}
+	}
+	if err := iter.Err(); err != nil {
+		log.Printf("Failed to list rooms in Redis: %v", err)
+		return
+	}
+	
+	cs.roomMutex.Lock()
+	defer cs.roomMutex.Unlock()
+	restored := 0
+	for _, roomID := range roomIDs {
+		if cs.rooms[roomID] != nil {
+			continue
+		}
+		if room := cs.loadRoomMeta(roomID); room != nil {
+			cs.rooms[roomID] = room
+			restored++
+		}
+	}
+	if restored > 0 {
+		log.Printf("Restored %d chat rooms from Redis", restored)
+	}
+}
+
 func (cs *ChatService) storeMessageInRedis(message EncryptedMessage) error {
 	ctx := context.Background()
 	
 	// Store individual message with expiration
-	messageKey := fmt.Sprintf("msg:%s:%s", message.Room, message.MessageID)
+	messageKey := chatMessageKey(message.Room, message.MessageID)
 	messageData, err := json.Marshal(message)
 	if err != nil {
 		return err
@@ -520,7 +1531,7 @@ func (cs *ChatService) storeMessageInRedis(message EncryptedMessage) error {
 	}
 	
 	// Add to room message list (sorted set with timestamp as score)
-	roomKey := fmt.Sprintf("room:%s:messages", message.Room)
+	roomKey := chatRoomKey(message.Room)
 	score := float64(message.Timestamp.Unix())
 	
 	if err := cs.redisClient.ZAdd(ctx, roomKey, &redis.Z{
@@ -539,19 +1550,33 @@ func (cs *ChatService) storeMessageInRedis(message EncryptedMessage) error {
 	return nil
 }
 
-func (cs *ChatService) getMessagesFromRedis(roomID string, limit int) ([]EncryptedMessage, error) {
+// getMessagesFromRedis returns up to limit of the room's most recent messages
+// in chronological order. When since is set, only messages after it are
+// returned, up to the room's MaxRoomMessages.
+func (cs *ChatService) getMessagesFromRedis(roomID string, since time.Time, limit int) ([]EncryptedMessage, error) {
 	ctx := context.Background()
 	
 	// Get recent message IDs from sorted set
-	roomKey := fmt.Sprintf("room:%s:messages", roomID)
-	messageIDs, err := cs.redisClient.ZRevRange(ctx, roomKey, 0, int64(limit-1)).Result()
+	roomKey := chatRoomKey(roomID)
+	var messageIDs []string
+	var err error
+	if since.IsZero() {
+		messageIDs, err = cs.redisClient.ZRevRange(ctx, roomKey, 0, int64(limit-1)).Result()
+	} else {
+		// Scores are whole seconds; the exact timestamp is checked below
+		messageIDs, err = cs.redisClient.ZRevRangeByScore(ctx, roomKey, &redis.ZRangeBy{
+			Min:   strconv.FormatInt(since.Unix(), 10),
+			Max:   "+inf",
+			Count: int64(messageConfig.MaxRoomMessages),
+		}).Result()
+	}
 	if err != nil {
 		return nil, err
 	}
 	
 	var messages []EncryptedMessage
 	for _, messageID := range messageIDs {
-		messageKey := fmt.Sprintf("msg:%s:%s", roomID, messageID)
+		messageKey := chatMessageKey(roomID, messageID)
 		messageData, err := cs.redisClient.Get(ctx, messageKey).Result()
 		if err != nil {
 			if err == redis.Nil {
@@ -566,6 +1591,9 @@ func (cs *ChatService) getMessagesFromRedis(roomID string, limit int) ([]Encrypt
 			log.Printf("Error unmarshaling message %s: %v", messageID, err)
 			continue
 		}
+		if !since.IsZero() && !message.Timestamp.After(since) {
+			continue
+		}
 		
 		messages = append(messages, message)
 	}
@@ -586,7 +1614,7 @@ func (cs *ChatService) checkRateLimit(userID string) bool {
 	limiter, exists := cs.rateLimiter[userID]
 	if !exists {
 		limiter = &RateLimiter{
-			tokens:     messageConfig.RateLimit,
+			tokens:     float64(messageConfig.RateLimit),
 			capacity:   messageConfig.RateLimit,
 			lastRefill: time.Now(),
 		}
@@ -603,14 +1631,14 @@ func (rl *RateLimiter) allow() bool {
 	now := time.Now()
 	elapsed := now.Sub(rl.lastRefill)
 	
-	// Refill tokens based on elapsed time (token bucket algorithm)
-	tokensToAdd := int(elapsed.Minutes())
-	if tokensToAdd > 0 {
-		rl.tokens = min(rl.capacity, rl.tokens+tokensToAdd)
-		rl.lastRefill = now
+	// Refill capacity tokens per minute, continuously (token bucket algorithm)
+	rl.tokens += elapsed.Seconds() * float64(rl.capacity) / 60
+	if rl.tokens > float64(rl.capacity) {
+		rl.tokens = float64(rl.capacity)
 	}
+	rl.lastRefill = now
 	
-	if rl.tokens > 0 {
+	if rl.tokens >= 1 {
 		rl.tokens--
 		return true
 	}
@@ -618,13 +1646,6 @@ func (rl *RateLimiter) allow() bool {
 	return false
 }
 
-func min(a, b int) int {
-	if a < b {
-		return a
-	}
-	return b
-}
-
 // Cleanup functions
 func (cs *ChatService) expiredMessageCleanup() {
 	ticker := time.NewTicker(1 * time.Hour)
@@ -636,7 +1657,8 @@ func (cs *ChatService) expiredMessageCleanup() {
 			ctx := context.Background()
 			
 			// Clean up expired messages
-			pattern := "msg:*:*"
+			msgPrefix := RedisKeyPrefix() + "msg:"
+			pattern := msgPrefix + "*:*"
 			iter := cs.redisClient.Scan(ctx, 0, pattern, 0).Iterator()
 			
 			for iter.Next(ctx) {
@@ -645,11 +1667,11 @@ func (cs *ChatService) expiredMessageCleanup() {
 				exists, err := cs.redisClient.Exists(ctx, key).Result()
 				if err != nil || exists == 0 {
 					// Remove from room message lists
-					parts := strings.Split(key, ":")
-					if len(parts) >= 3 {
-						roomID := parts[1]
-						messageID := parts[2]
-						roomKey := fmt.Sprintf("room:%s:messages", roomID)
+					parts := strings.Split(strings.TrimPrefix(key, msgPrefix), ":")
+					if len(parts) >= 2 {
+						roomID := parts[0]
+						messageID := parts[1]
+						roomKey := chatRoomKey(roomID)
 						cs.redisClient.ZRem(ctx, roomKey, messageID)
 					}
 				}
@@ -0,0 +1,191 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SSE is a fallback chat transport for networks that block WebSockets. The
+// client opens an event stream with GET /sse/chat?room=<id> and sends the
+// same frames it would send over a WebSocket (join, message, key_exchange,
+// ...) with POST /sse/chat/send, naming its stream in the X-Chat-Session
+// header. The stream's first event carries that session ID. SSE clients are
+// ordinary room members, so broadcasts reach both transports.
+
+// sseSessionHeader names the stream a POSTed frame belongs to.
+const sseSessionHeader = "X-Chat-Session"
+
+// sseKeepalive is how often an idle stream gets a comment line, so proxies
+// do not time it out.
+const sseKeepalive = 30 * time.Second
+
+// sseSession is one open event stream. mutex serializes the frames POSTed
+// for it, as a WebSocket's read loop would.
+type sseSession struct {
+	client *Client
+	roomID string
+	mutex  sync.Mutex
+	closed bool
+}
+
+var (
+	sseSessions     = make(map[string]*sseSession)
+	sseSessionMutex sync.Mutex
+)
+
+// HandleSSE streams a room's frames as server-sent events. It applies the
+// same admission checks as WebSocket connections and counts towards the
+// connection limit.
+func (cs *ChatService) HandleSSE(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	roomID := strings.TrimSpace(r.URL.Query().Get("room"))
+	if roomID == "" {
+		http.Error(w, "room is required", http.StatusBadRequest)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	if status, rejection := cs.checkAdmission(r); rejection != nil {
+		log.Printf("SSE connection from %s rejected: %s", r.RemoteAddr, rejection.Reason)
+		writeWSRejection(w, status, rejection)
+		return
+	}
+
+	atomic.AddInt64(&cs.activeConnections, 1)
+	client := newClient(nil)
+	sessionID := generateRandomString(32)
+	session := &sseSession{client: client, roomID: roomID}
+
+	sseSessionMutex.Lock()
+	sseSessions[sessionID] = session
+	sseSessionMutex.Unlock()
+
+	defer func() {
+		sseSessionMutex.Lock()
+		delete(sseSessions, sessionID)
+		sseSessionMutex.Unlock()
+
+		session.mutex.Lock()
+		session.closed = true
+		session.mutex.Unlock()
+
+		cs.LeaveRoom(client)
+		atomic.AddInt64(&cs.activeConnections, -1)
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // Keep nginx from buffering the stream
+	w.WriteHeader(http.StatusOK)
+
+	hello, _ := json.Marshal(map[string]string{"type": "session", "session": sessionID, "room": roomID})
+	writeSSEEvent(w, hello)
+	flusher.Flush()
+
+	ticker := time.NewTicker(sseKeepalive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case message := <-client.Send:
+			if err := writeSSEEvent(w, message); err != nil {
+				return
+			}
+			// Send what else is queued in the same flush
+			if err := writeQueuedSSEEvents(w, client); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-client.done:
+			// Deliver the frames queued before the disconnect, then end
+			writeQueuedSSEEvents(w, client)
+			flusher.Flush()
+			return
+		case <-ticker.C:
+			if _, err := io.WriteString(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		case <-cs.draining:
+			return
+		}
+	}
+}
+
+// writeQueuedSSEEvents writes the frames currently queued for client.
+func writeQueuedSSEEvents(w io.Writer, client *Client) error {
+	for n := len(client.Send); n > 0; n-- {
+		if err := writeSSEEvent(w, <-client.Send); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeSSEEvent writes one frame as a "data:" event. Frames are single-line
+// JSON, so no line splitting is needed.
+func writeSSEEvent(w io.Writer, data []byte) error {
+	_, err := fmt.Fprintf(w, "data: %s\n\n", data)
+	return err
+}
+
+// HandleSSESend accepts one frame from an SSE client. Frames are handled as
+// if they arrived over the client's WebSocket; errors are reported as error
+// frames on the stream, so the response is 202 once the frame is accepted.
+func (cs *ChatService) HandleSSESend(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !cs.upgrader.CheckOrigin(r) {
+		http.Error(w, "Connections from this origin are not allowed", http.StatusForbidden)
+		return
+	}
+
+	sseSessionMutex.Lock()
+	session := sseSessions[r.Header.Get(sseSessionHeader)]
+	sseSessionMutex.Unlock()
+	if session == nil {
+		http.Error(w, "Unknown or closed chat session", http.StatusNotFound)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, int64(wsMessageLimit()))
+	var wsMsg WSMessage
+	if err := json.NewDecoder(r.Body).Decode(&wsMsg); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid frame (max: %d bytes)", wsMessageLimit()), http.StatusBadRequest)
+		return
+	}
+	if wsMsg.Type == "join" && wsMsg.Room != session.roomID {
+		http.Error(w, "Frame is for a different room than the stream", http.StatusBadRequest)
+		return
+	}
+
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+	if session.closed {
+		http.Error(w, "Unknown or closed chat session", http.StatusNotFound)
+		return
+	}
+	if cs.handleClientMessage(session.client, wsMsg) {
+		// The stream ends once the rejection has been delivered
+		session.closed = true
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
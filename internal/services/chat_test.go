@@ -0,0 +1,358 @@
+package services
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestChatService() *ChatService {
+	return &ChatService{
+		rooms:       make(map[string]*ChatRoom),
+		rateLimiter: make(map[string]*RateLimiter),
+		draining:    make(chan struct{}),
+	}
+}
+
+// drainFrames returns the types of the frames queued for client.
+func drainFrames(client *Client) []string {
+	var types []string
+	for {
+		select {
+		case frame := <-client.Send:
+			var msg struct {
+				Type    string `json:"type"`
+				Message string `json:"message"`
+			}
+			json.Unmarshal(frame, &msg)
+			types = append(types, msg.Type)
+		default:
+			return types
+		}
+	}
+}
+
+func isDisconnected(client *Client) bool {
+	select {
+	case <-client.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestJoinWithWrongPasswordDisconnects(t *testing.T) {
+	cs := newTestChatService()
+	owner := newClient(nil)
+	if cs.handleClientMessage(owner, WSMessage{Type: "join", Room: "r1", User: "owner", Password: "secret"}) {
+		t.Fatal("owner's join was refused")
+	}
+
+	intruder := newClient(nil)
+	if !cs.handleClientMessage(intruder, WSMessage{Type: "join", Room: "r1", User: "intruder", Password: "wrong"}) {
+		t.Fatal("join with the wrong password was accepted")
+	}
+	if !isDisconnected(intruder) {
+		t.Error("refused client was not disconnected")
+	}
+	if frames := drainFrames(intruder); len(frames) != 1 || frames[0] != "error" {
+		t.Errorf("refused client got frames %v, want one error", frames)
+	}
+
+	// Leaving and disconnecting again must not panic
+	cs.LeaveRoom(intruder)
+	intruder.disconnect()
+}
+
+func TestSecondJoinIsRejected(t *testing.T) {
+	cs := newTestChatService()
+	cs.handleClientMessage(newClient(nil), WSMessage{Type: "join", Room: "locked", User: "owner", Password: "secret"})
+
+	member := newClient(nil)
+	if cs.handleClientMessage(member, WSMessage{Type: "join", Room: "open", User: "member"}) {
+		t.Fatal("join to an open room was refused")
+	}
+	drainFrames(member)
+
+	// A wrong password for another room must not disconnect the member
+	// while it is still registered in its room
+	if cs.handleClientMessage(member, WSMessage{Type: "join", Room: "locked", User: "member", Password: "wrong"}) {
+		t.Fatal("second join was handled as a refused first join")
+	}
+	if isDisconnected(member) {
+		t.Fatal("member was disconnected by a second join")
+	}
+	if member.Room == nil || member.Room.ID != "open" {
+		t.Fatalf("member moved to %v", member.Room)
+	}
+
+	// Broadcasting to the member's room still works
+	other := newClient(nil)
+	cs.handleClientMessage(other, WSMessage{Type: "join", Room: "open", User: "other"})
+	cs.broadcastRoster(member.Room)
+
+	cs.LeaveRoom(member)
+	cs.LeaveRoom(member)
+	cs.LeaveRoom(other)
+}
+
+func TestFullSendBufferDisconnectsClient(t *testing.T) {
+	cs := newTestChatService()
+	slow := newClient(nil)
+	cs.handleClientMessage(slow, WSMessage{Type: "join", Room: "r", User: "slow"})
+	room := slow.Room
+	for len(slow.Send) < cap(slow.Send) {
+		slow.Send <- []byte("{}")
+	}
+
+	sender := newClient(nil)
+	cs.handleClientMessage(sender, WSMessage{Type: "join", Room: "r", User: "sender"})
+	message := EncryptedMessage{
+		Type:      "message",
+		Room:      "r",
+		Encrypted: base64.StdEncoding.EncodeToString(make([]byte, 32)),
+		IV:        base64.StdEncoding.EncodeToString(make([]byte, 12)),
+	}
+	if err := cs.BroadcastMessage(room, message, sender.UserID); err != nil {
+		t.Fatal(err)
+	}
+
+	if !isDisconnected(slow) {
+		t.Fatal("client with a full buffer was not disconnected")
+	}
+	// Queueing more frames and leaving must not panic
+	slow.sendError("late")
+	cs.LeaveRoom(slow)
+	cs.LeaveRoom(sender)
+}
+
+// Listing rooms while the last members leave them used to take the service
+// and room locks in the opposite order and could deadlock.
+func TestListRoomsWhileLeaving(t *testing.T) {
+	cs := newTestChatService()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 300; i++ {
+			client := newClient(nil)
+			cs.handleClientMessage(client, WSMessage{Type: "join", Room: fmt.Sprintf("room-%d", i%5), User: "user"})
+			cs.LeaveRoom(client)
+		}
+	}()
+
+	deadline := time.After(10 * time.Second)
+	for {
+		select {
+		case <-done:
+			if rooms := cs.ListRooms(); len(rooms) != 0 {
+				t.Fatalf("%d empty rooms left behind", len(rooms))
+			}
+			return
+		case <-deadline:
+			t.Fatal("joining and leaving deadlocked with ListRooms")
+		default:
+			cs.ListRooms()
+		}
+	}
+}
+
+func TestFingerprintsKeyedByUserID(t *testing.T) {
+	cs := newTestChatService()
+	first := newClient(nil)
+	cs.handleClientMessage(first, WSMessage{Type: "join", Room: "r", User: "alice", KeyFingerprint: "AAAA"})
+	second := newClient(nil)
+	cs.handleClientMessage(second, WSMessage{Type: "join", Room: "r", User: "alice", KeyFingerprint: "BBBB"})
+	room := first.Room
+
+	// Sharing a name must not let one member replace another's fingerprint
+	roster := buildRoster(room)
+	if len(roster) != 2 || roster[0].KeyFingerprint != "AAAA" || roster[1].KeyFingerprint != "BBBB" {
+		t.Fatalf("roster = %+v", roster)
+	}
+
+	cs.LeaveRoom(second)
+	if _, ok := room.Fingerprints[second.UserID]; ok {
+		t.Error("fingerprint kept after the member left")
+	}
+	if len(room.Fingerprints) != 1 || room.Fingerprints[first.UserID] != "AAAA" {
+		t.Errorf("fingerprints = %v", room.Fingerprints)
+	}
+	cs.LeaveRoom(first)
+}
+
+func TestCheckWebSocketTokenRequiredForEveryOrigin(t *testing.T) {
+	t.Setenv("ZEEPASS_DEV_MODE", "")
+	t.Setenv("CHAT_ACCESS_TOKENS", "embed-token, page-token")
+
+	tests := []struct {
+		name     string
+		origin   string
+		target   string
+		protocol string
+		want     int
+	}{
+		{"same origin without token", "https://zeepass.example.com", "/ws/chat", "", http.StatusUnauthorized},
+		{"no origin without token", "", "/ws/chat", "", http.StatusUnauthorized},
+		{"cross origin without token", "https://embed.example.com", "/ws/chat", "", http.StatusUnauthorized},
+		{"same origin with query token", "https://zeepass.example.com", "/ws/chat?token=page-token", "", http.StatusOK},
+		{"subprotocol token", "https://embed.example.com", "/ws/chat", "zeepass, zeepass.token.embed-token", http.StatusOK},
+		{"unknown token", "https://zeepass.example.com", "/ws/chat?token=guess", "", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "https://zeepass.example.com"+tt.target, nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			if tt.protocol != "" {
+				r.Header.Set("Sec-WebSocket-Protocol", tt.protocol)
+			}
+			if status, _ := checkWebSocketToken(r); status != tt.want {
+				t.Errorf("status = %d, want %d", status, tt.want)
+			}
+		})
+	}
+}
+
+func TestSSEJoinWithWrongPasswordClosesStream(t *testing.T) {
+	cs := newTestChatService()
+	cs.upgrader.CheckOrigin = checkWebSocketOrigin
+	owner := newClient(nil)
+	cs.handleClientMessage(owner, WSMessage{Type: "join", Room: "sse-locked", User: "owner", Password: "secret"})
+	defer cs.LeaveRoom(owner)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sse/chat", cs.HandleSSE)
+	mux.HandleFunc("/sse/chat/send", cs.HandleSSESend)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := &http.Client{Timeout: 5 * time.Second}
+
+	resp, err := client.Get(server.URL + "/sse/chat?room=sse-locked")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	events := bufio.NewScanner(resp.Body)
+	nextEvent := func() (map[string]string, bool) {
+		for events.Scan() {
+			if data, ok := strings.CutPrefix(events.Text(), "data: "); ok {
+				var event map[string]string
+				json.Unmarshal([]byte(data), &event)
+				return event, true
+			}
+		}
+		return nil, false
+	}
+	hello, ok := nextEvent()
+	if !ok || hello["type"] != "session" || hello["session"] == "" {
+		t.Fatalf("first event = %v, want the session", hello)
+	}
+
+	send := func(frame string) int {
+		req, _ := http.NewRequest(http.MethodPost, server.URL+"/sse/chat/send", strings.NewReader(frame))
+		req.Header.Set(sseSessionHeader, hello["session"])
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := send(`{"type": "join", "room": "sse-locked", "user": "intruder", "password": "wrong"}`); code != http.StatusAccepted {
+		t.Fatalf("join frame: status %d, want 202", code)
+	}
+
+	if event, ok := nextEvent(); !ok || event["type"] != "error" {
+		t.Fatalf("event after a wrong password = %v, want an error", event)
+	}
+	if event, ok := nextEvent(); ok {
+		t.Fatalf("stream still open after a wrong password, got %v", event)
+	}
+	if err := events.Err(); err != nil {
+		t.Fatalf("stream did not end cleanly: %v", err)
+	}
+	if code := send(`{"type": "join", "room": "sse-locked", "user": "intruder", "password": "secret"}`); code != http.StatusNotFound {
+		t.Errorf("frame on a closed stream: status %d, want 404", code)
+	}
+	if room := cs.GetRoom("sse-locked"); room == nil || len(room.Clients) != 1 {
+		t.Error("refused SSE client joined the room")
+	}
+}
+
+// The password hash is kept with the room metadata in Redis, so a room keeps
+// its password when it is picked up again after a restart.
+func TestRoomPasswordSurvivesRestart(t *testing.T) {
+	_, redisClient := newTestRedis(t)
+	before := newTestChatService()
+	before.redisClient = redisClient
+	owner := newClient(nil)
+	if before.handleClientMessage(owner, WSMessage{Type: "join", Room: "kept", User: "owner", Password: "secret"}) {
+		t.Fatal("owner's join was refused")
+	}
+	room := before.loadRoomMeta("kept")
+	if room == nil || room.PasswordHash == "" || room.PasswordHash != owner.Room.PasswordHash {
+		t.Fatalf("stored room metadata = %+v", room)
+	}
+
+	after := newTestChatService()
+	after.redisClient = redisClient
+	intruder := newClient(nil)
+	if !after.handleClientMessage(intruder, WSMessage{Type: "join", Room: "kept", User: "intruder", Password: "wrong"}) {
+		t.Fatal("join with the wrong password was accepted after a restart")
+	}
+	if !after.handleClientMessage(newClient(nil), WSMessage{Type: "join", Room: "kept", User: "intruder"}) {
+		t.Fatal("join without a password was accepted after a restart")
+	}
+	member := newClient(nil)
+	if after.handleClientMessage(member, WSMessage{Type: "join", Room: "kept", User: "member", Password: "secret"}) {
+		t.Fatal("join with the right password was refused after a restart")
+	}
+	after.LeaveRoom(member)
+	before.LeaveRoom(owner)
+}
+
+func TestRoomsRestoredFromRedisKeepTheirPassword(t *testing.T) {
+	_, redisClient := newTestRedis(t)
+	before := newTestChatService()
+	before.redisClient = redisClient
+	owner := newClient(nil)
+	before.handleClientMessage(owner, WSMessage{Type: "join", Room: "protected", User: "owner", Password: "secret"})
+	before.CreateRoom("named", "Team Room")
+	createdAt := owner.Room.CreatedAt
+
+	// A restarted instance restores every room when it connects to Redis
+	after := newTestChatService()
+	after.SetRedisClient(redisClient)
+	rooms := after.ListRooms()
+	if len(rooms) != 2 {
+		t.Fatalf("restored rooms = %+v, want 2", rooms)
+	}
+	restored := after.GetRoom("protected")
+	if restored == nil || restored.PasswordHash == "" || !restored.CreatedAt.Equal(createdAt) {
+		t.Fatalf("restored room = %+v", restored)
+	}
+	if named := after.GetRoom("named"); named == nil || named.Name != "Team Room" {
+		t.Errorf("restored room name = %+v, want Team Room", named)
+	}
+
+	intruder := newClient(nil)
+	if !after.handleClientMessage(intruder, WSMessage{Type: "join", Room: "protected", User: "intruder", Password: "wrong"}) {
+		t.Fatal("join with the wrong password was accepted by a restored room")
+	}
+	if frames := drainFrames(intruder); len(frames) != 1 || frames[0] != "error" {
+		t.Errorf("refused client got frames %v, want one error", frames)
+	}
+	member := newClient(nil)
+	if after.handleClientMessage(member, WSMessage{Type: "join", Room: "protected", User: "member", Password: "secret"}) {
+		t.Fatal("join with the right password was refused by a restored room")
+	}
+	after.LeaveRoom(member)
+	before.LeaveRoom(owner)
+}
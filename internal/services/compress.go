@@ -0,0 +1,42 @@
+package services
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"io"
+)
+
+// CompressText deflates data at the best compression level. Compression
+// happens before encryption, so the stored ciphertext length reflects how
+// compressible the plaintext is.
+func CompressText(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer, err := flate.NewWriter(&buf, flate.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// DecompressText inflates data produced by CompressText. It refuses to
+// produce more than limit bytes so a crafted record cannot exhaust memory.
+func DecompressText(data []byte, limit int64) ([]byte, error) {
+	reader := flate.NewReader(bytes.NewReader(data))
+	defer reader.Close()
+
+	out, err := io.ReadAll(io.LimitReader(reader, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress text: %v", err)
+	}
+	if int64(len(out)) > limit {
+		return nil, fmt.Errorf("decompressed text exceeds %d bytes", limit)
+	}
+	return out, nil
+}
@@ -0,0 +1,25 @@
+package services
+
+import (
+	"os"
+)
+
+// IsDevMode reports whether ZEEPASS_DEV_MODE=1 is set. Dev mode enables
+// insecure conveniences for local testing and must never be used in production.
+func IsDevMode() bool {
+	return os.Getenv("ZEEPASS_DEV_MODE") == "1"
+}
+
+// WipeBytes zeroes a buffer that held plaintext once it is no longer needed,
+// unless WIPE_PLAINTEXT=false. This is best effort only: Go strings are
+// immutable and cannot be wiped, the garbage collector may already have
+// copied a buffer when growing or moving it, and data that passed through
+// net/http, multipart temp files or bufio readers is outside our control.
+// It shortens how long secrets linger in memory, it does not guarantee that
+// no copy remains.
+func WipeBytes(b []byte) {
+	if os.Getenv("WIPE_PLAINTEXT") == "false" {
+		return
+	}
+	clear(b)
+}
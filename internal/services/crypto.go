@@ -1,86 +1,385 @@
 package services
 
 import (
+	"bufio"
+	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
 	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
 	"fmt"
 	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/scrypt"
 )
 
-var encryptionKey = []byte("your-32-byte-encryption-key-here")
+// devEncryptionKey is a well-known key used only when ZEEPASS_DEV_MODE=1 and
+// ZEEPASS_ENCRYPTION_KEY is unset. Anything encrypted with it is readable by
+// anyone who has seen this source file.
+const devEncryptionKey = "your-32-byte-encryption-key-here"
 
-func Encrypt(plaintext string, key []byte) (string, error) {
-	block, err := aes.NewCipher(key[:32])
-	if err != nil {
-		return "", err
+// Key versions identify which key in the key ring sealed a ciphertext. The
+// version byte is prepended to every ciphertext so old secrets stay readable
+// after the primary key is rotated. Version 0 is reserved for keys that are
+// not in the ring (e.g. keys supplied directly by a caller).
+const unregisteredKeyVersion byte = 0
+
+var (
+	keyRing           = make(map[byte][]byte)
+	primaryKeyVersion byte
+	keyRingMutex      sync.RWMutex
+)
+
+// InitEncryptionKey loads the AES-256 key from ZEEPASS_ENCRYPTION_KEY, which
+// must be the base64 encoding of exactly 32 random bytes. The key is
+// registered as the primary key under ZEEPASS_ENCRYPTION_KEY_VERSION (default
+// 1). Retired keys that must remain available for decryption are listed in
+// ZEEPASS_PREVIOUS_ENCRYPTION_KEYS as comma-separated "version:base64key" pairs.
+func InitEncryptionKey() error {
+	version := byte(1)
+	if v := os.Getenv("ZEEPASS_ENCRYPTION_KEY_VERSION"); v != "" {
+		parsed, err := parseKeyVersion(v)
+		if err != nil {
+			return err
+		}
+		version = parsed
+	}
+
+	encoded := os.Getenv("ZEEPASS_ENCRYPTION_KEY")
+	var key []byte
+	if encoded == "" {
+		if !IsDevMode() {
+			return fmt.Errorf("ZEEPASS_ENCRYPTION_KEY is not set (generate one with: openssl rand -base64 32)")
+		}
+		log.Println("WARNING: ZEEPASS_ENCRYPTION_KEY not set, using the insecure development key (ZEEPASS_DEV_MODE=1)")
+		key = []byte(devEncryptionKey)
+	} else {
+		parsed, err := ParseEncryptionKey(encoded)
+		if err != nil {
+			return err
+		}
+		key = parsed
+	}
+
+	if previous := os.Getenv("ZEEPASS_PREVIOUS_ENCRYPTION_KEYS"); previous != "" {
+		seen := make(map[byte]bool)
+		for _, entry := range strings.Split(previous, ",") {
+			parts := strings.SplitN(strings.TrimSpace(entry), ":", 2)
+			if len(parts) != 2 {
+				return fmt.Errorf("invalid ZEEPASS_PREVIOUS_ENCRYPTION_KEYS entry %q (expected version:base64key)", entry)
+			}
+			oldVersion, err := parseKeyVersion(parts[0])
+			if err != nil {
+				return err
+			}
+			if oldVersion == version {
+				return fmt.Errorf("previous encryption key version %d collides with the primary key version", oldVersion)
+			}
+			if seen[oldVersion] {
+				return fmt.Errorf("previous encryption key version %d is listed more than once", oldVersion)
+			}
+			seen[oldVersion] = true
+			oldKey, err := ParseEncryptionKey(parts[1])
+			if err != nil {
+				return fmt.Errorf("previous encryption key version %d: %v", oldVersion, err)
+			}
+			if err := RegisterEncryptionKey(oldVersion, oldKey); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := RegisterEncryptionKey(version, key); err != nil {
+		return err
+	}
+	return SetPrimaryKeyVersion(version)
+}
+
+// RegisterEncryptionKey adds a key to the key ring under the given version.
+func RegisterEncryptionKey(version byte, key []byte) error {
+	if version == unregisteredKeyVersion {
+		return fmt.Errorf("encryption key version 0 is reserved")
+	}
+	if len(key) != 32 {
+		return fmt.Errorf("encryption key must be 32 bytes, got %d", len(key))
 	}
 
-	gcm, err := cipher.NewGCM(block)
+	keyRingMutex.Lock()
+	defer keyRingMutex.Unlock()
+	keyRing[version] = append([]byte(nil), key...)
+	return nil
+}
+
+// SetPrimaryKeyVersion selects which registered key encrypts new data.
+func SetPrimaryKeyVersion(version byte) error {
+	keyRingMutex.Lock()
+	defer keyRingMutex.Unlock()
+	if _, ok := keyRing[version]; !ok {
+		return fmt.Errorf("encryption key version %d is not registered", version)
+	}
+	primaryKeyVersion = version
+	return nil
+}
+
+func parseKeyVersion(s string) (byte, error) {
+	v, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || v < 1 || v > 255 {
+		return 0, fmt.Errorf("invalid encryption key version %q (must be 1-255)", s)
+	}
+	return byte(v), nil
+}
+
+// keyVersionFor returns the key ring version of key, or 0 if it is not registered.
+func keyVersionFor(key []byte) byte {
+	keyRingMutex.RLock()
+	defer keyRingMutex.RUnlock()
+	for version, k := range keyRing {
+		if subtle.ConstantTimeCompare(k, key) == 1 {
+			return version
+		}
+	}
+	return unregisteredKeyVersion
+}
+
+func lookupKey(version byte) ([]byte, bool) {
+	keyRingMutex.RLock()
+	defer keyRingMutex.RUnlock()
+	key, ok := keyRing[version]
+	return key, ok
+}
+
+// ParseEncryptionKey decodes a base64 encoded key and checks that it is 32 bytes long.
+func ParseEncryptionKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
 	if err != nil {
-		return "", err
+		return nil, fmt.Errorf("encryption key is not valid base64: %v", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("encryption key must be 32 bytes, got %d", len(key))
 	}
+	return key, nil
+}
 
-	nonce := make([]byte, gcm.NonceSize())
-	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+// Encrypt seals plaintext with key. The secret id is bound to the ciphertext
+// as associated data, so it only decrypts under the same id.
+func Encrypt(plaintext string, key []byte, id string) (string, error) {
+	buf := []byte(plaintext)
+	defer WipeBytes(buf)
+	ciphertext, err := seal(buf, key, []byte(id))
+	if err != nil {
 		return "", err
 	}
-
-	ciphertext := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
 	return base64.StdEncoding.EncodeToString(ciphertext), nil
 }
 
-func Decrypt(ciphertext string, key []byte) (string, error) {
+// Decrypt opens a ciphertext produced by Encrypt for the same id.
+func Decrypt(ciphertext string, key []byte, id string) (string, error) {
 	data, err := base64.StdEncoding.DecodeString(ciphertext)
 	if err != nil {
 		return "", err
 	}
 
-	block, err := aes.NewCipher(key[:32])
+	plaintext, err := open(data, key, []byte(id))
 	if err != nil {
 		return "", err
 	}
+	defer WipeBytes(plaintext)
 
-	gcm, err := cipher.NewGCM(block)
-	if err != nil {
-		return "", err
+	return string(plaintext), nil
+}
+
+// Argon2id parameters for PIN hashing (OWASP recommended minimums).
+const (
+	pinArgon2Time    = 2
+	pinArgon2Memory  = 19 * 1024
+	pinArgon2Threads = 1
+	pinArgon2KeyLen  = 32
+	pinSaltLen       = 16
+)
+
+// HashPIN hashes a PIN with Argon2id and a random salt. The result is encoded
+// in the PHC string format ($argon2id$v=19$m=...,t=...,p=...$salt$hash) so the
+// parameters can be read back by VerifyPIN.
+func HashPIN(pin string) string {
+	salt := make([]byte, pinSaltLen)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		panic(fmt.Sprintf("failed to generate PIN salt: %v", err))
 	}
 
-	nonceSize := gcm.NonceSize()
-	if len(data) < nonceSize {
-		return "", fmt.Errorf("ciphertext too short")
+	hash := argon2.IDKey([]byte(pin), salt, pinArgon2Time, pinArgon2Memory, pinArgon2Threads, pinArgon2KeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, pinArgon2Memory, pinArgon2Time, pinArgon2Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(hash))
+}
+
+// VerifyPIN checks pin against a hash produced by HashPIN. Hashes created
+// before the switch to Argon2id (unsalted hex SHA-256) are still accepted.
+// An empty PIN or stored hash never matches.
+func VerifyPIN(pin, encoded string) bool {
+	if pin == "" || encoded == "" {
+		return false
+	}
+
+	if !strings.HasPrefix(encoded, "$argon2id$") {
+		legacy := sha256.Sum256([]byte(pin))
+		return SecureCompare(hex.EncodeToString(legacy[:]), encoded)
 	}
 
-	nonce, ciphertextBytes := data[:nonceSize], data[nonceSize:]
-	plaintext, err := gcm.Open(nil, nonce, ciphertextBytes, nil)
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return false
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false
+	}
+
+	var memory, time uint32
+	var threads uint8
+	// argon2 panics on zero rounds or threads
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil || time == 0 || threads == 0 {
+		return false
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
 	if err != nil {
-		return "", err
+		return false
+	}
+	expected, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(expected) == 0 {
+		return false
 	}
 
-	return string(plaintext), nil
+	actual := argon2.IDKey([]byte(pin), salt, time, memory, threads, uint32(len(expected)))
+	return SecureCompare(string(actual), string(expected))
 }
 
-func GenerateID() string {
-	b := make([]byte, 16)
-	rand.Read(b)
-	return fmt.Sprintf("%x", b)
+// NormalizeHintAnswer trims, lowercases and collapses inner whitespace so that
+// "Fluffy", " fluffy " and "FLUFFY" are the same answer.
+func NormalizeHintAnswer(answer string) string {
+	return strings.ToLower(strings.Join(strings.Fields(answer), " "))
 }
 
-func HashPIN(pin string) string {
-	hasher := sha256.New()
-	hasher.Write([]byte(pin))
-	return fmt.Sprintf("%x", hasher.Sum(nil))
+// HashHintAnswer hashes the normalized answer to a security question the same
+// way as a PIN.
+func HashHintAnswer(answer string) string {
+	return HashPIN(NormalizeHintAnswer(answer))
 }
 
-func EncryptFile(data []byte, key []byte) ([]byte, error) {
-	block, err := aes.NewCipher(key[:32])
-	if err != nil {
+// VerifyHintAnswer checks answer against a hash produced by HashHintAnswer.
+func VerifyHintAnswer(answer, encoded string) bool {
+	return VerifyPIN(NormalizeHintAnswer(answer), encoded)
+}
+
+// Scrypt parameters for passphrase-derived keys.
+const (
+	scryptN           = 1 << 15
+	scryptR           = 8
+	scryptP           = 1
+	passphraseSaltLen = 16
+)
+
+// NewPassphraseSalt returns a random salt for DeriveKeyFromPassphrase.
+func NewPassphraseSalt() ([]byte, error) {
+	salt := make([]byte, passphraseSaltLen)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
 		return nil, err
 	}
+	return salt, nil
+}
+
+// DeriveKeyFromPassphrase derives a 32-byte AES key from a user passphrase
+// with scrypt. Secrets encrypted with such a key cannot be decrypted by the
+// server unless the passphrase is supplied again.
+func DeriveKeyFromPassphrase(passphrase string, salt []byte) ([]byte, error) {
+	if passphrase == "" {
+		return nil, fmt.Errorf("passphrase is required")
+	}
+	return scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, 32)
+}
+
+// SecureCompare compares two secrets in constant time with respect to their
+// contents, so response timing does not reveal how many leading bytes match.
+// Only the lengths may leak.
+func SecureCompare(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// EncryptFile seals file contents with key, bound to the secret id.
+func EncryptFile(data []byte, key []byte, id string) ([]byte, error) {
+	return seal(data, key, []byte(id))
+}
+
+// DecryptFile opens a ciphertext produced by EncryptFile for the same id.
+func DecryptFile(ciphertext []byte, key []byte, id string) ([]byte, error) {
+	return open(ciphertext, key, []byte(id))
+}
+
+// GetEncryptionKey returns the primary key, which is used for all new writes.
+func GetEncryptionKey() []byte {
+	keyRingMutex.RLock()
+	defer keyRingMutex.RUnlock()
+	return keyRing[primaryKeyVersion]
+}
+
+// Ciphertexts produced by seal begin with a small self-describing envelope
+// header so the format can evolve without ambiguity:
+//
+//	magic "ZPEV" | format version (1) | cipher id (1) | key version (1) | nonce | sealed
+//
+// The header is authenticated together with the caller's associated data.
+const (
+	envelopeMagic      = "ZPEV"
+	envelopeVersion1   = 1
+	envelopeHeaderSize = len(envelopeMagic) + 3
+	cipherAES256GCM    = 1
+)
+
+// envelopeHeader is the parsed form of an envelope header.
+type envelopeHeader struct {
+	Version    byte
+	Cipher     byte
+	KeyVersion byte
+}
+
+// parseEnvelopeHeader validates the envelope header at the start of data.
+func parseEnvelopeHeader(data []byte) (envelopeHeader, error) {
+	var h envelopeHeader
+	if !bytes.HasPrefix(data, []byte(envelopeMagic)) {
+		return h, fmt.Errorf("missing ciphertext envelope header")
+	}
+	if len(data) < envelopeHeaderSize {
+		return h, fmt.Errorf("ciphertext envelope header truncated: got %d bytes, need %d", len(data), envelopeHeaderSize)
+	}
+
+	h.Version = data[len(envelopeMagic)]
+	h.Cipher = data[len(envelopeMagic)+1]
+	h.KeyVersion = data[len(envelopeMagic)+2]
+	if h.Version != envelopeVersion1 {
+		return h, fmt.Errorf("unsupported ciphertext format version %d (this build supports version %d)", h.Version, envelopeVersion1)
+	}
+	if h.Cipher != cipherAES256GCM {
+		return h, fmt.Errorf("unsupported cipher id %d in ciphertext envelope", h.Cipher)
+	}
+	return h, nil
+}
 
-	gcm, err := cipher.NewGCM(block)
+// seal encrypts data with AES-256-GCM under an envelope header that names the
+// key used, so it can be found in the key ring after rotation.
+func seal(data []byte, key []byte, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
 	if err != nil {
 		return nil, err
 	}
@@ -90,17 +389,47 @@ func EncryptFile(data []byte, key []byte) ([]byte, error) {
 		return nil, err
 	}
 
-	ciphertext := gcm.Seal(nonce, nonce, data, nil)
-	return ciphertext, nil
+	out := make([]byte, 0, envelopeHeaderSize+len(nonce)+len(data)+gcm.Overhead())
+	out = append(out, envelopeMagic...)
+	out = append(out, envelopeVersion1, cipherAES256GCM, keyVersionFor(key))
+	header := out[:envelopeHeaderSize:envelopeHeaderSize]
+	out = append(out, nonce...)
+	return gcm.Seal(out, nonce, data, append(header, aad...)), nil
 }
 
-func DecryptFile(ciphertext []byte, key []byte) ([]byte, error) {
-	block, err := aes.NewCipher(key[:32])
+// open decrypts the output of seal. Ciphertexts written before the envelope
+// header was introduced are still accepted, see openPreEnvelope.
+func open(ciphertext []byte, key []byte, aad []byte) ([]byte, error) {
+	if !bytes.HasPrefix(ciphertext, []byte(envelopeMagic)) {
+		return openPreEnvelope(ciphertext, key)
+	}
+
+	h, err := parseEnvelopeHeader(ciphertext)
 	if err != nil {
 		return nil, err
 	}
 
-	gcm, err := cipher.NewGCM(block)
+	if h.KeyVersion != unregisteredKeyVersion {
+		versionedKey, ok := lookupKey(h.KeyVersion)
+		if !ok {
+			return nil, fmt.Errorf("unknown encryption key version %d", h.KeyVersion)
+		}
+		key = versionedKey
+	}
+
+	header := ciphertext[:envelopeHeaderSize:envelopeHeaderSize]
+	return openWithKey(ciphertext[envelopeHeaderSize:], key, append(header, aad...))
+}
+
+// openPreEnvelope decrypts ciphertexts written before the envelope header
+// was introduced, which are a plain nonce || ciphertext sealed with key and no
+// associated data. Anything else is refused.
+func openPreEnvelope(ciphertext []byte, key []byte) ([]byte, error) {
+	return openWithKey(ciphertext, key, nil)
+}
+
+func openWithKey(ciphertext []byte, key []byte, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
 	if err != nil {
 		return nil, err
 	}
@@ -111,14 +440,304 @@ func DecryptFile(ciphertext []byte, key []byte) ([]byte, error) {
 	}
 
 	nonce, ciphertextBytes := ciphertext[:nonceSize], ciphertext[nonceSize:]
-	plaintext, err := gcm.Open(nil, nonce, ciphertextBytes, nil)
+	return gcm.Open(nil, nonce, ciphertextBytes, aad)
+}
+
+// newGCM returns AES-256-GCM for key. Keys of any other length are refused
+// rather than truncated, so a misconfigured key cannot silently select a
+// different cipher key.
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("encryption key must be 32 bytes, got %d", len(key))
+	}
+
+	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
 	}
 
-	return plaintext, nil
+	return cipher.NewGCM(block)
 }
 
-func GetEncryptionKey() []byte {
-	return encryptionKey
-}
\ No newline at end of file
+// Streaming encryption splits the plaintext into fixed-size chunks that are
+// sealed independently with AES-256-GCM (the STREAM construction), so memory
+// use is bounded by the chunk size rather than the file size. The format is:
+//
+//	magic "ZPST" | key version (1) | nonce prefix (7) | sealed chunks...
+//
+// Each chunk nonce is the prefix followed by a 4-byte big-endian counter and
+// a final-chunk flag byte, which prevents chunks from being reordered,
+// dropped or the stream from being truncated without detection.
+const (
+	streamMagic       = "ZPST"
+	streamChunkSize   = 64 * 1024
+	streamPrefixSize  = 7
+	streamHeaderSize  = len(streamMagic) + 1 + streamPrefixSize
+	streamFinalFlag   = 1
+	streamMaxChunkNum = 1<<32 - 1
+)
+
+// IsStreamCiphertext reports whether data was produced by EncryptStream.
+func IsStreamCiphertext(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(streamMagic))
+}
+
+// EncryptStream encrypts src into dst chunk by chunk. Every chunk is bound to
+// the secret id as associated data.
+func EncryptStream(dst io.Writer, src io.Reader, key []byte, id string) error {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+
+	header := make([]byte, 0, streamHeaderSize)
+	header = append(header, streamMagic...)
+	header = append(header, keyVersionFor(key))
+	prefix := make([]byte, streamPrefixSize)
+	if _, err := io.ReadFull(rand.Reader, prefix); err != nil {
+		return err
+	}
+	header = append(header, prefix...)
+	if _, err := dst.Write(header); err != nil {
+		return err
+	}
+
+	reader := bufio.NewReaderSize(src, streamChunkSize)
+	plaintext := make([]byte, streamChunkSize)
+	defer WipeBytes(plaintext)
+	sealed := make([]byte, 0, streamChunkSize+gcm.Overhead())
+
+	for counter := uint32(0); ; counter++ {
+		n, err := io.ReadFull(reader, plaintext)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+
+		final := err != nil
+		if !final {
+			// A full chunk is only the last one if nothing follows it
+			if _, peekErr := reader.Peek(1); peekErr == io.EOF {
+				final = true
+			} else if peekErr != nil {
+				return peekErr
+			}
+		}
+
+		if !final && counter == streamMaxChunkNum {
+			return fmt.Errorf("stream too large")
+		}
+
+		sealed = gcm.Seal(sealed[:0], streamNonce(prefix, counter, final), plaintext[:n], []byte(id))
+		if _, err := dst.Write(sealed); err != nil {
+			return err
+		}
+
+		if final {
+			return nil
+		}
+	}
+}
+
+// DecryptStream decrypts the output of EncryptStream from src into dst. Each
+// chunk is authenticated before it is written, so dst never receives
+// unauthenticated plaintext; on error, dst may hold a valid prefix of the file.
+func DecryptStream(dst io.Writer, src io.Reader, key []byte, id string) error {
+	header := make([]byte, streamHeaderSize)
+	if _, err := io.ReadFull(src, header); err != nil {
+		return fmt.Errorf("ciphertext too short")
+	}
+	if string(header[:len(streamMagic)]) != streamMagic {
+		return fmt.Errorf("not a stream ciphertext")
+	}
+
+	if version := header[len(streamMagic)]; version != unregisteredKeyVersion {
+		versionedKey, ok := lookupKey(version)
+		if !ok {
+			return fmt.Errorf("unknown encryption key version %d", version)
+		}
+		key = versionedKey
+	}
+	prefix := header[len(streamMagic)+1:]
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+
+	reader := bufio.NewReaderSize(src, streamChunkSize+gcm.Overhead())
+	sealed := make([]byte, streamChunkSize+gcm.Overhead())
+	plaintext := make([]byte, 0, streamChunkSize)
+	defer func() { WipeBytes(plaintext[:cap(plaintext)]) }()
+
+	for counter := uint32(0); ; counter++ {
+		n, err := io.ReadFull(reader, sealed)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+
+		final := err != nil
+		if !final {
+			if _, peekErr := reader.Peek(1); peekErr == io.EOF {
+				final = true
+			} else if peekErr != nil {
+				return peekErr
+			}
+		}
+
+		nonce := streamNonce(prefix, counter, final)
+		plaintext, err = gcm.Open(plaintext[:0], nonce, sealed[:n], []byte(id))
+		if err != nil {
+			return fmt.Errorf("chunk %d failed authentication: %v", counter, err)
+		}
+		if _, err := dst.Write(plaintext); err != nil {
+			return err
+		}
+
+		if final {
+			return nil
+		}
+		if counter == streamMaxChunkNum {
+			return fmt.Errorf("stream too large")
+		}
+	}
+}
+
+// StreamReader decrypts an EncryptStream ciphertext held in memory with
+// random access, so a download can be served in ranges. Only the chunk under
+// the read position is decrypted, and it is authenticated before any of it is
+// returned. The final chunk is opened with the final flag and must be
+// authenticated before EOF is reported, so a truncated ciphertext fails when
+// its end is read, even if it claims to hold an empty file.
+type StreamReader struct {
+	gcm    cipher.AEAD
+	prefix []byte
+	body   []byte
+	aad    []byte
+	final  bool // the final chunk has been authenticated
+
+	size   int64
+	chunks int64
+	offset int64
+
+	chunk int64
+	plain []byte
+}
+
+// NewStreamReader prepares ciphertext, the output of EncryptStream for id, for
+// reading. It fails if the header or chunk layout is malformed.
+func NewStreamReader(ciphertext, key []byte, id string) (*StreamReader, error) {
+	if len(ciphertext) < streamHeaderSize || !IsStreamCiphertext(ciphertext) {
+		return nil, fmt.Errorf("not a stream ciphertext")
+	}
+	if version := ciphertext[len(streamMagic)]; version != unregisteredKeyVersion {
+		versionedKey, ok := lookupKey(version)
+		if !ok {
+			return nil, fmt.Errorf("unknown encryption key version %d", version)
+		}
+		key = versionedKey
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	body := ciphertext[streamHeaderSize:]
+	sealedSize := int64(streamChunkSize + gcm.Overhead())
+	chunks := (int64(len(body)) + sealedSize - 1) / sealedSize
+	if chunks == 0 || chunks-1 > streamMaxChunkNum {
+		return nil, fmt.Errorf("malformed stream ciphertext")
+	}
+	last := int64(len(body)) - (chunks-1)*sealedSize
+	if last < int64(gcm.Overhead()) {
+		return nil, fmt.Errorf("malformed stream ciphertext")
+	}
+
+	return &StreamReader{
+		gcm:    gcm,
+		prefix: ciphertext[len(streamMagic)+1 : streamHeaderSize],
+		body:   body,
+		aad:    []byte(id),
+		size:   (chunks-1)*streamChunkSize + last - int64(gcm.Overhead()),
+		chunks: chunks,
+		chunk:  -1,
+	}, nil
+}
+
+// Size returns the length of the plaintext.
+func (s *StreamReader) Size() int64 {
+	return s.size
+}
+
+func (s *StreamReader) Read(p []byte) (int, error) {
+	if s.offset >= s.size {
+		if !s.final {
+			if err := s.open(s.chunks - 1); err != nil {
+				return 0, err
+			}
+		}
+		return 0, io.EOF
+	}
+	index := s.offset / streamChunkSize
+	if index != s.chunk {
+		if err := s.open(index); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, s.plain[s.offset-index*streamChunkSize:])
+	s.offset += int64(n)
+	return n, nil
+}
+
+func (s *StreamReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += s.offset
+	case io.SeekEnd:
+		offset += s.size
+	default:
+		return 0, fmt.Errorf("invalid whence %d", whence)
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("negative position")
+	}
+	s.offset = offset
+	return offset, nil
+}
+
+// Wipe clears the decrypted chunk buffer.
+func (s *StreamReader) Wipe() {
+	WipeBytes(s.plain[:cap(s.plain)])
+	s.chunk = -1
+}
+
+// open decrypts and authenticates chunk index into s.plain.
+func (s *StreamReader) open(index int64) error {
+	sealedSize := int64(streamChunkSize + s.gcm.Overhead())
+	end := min((index+1)*sealedSize, int64(len(s.body)))
+	sealed := s.body[index*sealedSize : end]
+	nonce := streamNonce(s.prefix, uint32(index), index == s.chunks-1)
+
+	plain, err := s.gcm.Open(s.plain[:0], nonce, sealed, s.aad)
+	if err != nil {
+		s.chunk = -1
+		return fmt.Errorf("chunk %d failed authentication: %v", index, err)
+	}
+	s.plain = plain
+	s.chunk = index
+	if index == s.chunks-1 {
+		s.final = true
+	}
+	return nil
+}
+
+func streamNonce(prefix []byte, counter uint32, final bool) []byte {
+	nonce := make([]byte, 12)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[streamPrefixSize:], counter)
+	if final {
+		nonce[11] = streamFinalFlag
+	}
+	return nonce
+}
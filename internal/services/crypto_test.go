@@ -0,0 +1,350 @@
+package services
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"strings"
+	"testing"
+)
+
+func testStreamCiphertext(t *testing.T, plaintext, key []byte, id string) []byte {
+	t.Helper()
+	var sealed bytes.Buffer
+	if err := EncryptStream(&sealed, bytes.NewReader(plaintext), key, id); err != nil {
+		t.Fatal(err)
+	}
+	return sealed.Bytes()
+}
+
+func TestStreamReaderRandomAccess(t *testing.T) {
+	key := make([]byte, 32)
+	rand.Read(key)
+	for _, size := range []int{0, 1, streamChunkSize - 1, streamChunkSize, 2*streamChunkSize + 123} {
+		plaintext := make([]byte, size)
+		rand.Read(plaintext)
+		reader, err := NewStreamReader(testStreamCiphertext(t, plaintext, key, "file-id"), key, "file-id")
+		if err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+		if reader.Size() != int64(size) {
+			t.Fatalf("Size() = %d, want %d", reader.Size(), size)
+		}
+
+		all, err := io.ReadAll(reader)
+		if err != nil || !bytes.Equal(all, plaintext) {
+			t.Fatalf("size %d: full read differs (err %v)", size, err)
+		}
+
+		// Ranges crossing chunk boundaries, as a Range request would read them
+		for _, start := range []int{0, size / 3, size - 1, streamChunkSize - 5} {
+			if start < 0 || start >= size {
+				continue
+			}
+			end := min(start+10, size)
+			if _, err := reader.Seek(int64(start), io.SeekStart); err != nil {
+				t.Fatal(err)
+			}
+			part := make([]byte, end-start)
+			if _, err := io.ReadFull(reader, part); err != nil {
+				t.Fatalf("size %d, range %d-%d: %v", size, start, end, err)
+			}
+			if !bytes.Equal(part, plaintext[start:end]) {
+				t.Errorf("size %d, range %d-%d differs", size, start, end)
+			}
+		}
+		reader.Wipe()
+	}
+}
+
+func TestStreamReaderRejectsTampering(t *testing.T) {
+	key := make([]byte, 32)
+	rand.Read(key)
+	plaintext := make([]byte, 2*streamChunkSize+10)
+	sealed := testStreamCiphertext(t, plaintext, key, "file-id")
+
+	if reader, err := NewStreamReader(sealed, key, "other-id"); err == nil {
+		if _, err := io.ReadAll(reader); err == nil {
+			t.Error("ciphertext opened under another id")
+		}
+	}
+
+	flipped := bytes.Clone(sealed)
+	flipped[len(flipped)-20] ^= 1
+	reader, err := NewStreamReader(flipped, key, "file-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader.Seek(-5, io.SeekEnd)
+	if _, err := io.ReadAll(reader); err == nil {
+		t.Error("modified final chunk was accepted")
+	}
+
+	// Dropping the final chunk must not pass as a shorter file
+	sealedChunk := streamChunkSize + 16
+	truncated := sealed[:streamHeaderSize+2*sealedChunk]
+	if reader, err := NewStreamReader(truncated, key, "file-id"); err == nil {
+		if _, err := io.ReadAll(reader); err == nil {
+			t.Error("truncated ciphertext read without error")
+		}
+	}
+}
+
+func TestEncryptionRejectsWrongKeyLengths(t *testing.T) {
+	for _, size := range []int{0, 16, 31, 33, 64} {
+		key := make([]byte, size)
+		if _, err := Encrypt("text", key, "id"); err == nil {
+			t.Errorf("Encrypt accepted a %d-byte key", size)
+		}
+		var sealed bytes.Buffer
+		if err := EncryptStream(&sealed, bytes.NewReader([]byte("data")), key, "id"); err == nil {
+			t.Errorf("EncryptStream accepted a %d-byte key", size)
+		}
+	}
+
+	// A 64-byte key used to be cut to its first half
+	long := make([]byte, 64)
+	rand.Read(long)
+	ciphertext, err := Encrypt("text", long[:32], "id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Decrypt(ciphertext, long, "id"); err == nil {
+		t.Error("Decrypt accepted a key that only matches in its first 32 bytes")
+	}
+}
+
+func TestCiphertextMovedToAnotherIDFails(t *testing.T) {
+	key := make([]byte, 32)
+	rand.Read(key)
+
+	ciphertext, err := Encrypt("secret", key, "id-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Decrypt(ciphertext, key, "id-b"); err == nil {
+		t.Error("text ciphertext opened under another id")
+	}
+	sealedFile, err := EncryptFile([]byte("file"), key, "id-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DecryptFile(sealedFile, key, "id-b"); err == nil {
+		t.Error("file ciphertext opened under another id")
+	}
+
+	// A ciphertext sealed without associated data must not be accepted in
+	// place of one bound to an id
+	gcm, err := newGCM(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	rand.Read(nonce)
+	unbound := append([]byte{unregisteredKeyVersion}, gcm.Seal(nonce, nonce, []byte("secret"), nil)...)
+	if _, err := open(unbound, key, []byte("id-b")); err == nil {
+		t.Error("unbound versioned ciphertext opened under an id")
+	}
+
+	stream := testStreamCiphertext(t, []byte("file"), key, "")
+	if err := DecryptStream(io.Discard, bytes.NewReader(stream), key, "id-b"); err == nil {
+		t.Error("unbound stream decrypted under an id")
+	}
+	reader, err := NewStreamReader(stream, key, "id-b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(reader); err == nil {
+		t.Error("unbound stream read under an id")
+	}
+}
+
+func TestOpenEnvelopeFormats(t *testing.T) {
+	key := make([]byte, 32)
+	rand.Read(key)
+	gcm, err := newGCM(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Ciphertexts from before the envelope header: nonce || sealed, no
+	// associated data
+	nonce := make([]byte, gcm.NonceSize())
+	rand.Read(nonce)
+	baseline := gcm.Seal(bytes.Clone(nonce), nonce, []byte("old secret"), nil)
+	plaintext, err := Decrypt(base64.StdEncoding.EncodeToString(baseline), key, "id")
+	if err != nil || plaintext != "old secret" {
+		t.Fatalf("baseline ciphertext: got %q, %v", plaintext, err)
+	}
+
+	sealed, err := seal([]byte("new secret"), key, []byte("id"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := open(sealed, key, []byte("id")); err != nil || string(got) != "new secret" {
+		t.Fatalf("envelope ciphertext: got %q, %v", got, err)
+	}
+
+	// flipped returns sealed with the byte at i changed
+	flipped := func(i int, mask byte) []byte {
+		b := bytes.Clone(sealed)
+		b[i] ^= mask
+		return b
+	}
+	for name, ciphertext := range map[string][]byte{
+		"corrupted body":        flipped(len(sealed)-1, 1),
+		"corrupted key version": flipped(len(envelopeMagic)+2, 0x80),
+		"unknown version":       flipped(len(envelopeMagic), 0x02),
+		"unknown cipher id":     flipped(len(envelopeMagic)+1, 0xf0),
+		"truncated header":      sealed[:envelopeHeaderSize-1],
+		"truncated nonce":       sealed[:envelopeHeaderSize+gcm.NonceSize()-1],
+		"versioned layout":      append([]byte{unregisteredKeyVersion}, baseline...),
+	} {
+		if _, err := open(ciphertext, key, []byte("id")); err == nil {
+			t.Errorf("%s: ciphertext opened", name)
+		}
+	}
+}
+
+func TestStreamReaderVerifiesFinalChunkOfEmptyFile(t *testing.T) {
+	key := make([]byte, 32)
+	rand.Read(key)
+
+	empty := testStreamCiphertext(t, nil, key, "file-id")
+	reader, err := NewStreamReader(empty, key, "file-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := io.ReadAll(reader); err != nil || len(got) != 0 {
+		t.Fatalf("empty file: got %d bytes, %v", len(got), err)
+	}
+
+	forged := bytes.Clone(empty)
+	rand.Read(forged[streamHeaderSize:])
+	// A longer file cut down to a single tag-sized chunk reads as empty
+	truncated := testStreamCiphertext(t, make([]byte, 2*streamChunkSize), key, "file-id")[:streamHeaderSize+16]
+	for name, ciphertext := range map[string][]byte{"forged": forged, "truncated": truncated} {
+		reader, err := NewStreamReader(ciphertext, key, "file-id")
+		if err != nil {
+			continue
+		}
+		if reader.Size() != 0 {
+			t.Fatalf("%s: size %d, want 0", name, reader.Size())
+		}
+		if _, err := io.ReadAll(reader); err == nil {
+			t.Errorf("%s: empty file read without authenticating its final chunk", name)
+		}
+	}
+}
+
+func TestVerifyPIN(t *testing.T) {
+	hash := HashPIN("1234")
+	if !strings.HasPrefix(hash, "$argon2id$") {
+		t.Fatalf("HashPIN = %q, want an Argon2id PHC string", hash)
+	}
+	if hash == HashPIN("1234") {
+		t.Error("two hashes of the same PIN are identical, the salt is not random")
+	}
+	if !VerifyPIN("1234", hash) {
+		t.Error("PIN does not verify against its own hash")
+	}
+	if VerifyPIN("1235", hash) {
+		t.Error("wrong PIN verified")
+	}
+	if VerifyPIN("", hash) || VerifyPIN("1234", "") {
+		t.Error("empty PIN or hash verified")
+	}
+
+	// Hashes from before Argon2id are unsalted hex SHA-256
+	legacy := sha256.Sum256([]byte("1234"))
+	if !VerifyPIN("1234", hex.EncodeToString(legacy[:])) {
+		t.Error("legacy SHA-256 hash does not verify")
+	}
+	if VerifyPIN("1235", hex.EncodeToString(legacy[:])) {
+		t.Error("wrong PIN verified against a legacy hash")
+	}
+
+	parts := strings.Split(hash, "$")
+	for name, malformed := range map[string]string{
+		"missing hash":   strings.Join(parts[:5], "$"),
+		"other version":  strings.Replace(hash, "$v=19$", "$v=16$", 1),
+		"zero time":      strings.Replace(hash, ",t=2,", ",t=0,", 1),
+		"zero threads":   strings.Replace(hash, ",p=1$", ",p=0$", 1),
+		"bad salt":       strings.Replace(hash, "$"+parts[4]+"$", "$!!$", 1),
+		"truncated hash": hash[:len(hash)-4],
+	} {
+		if VerifyPIN("1234", malformed) {
+			t.Errorf("%s: PIN verified against %q", name, malformed)
+		}
+	}
+}
+
+// restoreKeyRing puts the key ring back as it was when the test ends.
+func restoreKeyRing(t *testing.T) {
+	t.Helper()
+	keyRingMutex.Lock()
+	saved, savedPrimary := keyRing, primaryKeyVersion
+	keyRing = make(map[byte][]byte)
+	keyRingMutex.Unlock()
+	t.Cleanup(func() {
+		keyRingMutex.Lock()
+		keyRing, primaryKeyVersion = saved, savedPrimary
+		keyRingMutex.Unlock()
+	})
+}
+
+func TestInitEncryptionKeyRejectsMalformedInput(t *testing.T) {
+	key := make([]byte, 32)
+	rand.Read(key)
+	valid := base64.StdEncoding.EncodeToString(key)
+	other := make([]byte, 32)
+	rand.Read(other)
+	otherValid := base64.StdEncoding.EncodeToString(other)
+
+	tests := []struct {
+		name, key, version, previous string
+	}{
+		{"not base64", "not*base64!", "", ""},
+		{"hex key", hex.EncodeToString(key), "", ""},
+		{"short key", base64.StdEncoding.EncodeToString(key[:16]), "", ""},
+		{"version not a number", valid, "v2", ""},
+		{"version 0", valid, "0", ""},
+		{"version too large", valid, "256", ""},
+		{"previous without version", valid, "", otherValid},
+		{"previous with bad version", valid, "", "x:" + otherValid},
+		{"previous not base64", valid, "", "2:not*base64!"},
+		{"previous hex key", valid, "", "2:" + hex.EncodeToString(other)},
+		{"previous collides with primary", valid, "2", "2:" + otherValid},
+		{"previous listed twice", valid, "", "2:" + otherValid + ",2:" + valid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restoreKeyRing(t)
+			t.Setenv("ZEEPASS_ENCRYPTION_KEY", tt.key)
+			t.Setenv("ZEEPASS_ENCRYPTION_KEY_VERSION", tt.version)
+			t.Setenv("ZEEPASS_PREVIOUS_ENCRYPTION_KEYS", tt.previous)
+			if err := InitEncryptionKey(); err == nil {
+				t.Error("InitEncryptionKey accepted malformed key configuration")
+			}
+		})
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		restoreKeyRing(t)
+		t.Setenv("ZEEPASS_ENCRYPTION_KEY", valid)
+		t.Setenv("ZEEPASS_ENCRYPTION_KEY_VERSION", "3")
+		t.Setenv("ZEEPASS_PREVIOUS_ENCRYPTION_KEYS", " 2:"+otherValid)
+		if err := InitEncryptionKey(); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(GetEncryptionKey(), key) {
+			t.Error("primary key is not the configured key")
+		}
+		if old, ok := lookupKey(2); !ok || !bytes.Equal(old, other) {
+			t.Error("previous key was not registered")
+		}
+	})
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"crypto/rand"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+const (
+	// shortIDLength base62 characters carry about 71 bits of entropy, far
+	// more than can be guessed through the rate limited view endpoints.
+	shortIDLength = 12
+
+	// maxIDAttempts bounds how often a short ID is regenerated after it
+	// collided with a stored record.
+	maxIDAttempts = 5
+)
+
+// idStrategy returns the ID format selected with ID_STRATEGY: "hex" (the
+// default, 32 hex characters), "short" (12 base62 characters) or "uuid".
+func idStrategy() string {
+	switch strategy := strings.ToLower(os.Getenv("ID_STRATEGY")); strategy {
+	case "short", "uuid":
+		return strategy
+	default:
+		return "hex"
+	}
+}
+
+// GenerateID returns a random ID for a new message or file in the format
+// selected with ID_STRATEGY. Short IDs are checked against storage and
+// regenerated on a collision; if every attempt collides a hex ID is used.
+func GenerateID() string {
+	switch idStrategy() {
+	case "uuid":
+		return generateUUID()
+	case "short":
+		for attempt := 0; attempt < maxIDAttempts; attempt++ {
+			id := generateRandomString(shortIDLength)
+			if !idInUse(id) {
+				return id
+			}
+			log.Printf("Generated ID collided with a stored record, retrying")
+		}
+		return generateHexID()
+	default:
+		return generateHexID()
+	}
+}
+
+// ValidateIDStrategy reports an error for an unknown ID_STRATEGY so a typo
+// is caught at startup instead of silently falling back to hex IDs.
+func ValidateIDStrategy() error {
+	switch strategy := strings.ToLower(os.Getenv("ID_STRATEGY")); strategy {
+	case "", "hex", "short", "uuid":
+		return nil
+	default:
+		return fmt.Errorf("unknown ID_STRATEGY %q (expected hex, short or uuid)", strategy)
+	}
+}
+
+// idInUse reports whether a message or file is already stored under id.
+func idInUse(id string) bool {
+	backend := GetStorage()
+	if _, err := backend.GetMessage(id); err == nil {
+		return true
+	}
+	if _, err := backend.GetFile(id); err == nil {
+		return true
+	}
+	return false
+}
+
+func generateHexID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return fmt.Sprintf("%x", b)
+}
+
+// generateUUID returns a random (version 4) UUID.
+func generateUUID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,241 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// UnlimitedViews is the MaxViews value used for secrets that are limited by
+// time only.
+const UnlimitedViews = 999999
+
+// LifetimeOption is a lifetime a user can pick when creating a link. A zero
+// Duration means the link never expires by time.
+type LifetimeOption struct {
+	Value    string
+	Label    string
+	Duration time.Duration
+	MaxViews int
+}
+
+// lifetimeOptions lists the built-in lifetimes in display order.
+var lifetimeOptions = []LifetimeOption{
+	{Value: "once", Label: "Once received", MaxViews: 1},
+	{Value: "1h", Label: "1 Hour", Duration: time.Hour, MaxViews: UnlimitedViews},
+	{Value: "24h", Label: "24 Hours", Duration: 24 * time.Hour, MaxViews: UnlimitedViews},
+	{Value: "7d", Label: "7 Days", Duration: 7 * 24 * time.Hour, MaxViews: UnlimitedViews},
+	{Value: "30d", Label: "30 Days", Duration: 30 * 24 * time.Hour, MaxViews: UnlimitedViews},
+	{Value: "never", Label: "Never expires", MaxViews: UnlimitedViews},
+}
+
+// Custom lifetimes given as a ttl must lie within these bounds, so typos
+// such as "9999d" are rejected rather than kept for decades.
+const (
+	MinCustomTTL = time.Minute
+	MaxCustomTTL = 365 * 24 * time.Hour
+)
+
+// ResolvedLifetime is the expiry and view limit a lifetime option resolves to.
+type ResolvedLifetime struct {
+	ExpiresAt *time.Time
+	MaxViews  int
+	// Label describes the lifetime for display, e.g. "7 Days"
+	Label string
+	// Clamped is set when the requested lifetime was shortened to the
+	// configured maximum.
+	Clamped bool
+}
+
+// ResolveLifetime turns a lifetime option into an expiry and view limit.
+// Unknown values resolve to "once". When MAX_LIFETIME is set, lifetimes
+// longer than it (including "never" and "once", which otherwise wait
+// indefinitely) are clamped to it, or rejected with an error when
+// MAX_LIFETIME_POLICY=reject.
+func ResolveLifetime(value string) (ResolvedLifetime, error) {
+	return resolveOption(lookupLifetimeOption(value))
+}
+
+// ResolveCustomLifetime resolves a ttl parsed with ParseTTL like a preset of
+// that duration, including the MAX_LIFETIME cap. The ttl only replaces the
+// expiry: the view limit is the one of the chosen preset, so "once" with a
+// ttl stays a one-time link that also expires after ttl.
+func ResolveCustomLifetime(preset string, ttl time.Duration) (ResolvedLifetime, error) {
+	return resolveOption(LifetimeOption{
+		Value:    "custom",
+		Label:    FormatLifetime(ttl),
+		Duration: ttl,
+		MaxViews: lookupLifetimeOption(preset).MaxViews,
+	})
+}
+
+// lookupLifetimeOption returns the preset named value, or "once" for
+// unknown values.
+func lookupLifetimeOption(value string) LifetimeOption {
+	for _, o := range lifetimeOptions {
+		if o.Value == value {
+			return o
+		}
+	}
+	return lifetimeOptions[0]
+}
+
+func resolveOption(option LifetimeOption) (ResolvedLifetime, error) {
+	resolved := ResolvedLifetime{MaxViews: option.MaxViews, Label: option.Label}
+	duration := option.Duration
+
+	if maxLifetime := MaxLifetime(); maxLifetime > 0 {
+		switch {
+		case exceedsMaxLifetime(option, maxLifetime):
+			if rejectLongLifetimes() {
+				return resolved, fmt.Errorf("the lifetime %q exceeds the maximum allowed lifetime of %s", option.Label, FormatLifetime(maxLifetime))
+			}
+			duration = maxLifetime
+			resolved.Clamped = true
+		case duration == 0:
+			// Once-received links still burn on read, but an unread one may
+			// not outlive the cap either
+			duration = maxLifetime
+		}
+	}
+
+	if duration > 0 {
+		expiresAt := time.Now().Add(duration)
+		resolved.ExpiresAt = &expiresAt
+	}
+	return resolved, nil
+}
+
+// ParseTTL parses a custom lifetime: a Go duration ("90m", "36h"), a number
+// of days ("3d") or a plain number of minutes ("45"). It must lie between
+// MinCustomTTL and MaxCustomTTL.
+func ParseTTL(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	var ttl time.Duration
+	if n, err := strconv.Atoi(value); err == nil {
+		ttl = boundedMultiple(n, time.Minute)
+	} else if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid ttl %q (use e.g. 90m, 36h, 3d or minutes)", value)
+		}
+		ttl = boundedMultiple(n, 24*time.Hour)
+	} else {
+		ttl, err = time.ParseDuration(value)
+		if err != nil {
+			return 0, fmt.Errorf("invalid ttl %q (use e.g. 90m, 36h, 3d or minutes)", value)
+		}
+	}
+
+	switch {
+	case ttl <= 0:
+		return 0, fmt.Errorf("ttl must be positive")
+	case ttl < MinCustomTTL:
+		return 0, fmt.Errorf("ttl must be at least %s", MinCustomTTL)
+	case ttl > MaxCustomTTL:
+		return 0, fmt.Errorf("ttl must be at most %s", FormatLifetime(MaxCustomTTL))
+	}
+	return ttl, nil
+}
+
+// boundedMultiple returns n units, or just over MaxCustomTTL when that would
+// be exceeded, so huge values cannot overflow.
+func boundedMultiple(n int, unit time.Duration) time.Duration {
+	if n > int(MaxCustomTTL/unit) {
+		return MaxCustomTTL + 1
+	}
+	return time.Duration(n) * unit
+}
+
+// LifetimeDuration returns the duration of a lifetime option, or zero for
+// options that do not expire by time and unknown values.
+func LifetimeDuration(value string) time.Duration {
+	for _, option := range lifetimeOptions {
+		if option.Value == value {
+			return option.Duration
+		}
+	}
+	return 0
+}
+
+// LifetimeOptions returns the lifetimes that can currently be selected. With
+// MAX_LIFETIME_POLICY=reject, options exceeding MAX_LIFETIME are left out;
+// otherwise their labels note that they are capped.
+func LifetimeOptions() []LifetimeOption {
+	maxLifetime := MaxLifetime()
+	options := make([]LifetimeOption, 0, len(lifetimeOptions))
+	for _, option := range lifetimeOptions {
+		if maxLifetime > 0 && exceedsMaxLifetime(option, maxLifetime) {
+			if rejectLongLifetimes() {
+				continue
+			}
+			option.Label = fmt.Sprintf("%s (capped at %s)", option.Label, FormatLifetime(maxLifetime))
+		}
+		options = append(options, option)
+	}
+	return options
+}
+
+// exceedsMaxLifetime reports whether option would keep a readable link around
+// longer than maxLifetime. "never" always does; "once" never does since it is
+// burned on read.
+func exceedsMaxLifetime(option LifetimeOption, maxLifetime time.Duration) bool {
+	if option.Duration == 0 {
+		return option.MaxViews != 1
+	}
+	return option.Duration > maxLifetime
+}
+
+// LifetimeLabel returns the display label of a lifetime option.
+func LifetimeLabel(value string) string {
+	for _, option := range lifetimeOptions {
+		if option.Value == value {
+			return option.Label
+		}
+	}
+	return lifetimeOptions[0].Label
+}
+
+// MaxLifetime returns the longest lifetime a link may have, configured with
+// MAX_LIFETIME as a Go duration ("12h") or a number of days ("7d"). Zero
+// means no cap.
+func MaxLifetime() time.Duration {
+	value := strings.TrimSpace(os.Getenv("MAX_LIFETIME"))
+	if value == "" {
+		return 0
+	}
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n > 0 {
+			return time.Duration(n) * 24 * time.Hour
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil && d > 0 {
+		return d
+	}
+	return 0
+}
+
+func rejectLongLifetimes() bool {
+	return os.Getenv("MAX_LIFETIME_POLICY") == "reject"
+}
+
+// FormatLifetime renders a duration in days or hours for messages.
+func FormatLifetime(d time.Duration) string {
+	if d >= 24*time.Hour && d%(24*time.Hour) == 0 {
+		days := int(d / (24 * time.Hour))
+		if days == 1 {
+			return "1 day"
+		}
+		return fmt.Sprintf("%d days", days)
+	}
+	if d >= time.Hour && d%time.Hour == 0 {
+		hours := int(d / time.Hour)
+		if hours == 1 {
+			return "1 hour"
+		}
+		return fmt.Sprintf("%d hours", hours)
+	}
+	return d.String()
+}
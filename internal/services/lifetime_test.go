@@ -0,0 +1,31 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCustomLifetimeKeepsPresetViewLimit(t *testing.T) {
+	t.Setenv("MAX_LIFETIME", "")
+	tests := []struct {
+		preset   string
+		maxViews int
+	}{
+		{"once", 1},
+		{"24h", UnlimitedViews},
+		{"never", UnlimitedViews},
+		{"bogus", 1},
+	}
+	for _, tt := range tests {
+		resolved, err := ResolveCustomLifetime(tt.preset, 2*time.Hour)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.preset, err)
+		}
+		if resolved.MaxViews != tt.maxViews {
+			t.Errorf("%s with a ttl: MaxViews %d, want %d", tt.preset, resolved.MaxViews, tt.maxViews)
+		}
+		if resolved.ExpiresAt == nil || time.Until(*resolved.ExpiresAt) < 119*time.Minute || time.Until(*resolved.ExpiresAt) > 2*time.Hour {
+			t.Errorf("%s with a ttl: expires at %v, want in 2h", tt.preset, resolved.ExpiresAt)
+		}
+	}
+}
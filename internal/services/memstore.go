@@ -0,0 +1,158 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// memoryStoreSweepInterval is how often expired entries are purged from the
+// in-memory store.
+const memoryStoreSweepInterval = time.Minute
+
+// MemoryBackend keeps records in process memory. It is used when Redis is
+// unavailable and in tests. Data does not survive a restart and is not shared
+// between instances.
+type MemoryBackend struct {
+	recordBackend
+}
+
+// NewMemoryBackend returns an empty in-memory StorageBackend and starts the
+// sweeper that removes expired entries.
+func NewMemoryBackend() *MemoryBackend {
+	store := newMemoryStore()
+	go store.sweep(memoryStoreSweepInterval)
+	return &MemoryBackend{recordBackend{store: store}}
+}
+
+// memoryStore is a mutex-guarded map whose entries expire after their TTL
+// like Redis keys do. Expired entries are hidden from reads immediately and
+// removed by a background sweeper.
+type memoryStore struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+}
+
+type memoryEntry struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{entries: make(map[string]memoryEntry)}
+}
+
+// Set stores a copy of value under key for ttl.
+func (m *memoryStore) Set(key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[key] = memoryEntry{
+		value:     append([]byte(nil), value...),
+		expiresAt: time.Now().Add(ttl),
+	}
+	return nil
+}
+
+// Get returns the value stored under key, or ErrNotFound if it is
+// missing or expired.
+func (m *memoryStore) Get(key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.entries[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if time.Now().After(entry.expiresAt) {
+		delete(m.entries, key)
+		return nil, ErrNotFound
+	}
+	return append([]byte(nil), entry.value...), nil
+}
+
+// Del removes key.
+func (m *memoryStore) Del(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.entries, key)
+	return nil
+}
+
+// GetDel returns and removes the value at key.
+func (m *memoryStore) GetDel(key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.entries[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	delete(m.entries, key)
+	if time.Now().After(entry.expiresAt) {
+		return nil, ErrNotFound
+	}
+	return entry.value, nil
+}
+
+// Incr increments the decimal counter stored at key, seeding a missing or
+// expired counter with seed and ttl first.
+func (m *memoryStore) Incr(key string, seed int, ttl time.Duration) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.entries[key]
+	count := seed
+	if ok && !time.Now().After(entry.expiresAt) {
+		parsed, err := strconv.Atoi(string(entry.value))
+		if err != nil {
+			return 0, fmt.Errorf("value at %s is not a counter", key)
+		}
+		count = parsed
+	} else {
+		entry.expiresAt = time.Now().Add(ttl)
+	}
+
+	count++
+	entry.value = []byte(strconv.Itoa(count))
+	m.entries[key] = entry
+	return count, nil
+}
+
+// Decr decrements the counter at key if it exists and is above zero.
+func (m *memoryStore) Decr(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.entries[key]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return nil
+	}
+	count, err := strconv.Atoi(string(entry.value))
+	if err != nil {
+		return fmt.Errorf("value at %s is not a counter", key)
+	}
+	if count > 0 {
+		entry.value = []byte(strconv.Itoa(count - 1))
+		m.entries[key] = entry
+	}
+	return nil
+}
+
+// removeExpired drops every entry whose TTL has passed.
+func (m *memoryStore) removeExpired() {
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key, entry := range m.entries {
+		if now.After(entry.expiresAt) {
+			delete(m.entries, key)
+		}
+	}
+}
+
+func (m *memoryStore) sweep(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		m.removeExpired()
+	}
+}
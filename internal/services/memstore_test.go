@@ -0,0 +1,66 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/anazri/zeepass/internal/models"
+)
+
+func TestMemoryStoreExpiresEntries(t *testing.T) {
+	store := newMemoryStore()
+	for _, key := range []string{"get", "getdel", "counter", "swept"} {
+		if err := store.Set(key, []byte("1"), 20*time.Millisecond); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := store.Get("get"); err != nil {
+		t.Fatalf("entry expired early: %v", err)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if _, err := store.Get("get"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get of an expired entry error = %v, want ErrNotFound", err)
+	}
+	if _, err := store.GetDel("getdel"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetDel of an expired entry error = %v, want ErrNotFound", err)
+	}
+	// An expired counter starts over from the seed with a fresh TTL
+	if count, err := store.Incr("counter", 5, time.Hour); err != nil || count != 6 {
+		t.Errorf("Incr of an expired counter = %d, %v, want 6", count, err)
+	}
+
+	store.removeExpired()
+	if _, ok := store.entries["swept"]; ok {
+		t.Error("removeExpired kept an expired entry")
+	}
+	if _, ok := store.entries["counter"]; !ok {
+		t.Error("removeExpired dropped a live entry")
+	}
+}
+
+func TestRecordTTL(t *testing.T) {
+	if ttl := recordTTL(nil); ttl != 24*time.Hour {
+		t.Errorf("recordTTL(nil) = %v, want 24h", ttl)
+	}
+	past := time.Now().Add(-time.Hour)
+	if ttl := recordTTL(&past); ttl != time.Minute {
+		t.Errorf("recordTTL of a past expiry = %v, want 1m", ttl)
+	}
+	future := time.Now().Add(time.Hour)
+	if ttl := recordTTL(&future); ttl <= 59*time.Minute || ttl > time.Hour {
+		t.Errorf("recordTTL of an expiry in 1h = %v", ttl)
+	}
+
+	// A secret without an expiry is kept for the default TTL
+	backend := NewMemoryBackend()
+	if err := backend.StoreMessage("no-expiry", &models.EncryptedData{ID: "no-expiry", Content: "ciphertext", MaxViews: 1}); err != nil {
+		t.Fatal(err)
+	}
+	store := backend.store.(*memoryStore)
+	entry := store.entries[messageKey("no-expiry")]
+	if remaining := time.Until(entry.expiresAt); remaining <= 23*time.Hour || remaining > 24*time.Hour {
+		t.Errorf("message without an expiry is kept for %v, want 24h", remaining)
+	}
+}
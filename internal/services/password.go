@@ -2,8 +2,17 @@ package services
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math"
 	"math/big"
+	"os"
+	"sort"
+	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 type PasswordOptions struct {
@@ -12,7 +21,109 @@ type PasswordOptions struct {
 	UseUppercase bool `json:"use_uppercase"`
 	UseLowercase bool `json:"use_lowercase"`
 	UseSymbols   bool `json:"use_symbols"`
-	Type         string `json:"type"` // "random", "memorable", "pin"
+	Type         string `json:"type"` // "random", "memorable", "pin", "passphrase"
+	Preset       string `json:"preset,omitempty"` // Named policy, overrides the other options
+	Words        int    `json:"words,omitempty"`    // Number of words for "passphrase", defaults to Length
+	Checksum     bool   `json:"checksum,omitempty"` // Append a checksum word to a "passphrase"
+	Separator    string `json:"separator,omitempty"`  // Joins "passphrase" words, "-" by default
+	Capitalize   bool   `json:"capitalize,omitempty"` // Capitalize each "passphrase" word
+	AddNumber    bool   `json:"add_number,omitempty"` // Append a random digit to a "passphrase"
+}
+
+// PasswordPreset is a named password policy that expands into concrete
+// PasswordOptions plus constraints the generated password must satisfy.
+type PasswordPreset struct {
+	Name              string          `json:"name"`
+	Description       string          `json:"description"`
+	Options           PasswordOptions `json:"options"`
+	RequireAllClasses bool            `json:"require_all_classes"` // Every enabled character class must appear
+}
+
+// passwordPresets holds the built-in compliance presets, keyed by name.
+var passwordPresets = map[string]PasswordPreset{
+	"nist": {
+		Name:        "nist",
+		Description: "NIST SP 800-63B: long random password without composition rules",
+		Options: PasswordOptions{
+			Length:       16,
+			UseNumbers:   true,
+			UseUppercase: true,
+			UseLowercase: true,
+			Type:         "random",
+		},
+	},
+	"pci": {
+		Name:        "pci",
+		Description: "PCI DSS v4.0: at least 12 characters with both letters and numbers",
+		Options: PasswordOptions{
+			Length:       12,
+			UseNumbers:   true,
+			UseUppercase: true,
+			UseLowercase: true,
+			Type:         "random",
+		},
+		RequireAllClasses: true,
+	},
+	"corporate-16": {
+		Name:        "corporate-16",
+		Description: "16 characters including uppercase, lowercase, numbers and symbols",
+		Options: PasswordOptions{
+			Length:       16,
+			UseNumbers:   true,
+			UseUppercase: true,
+			UseLowercase: true,
+			UseSymbols:   true,
+			Type:         "random",
+		},
+		RequireAllClasses: true,
+	},
+}
+
+// GetPasswordPreset looks up a built-in preset by name (case-insensitive).
+func GetPasswordPreset(name string) (PasswordPreset, bool) {
+	preset, ok := passwordPresets[strings.ToLower(strings.TrimSpace(name))]
+	return preset, ok
+}
+
+// PasswordPresetNames returns the names of all built-in presets in sorted order.
+func PasswordPresetNames() []string {
+	names := make([]string, 0, len(passwordPresets))
+	for name := range passwordPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// MeetsPreset reports whether password satisfies the preset's length and
+// character class constraints.
+func MeetsPreset(password string, preset PasswordPreset) bool {
+	if len(password) < preset.Options.Length {
+		return false
+	}
+	if !preset.RequireAllClasses {
+		return true
+	}
+	opts := preset.Options
+	return (!opts.UseNumbers || containsNumbers(password)) &&
+		(!opts.UseUppercase || containsUppercase(password)) &&
+		(!opts.UseLowercase || containsLowercase(password)) &&
+		(!opts.UseSymbols || containsSymbols(password))
+}
+
+func generatePresetPassword(preset PasswordPreset) (string, error) {
+	// Regenerate until the class constraints are met; with the preset lengths
+	// this almost always succeeds on the first or second attempt.
+	for attempt := 0; attempt < 100; attempt++ {
+		password, err := generateRandomPassword(preset.Options)
+		if err != nil {
+			return "", err
+		}
+		if MeetsPreset(password, preset) {
+			return password, nil
+		}
+	}
+	return "", fmt.Errorf("failed to generate a password meeting preset %s", preset.Name)
 }
 
 const (
@@ -28,12 +139,145 @@ var memorableWords = []string{
 	"apple", "book", "car", "door", "fish", "game", "house", "ice", "key", "lamp",
 }
 
+const (
+	defaultPasswordMinLength = 8
+	defaultPasswordMaxLength = 128
+
+	// minPINLength is the shortest PIN the generator accepts. PINs are not
+	// bound by PASSWORD_MIN_LENGTH, so short numeric codes stay available.
+	minPINLength = 4
+
+	defaultPasswordLength = 20
+)
+
+// PasswordLengthLimits returns the accepted range for generated password
+// lengths, configurable with PASSWORD_MIN_LENGTH and PASSWORD_MAX_LENGTH.
+func PasswordLengthLimits() (int, int) {
+	min, max := defaultPasswordMinLength, defaultPasswordMaxLength
+	if n, err := strconv.Atoi(os.Getenv("PASSWORD_MIN_LENGTH")); err == nil && n > 0 {
+		min = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("PASSWORD_MAX_LENGTH")); err == nil && n > 0 {
+		max = n
+	}
+	if max < min {
+		max = min
+	}
+	return min, max
+}
+
+// PINLengthLimits returns the accepted range for generated PIN lengths: at
+// least minPINLength digits, up to the PASSWORD_MAX_LENGTH maximum.
+func PINLengthLimits() (int, int) {
+	_, max := PasswordLengthLimits()
+	if max < minPINLength {
+		max = minPINLength
+	}
+	return minPINLength, max
+}
+
+// ValidatePasswordLength checks the requested length against the limits for
+// its type: PINLengthLimits for PINs, PasswordLengthLimits otherwise.
+// Passphrases are sized in words and presets carry their own length, so
+// neither is checked.
+func ValidatePasswordLength(opts PasswordOptions) error {
+	if opts.Preset != "" || opts.Type == "passphrase" {
+		return nil
+	}
+	min, max := PasswordLengthLimits()
+	if opts.Type == "pin" {
+		min, max = PINLengthLimits()
+	}
+	if opts.Length < min {
+		return fmt.Errorf("password length %d is below the minimum of %d", opts.Length, min)
+	}
+	if opts.Length > max {
+		return fmt.Errorf("password length %d is above the maximum of %d", opts.Length, max)
+	}
+	return nil
+}
+
+// DefaultPasswordOptions returns the deployment's generator defaults, used
+// to preselect the generator page and to fill in omitted request options:
+// PASSWORD_DEFAULT_LENGTH (default 20, kept within PasswordLengthLimits),
+// PASSWORD_DEFAULT_CLASSES, a comma separated subset of numbers, uppercase,
+// lowercase and symbols (default numbers,uppercase,lowercase), and
+// PASSWORD_DEFAULT_TYPE (random, memorable, pin or passphrase; default
+// random). Invalid values fall back to the built-in defaults.
+func DefaultPasswordOptions() PasswordOptions {
+	min, max := PasswordLengthLimits()
+	opts := PasswordOptions{Length: defaultPasswordLength, Type: "random"}
+	if n, err := strconv.Atoi(os.Getenv("PASSWORD_DEFAULT_LENGTH")); err == nil && n > 0 {
+		opts.Length = n
+	}
+	if opts.Length < min {
+		opts.Length = min
+	}
+	if opts.Length > max {
+		opts.Length = max
+	}
+
+	classes := os.Getenv("PASSWORD_DEFAULT_CLASSES")
+	if classes == "" {
+		classes = "numbers,uppercase,lowercase"
+	}
+	for _, class := range strings.Split(classes, ",") {
+		switch strings.ToLower(strings.TrimSpace(class)) {
+		case "numbers":
+			opts.UseNumbers = true
+		case "uppercase":
+			opts.UseUppercase = true
+		case "lowercase":
+			opts.UseLowercase = true
+		case "symbols":
+			opts.UseSymbols = true
+		}
+	}
+	if !opts.UseNumbers && !opts.UseUppercase && !opts.UseLowercase && !opts.UseSymbols {
+		opts.UseNumbers, opts.UseUppercase, opts.UseLowercase = true, true, true
+	}
+
+	switch passwordType := os.Getenv("PASSWORD_DEFAULT_TYPE"); passwordType {
+	case "random", "memorable", "pin", "passphrase":
+		opts.Type = passwordType
+	}
+	return opts
+}
+
+// ApplyPasswordDefaults fills in the options a request left out from
+// DefaultPasswordOptions: the type, the length (not for passphrases, which
+// are sized in words) and the character classes when none is enabled.
+// Presets carry their own options and are left alone.
+func ApplyPasswordDefaults(opts *PasswordOptions) {
+	if opts.Preset != "" {
+		return
+	}
+	defaults := DefaultPasswordOptions()
+	if opts.Type == "" {
+		opts.Type = defaults.Type
+	}
+	if opts.Length == 0 && opts.Type != "passphrase" {
+		opts.Length = defaults.Length
+	}
+	if !opts.UseNumbers && !opts.UseUppercase && !opts.UseLowercase && !opts.UseSymbols {
+		opts.UseNumbers = defaults.UseNumbers
+		opts.UseUppercase = defaults.UseUppercase
+		opts.UseLowercase = defaults.UseLowercase
+		opts.UseSymbols = defaults.UseSymbols
+	}
+}
+
 func GeneratePassword(opts PasswordOptions) (string, error) {
-	if opts.Length < 4 {
-		opts.Length = 4
+	if opts.Preset != "" {
+		preset, ok := GetPasswordPreset(opts.Preset)
+		if !ok {
+			return "", fmt.Errorf("unknown password preset: %s", opts.Preset)
+		}
+		return generatePresetPassword(preset)
 	}
-	if opts.Length > 64 {
-		opts.Length = 64
+
+	if err := ValidatePasswordLength(opts); err != nil {
+		return "", err
 	}
 
 	switch opts.Type {
@@ -41,6 +285,8 @@ func GeneratePassword(opts PasswordOptions) (string, error) {
 		return generatePIN(opts.Length)
 	case "memorable":
 		return generateMemorablePassword(opts.Length)
+	case "passphrase":
+		return GeneratePassphrase(opts)
 	default:
 		return generateRandomPassword(opts)
 	}
@@ -150,39 +396,221 @@ func generateMemorablePassword(length int) (string, error) {
 	return result, nil
 }
 
-func CalculatePasswordStrength(password string) string {
-	score := 0
-	
-	// Length score
-	if len(password) >= 12 {
-		score += 2
-	} else if len(password) >= 8 {
-		score += 1
+// Passphrases are words from the EFF long wordlist joined by
+// passphraseSeparator unless PasswordOptions.Separator says otherwise.
+const (
+	passphraseSeparator    = "-"
+	defaultPassphraseWords = 5
+	minPassphraseWords     = 3
+	maxPassphraseWords     = 12
+	maxSeparatorLength     = 5
+)
+
+// passphraseWordCount returns the number of words for a passphrase: Words
+// when set, otherwise Length read as a word count, clamped to the allowed
+// range.
+func passphraseWordCount(opts PasswordOptions) int {
+	words := opts.Words
+	if words == 0 {
+		words = opts.Length
 	}
-	
-	// Character variety score
-	if containsNumbers(password) {
-		score += 1
+	if words == 0 {
+		words = defaultPassphraseWords
 	}
-	if containsLowercase(password) {
-		score += 1
+	if words < minPassphraseWords {
+		words = minPassphraseWords
 	}
-	if containsUppercase(password) {
-		score += 1
+	if words > maxPassphraseWords {
+		words = maxPassphraseWords
+	}
+	return words
+}
+
+// GeneratePassphrase returns diceware words picked uniformly from the EFF
+// long wordlist and joined by the separator, optionally capitalized and
+// followed by a random digit. With Checksum set, a final word derived from
+// the others is appended so that a recipient can detect transcription errors
+// with VerifyPassphraseChecksum. The checksum word adds no entropy.
+func GeneratePassphrase(opts PasswordOptions) (string, error) {
+	if err := ValidatePassphraseSeparator(opts.Separator); err != nil {
+		return "", err
 	}
-	if containsSymbols(password) {
-		score += 1
+	separator := opts.Separator
+	if separator == "" {
+		separator = passphraseSeparator
 	}
-	
-	if score >= 5 {
+
+	words := passphraseWordCount(opts)
+	selected := make([]string, 0, words+1)
+	for i := 0; i < words; i++ {
+		wordIndex, err := rand.Int(rand.Reader, big.NewInt(int64(len(effLargeWords))))
+		if err != nil {
+			return "", err
+		}
+		selected = append(selected, effLargeWords[wordIndex.Int64()])
+	}
+
+	if opts.Checksum {
+		selected = append(selected, PassphraseChecksumWord(selected))
+	}
+	if opts.Capitalize {
+		for i, word := range selected {
+			selected[i] = strings.ToUpper(word[:1]) + word[1:]
+		}
+	}
+
+	passphrase := strings.Join(selected, separator)
+	if opts.AddNumber {
+		digit, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", err
+		}
+		passphrase += digit.String()
+	}
+	return passphrase, nil
+}
+
+// ValidatePassphraseSeparator rejects separators that are too long or that
+// contain letters, which would make the words impossible to split apart.
+func ValidatePassphraseSeparator(separator string) error {
+	if len(separator) > maxSeparatorLength || strings.ContainsFunc(separator, unicode.IsLetter) {
+		return fmt.Errorf("separator must be at most %d characters and contain no letters", maxSeparatorLength)
+	}
+	return nil
+}
+
+// PassphraseEntropy returns the entropy in bits of a passphrase generated
+// with opts: log2(7776) per word plus log2(10) for the trailing digit.
+// Capitalization, the separator and the checksum word are fixed by the
+// options and add nothing.
+func PassphraseEntropy(opts PasswordOptions) float64 {
+	bits := float64(passphraseWordCount(opts)) * math.Log2(effLargeWordlistSize)
+	if opts.AddNumber {
+		bits += math.Log2(10)
+	}
+	return bits
+}
+
+// PassphraseChecksumWord derives the checksum word for words: the first two
+// bytes of the SHA-256 of the normalized words, taken as an index into the
+// wordlist.
+func PassphraseChecksumWord(words []string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.Join(words, passphraseSeparator))))
+	index := int(binary.BigEndian.Uint16(sum[:2])) % len(effLargeWords)
+	return effLargeWords[index]
+}
+
+// VerifyPassphraseChecksum checks that the last word of passphrase is the
+// checksum of the preceding words. Words may be separated by any
+// non-letter characters, are compared case-insensitively, and a trailing
+// number is ignored.
+func VerifyPassphraseChecksum(passphrase string) (bool, error) {
+	words := splitPassphrase(passphrase)
+	if len(words) < minPassphraseWords+1 {
+		return false, fmt.Errorf("passphrase must have at least %d words plus a checksum word", minPassphraseWords)
+	}
+
+	// Hyphenated words such as "yo-yo" come back as two parts. The checksum
+	// is taken over the words joined by hyphens, so only the checksum word
+	// itself needs to be reassembled.
+	last := len(words) - 1
+	if words[last] == PassphraseChecksumWord(words[:last]) {
+		return true, nil
+	}
+	if joined := words[last-1] + "-" + words[last]; effLargeWordSet[joined] {
+		return joined == PassphraseChecksumWord(words[:last-1]), nil
+	}
+	return false, nil
+}
+
+func splitPassphrase(passphrase string) []string {
+	passphrase = strings.TrimRightFunc(passphrase, unicode.IsDigit)
+	return strings.FieldsFunc(strings.ToLower(passphrase), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+}
+
+// Entropy thresholds in bits for the strength labels.
+const (
+	strongPasswordEntropy = 60
+	mediumPasswordEntropy = 40
+)
+
+// Character pools used to estimate entropy. Symbols counts every printable
+// ASCII punctuation character plus space, not only the generator's Symbols,
+// and any non-ASCII character adds a rough allowance.
+const (
+	digitPool    = 10
+	letterPool   = 26
+	symbolPool   = 33
+	nonASCIIPool = 100
+)
+
+// CalculatePasswordStrength labels a password weak, medium or strong from
+// its estimated entropy.
+func CalculatePasswordStrength(password string) string {
+	return StrengthForEntropy(CalculatePasswordEntropy(password))
+}
+
+// StrengthForEntropy maps an entropy in bits to the weak, medium or strong
+// label.
+func StrengthForEntropy(entropy float64) string {
+	if entropy >= strongPasswordEntropy {
 		return "strong"
-	} else if score >= 3 {
+	} else if entropy >= mediumPasswordEntropy {
 		return "medium"
 	} else {
 		return "weak"
 	}
 }
 
+// CalculatePasswordEntropy estimates the entropy of password in bits as
+// length × log2(pool), where the pool is the combined size of the character
+// classes it uses. This is an upper bound that assumes every character was
+// picked at random: dictionary words and patterns make real passwords
+// weaker. Generated passphrases report their exact entropy through
+// PassphraseEntropy instead.
+func CalculatePasswordEntropy(password string) float64 {
+	pool := 0
+	if containsNumbers(password) {
+		pool += digitPool
+	}
+	if containsLowercase(password) {
+		pool += letterPool
+	}
+	if containsUppercase(password) {
+		pool += letterPool
+	}
+	if containsOtherASCII(password) {
+		pool += symbolPool
+	}
+	if containsNonASCII(password) {
+		pool += nonASCIIPool
+	}
+	if pool == 0 {
+		return 0
+	}
+	return float64(utf8.RuneCountInString(password)) * math.Log2(float64(pool))
+}
+
+func containsOtherASCII(s string) bool {
+	for _, char := range s {
+		if char >= ' ' && char <= '~' && !unicode.IsLetter(char) && !unicode.IsDigit(char) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsNonASCII(s string) bool {
+	for _, char := range s {
+		if char > unicode.MaxASCII {
+			return true
+		}
+	}
+	return false
+}
+
 func containsNumbers(s string) bool {
 	for _, char := range s {
 		if char >= '0' && char <= '9' {
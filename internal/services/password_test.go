@@ -0,0 +1,87 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+// Passphrases are sized in words, so a character length such as 30 must not
+// turn into 30 words.
+func TestPassphraseWordCountIsBounded(t *testing.T) {
+	tests := []struct {
+		opts PasswordOptions
+		want int
+	}{
+		{PasswordOptions{}, defaultPassphraseWords},
+		{PasswordOptions{Length: 30}, maxPassphraseWords},
+		{PasswordOptions{Words: 1}, minPassphraseWords},
+		{PasswordOptions{Words: 7, Length: 30}, 7},
+	}
+	for _, tt := range tests {
+		if got := passphraseWordCount(tt.opts); got != tt.want {
+			t.Errorf("passphraseWordCount(%+v) = %d, want %d", tt.opts, got, tt.want)
+		}
+	}
+}
+
+func TestGeneratePassphraseUsesWordlist(t *testing.T) {
+	passphrase, err := GeneratePassphrase(PasswordOptions{Type: "passphrase", Separator: " "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	words := strings.Split(passphrase, " ")
+	if len(words) != defaultPassphraseWords {
+		t.Fatalf("%q has %d words, want %d", passphrase, len(words), defaultPassphraseWords)
+	}
+	for _, word := range words {
+		if !effLargeWordSet[word] {
+			t.Errorf("%q is not in the EFF long wordlist", word)
+		}
+	}
+	if bits := PassphraseEntropy(PasswordOptions{}); bits < 64 {
+		t.Errorf("default passphrase entropy is %.1f bits", bits)
+	}
+}
+
+func TestPassphraseChecksumRoundTrip(t *testing.T) {
+	passphrase, err := GeneratePassphrase(PasswordOptions{Words: 6, Checksum: true, Capitalize: true, AddNumber: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := VerifyPassphraseChecksum(passphrase); err != nil || !ok {
+		t.Fatalf("VerifyPassphraseChecksum(%q) = %v, %v", passphrase, ok, err)
+	}
+
+	words := []string{"abacus", "abdomen", "abdominal", "abide", "abiding"}
+	checksum := PassphraseChecksumWord(words)
+	if ok, _ := VerifyPassphraseChecksum(strings.Join(append(words, checksum), " ")); !ok {
+		t.Fatal("checksum word rejected")
+	}
+	swapped := []string{"abdomen", "abacus", "abdominal", "abide", "abiding", checksum}
+	if ok, _ := VerifyPassphraseChecksum(strings.Join(swapped, " ")); ok {
+		t.Error("swapped words passed the checksum")
+	}
+}
+
+func TestValidatePasswordLengthPerType(t *testing.T) {
+	t.Setenv("PASSWORD_MIN_LENGTH", "")
+	t.Setenv("PASSWORD_MAX_LENGTH", "")
+	tests := []struct {
+		opts PasswordOptions
+		ok   bool
+	}{
+		{PasswordOptions{Type: "pin", Length: 4}, true},
+		{PasswordOptions{Type: "pin", Length: 6}, true},
+		{PasswordOptions{Type: "pin", Length: 3}, false},
+		{PasswordOptions{Type: "pin", Length: 129}, false},
+		{PasswordOptions{Type: "random", Length: 6}, false},
+		{PasswordOptions{Type: "random", Length: 8}, true},
+		{PasswordOptions{Type: "memorable", Length: 4}, false},
+		{PasswordOptions{Type: "passphrase", Length: 2}, true},
+	}
+	for _, tt := range tests {
+		if err := ValidatePasswordLength(tt.opts); (err == nil) != tt.ok {
+			t.Errorf("ValidatePasswordLength(%+v) = %v, want ok %v", tt.opts, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Randomart field size and symbols, as in OpenSSH's sshkey.c. Symbols are
+// ordered by how often the bishop visited a square; the two last ones mark
+// the start and end positions.
+const (
+	randomartWidth   = 17
+	randomartHeight  = 9
+	randomartSymbols = " .o+=*BOX@%&#/^SE"
+)
+
+// Randomart draws a key fingerprint digest as the "drunken bishop" picture
+// ssh-keygen -lv prints, so two keys can be compared at a glance. fingerprint
+// is the raw digest, e.g. the SHA256 sum of the marshaled public key.
+func Randomart(fingerprint []byte) string {
+	return randomart(fingerprint, "", "SHA256")
+}
+
+// randomart renders the digest with title centered in the top border (e.g.
+// "ED25519 256") and hashName in the bottom one.
+func randomart(digest []byte, title, hashName string) string {
+	var field [randomartWidth][randomartHeight]int
+	last := len(randomartSymbols) - 1
+
+	// The bishop starts in the centre and makes four moves per byte, two
+	// bits each: bit 0 picks left/right, bit 1 up/down. It stays inside the
+	// field by sliding along the walls.
+	x, y := randomartWidth/2, randomartHeight/2
+	for _, b := range digest {
+		for i := 0; i < 4; i++ {
+			if b&1 != 0 {
+				x++
+			} else {
+				x--
+			}
+			if b&2 != 0 {
+				y++
+			} else {
+				y--
+			}
+			x = min(max(x, 0), randomartWidth-1)
+			y = min(max(y, 0), randomartHeight-1)
+			if field[x][y] < last-2 {
+				field[x][y]++
+			}
+			b >>= 2
+		}
+	}
+	field[randomartWidth/2][randomartHeight/2] = last - 1
+	field[x][y] = last
+
+	var art strings.Builder
+	art.WriteString(randomartBorder(title))
+	art.WriteByte('\n')
+	for y := 0; y < randomartHeight; y++ {
+		art.WriteByte('|')
+		for x := 0; x < randomartWidth; x++ {
+			art.WriteByte(randomartSymbols[field[x][y]])
+		}
+		art.WriteString("|\n")
+	}
+	art.WriteString(randomartBorder(hashName))
+	return art.String()
+}
+
+// randomartBorder returns a border line with label centered in brackets,
+// or a plain line when label is empty or too long to fit.
+func randomartBorder(label string) string {
+	if label != "" {
+		label = "[" + label + "]"
+	}
+	if len(label) > randomartWidth {
+		label = ""
+	}
+	left := (randomartWidth - len(label)) / 2
+	right := randomartWidth - len(label) - left
+	return "+" + strings.Repeat("-", left) + label + strings.Repeat("-", right) + "+"
+}
+
+// publicKeyRandomart draws the SHA256 randomart of an SSH public key with
+// ssh-keygen's title, e.g. "[ED25519 256]".
+func publicKeyRandomart(publicKey ssh.PublicKey) string {
+	digest := sha256.Sum256(publicKey.Marshal())
+	name, bits := publicKeySize(publicKey)
+	title := name
+	if bits > 0 {
+		title = fmt.Sprintf("%s %d", name, bits)
+	}
+	return randomart(digest[:], title, "SHA256")
+}
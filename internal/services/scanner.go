@@ -0,0 +1,174 @@
+package services
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileScanner inspects file contents for malware. Scan reads r to the end
+// and returns a non-empty signature name when the contents are infected.
+type FileScanner interface {
+	Scan(r io.Reader) (signature string, err error)
+}
+
+const (
+	// clamdChunkSize is the size of each INSTREAM chunk sent to clamd, so a
+	// scan never holds more than one chunk of the file in memory.
+	clamdChunkSize = 64 * 1024
+
+	defaultClamdTimeout = 30 * time.Second
+)
+
+// ClamdScanner scans files with a ClamAV daemon using the INSTREAM command.
+type ClamdScanner struct {
+	Network string // "unix" or "tcp"
+	Address string
+	Timeout time.Duration
+}
+
+// NewClamdScanner parses a clamd address such as "unix:/run/clamav/clamd.ctl",
+// "/run/clamav/clamd.ctl", "tcp:127.0.0.1:3310" or "127.0.0.1:3310".
+func NewClamdScanner(address string) (*ClamdScanner, error) {
+	network := "tcp"
+	switch {
+	case strings.HasPrefix(address, "unix:"):
+		network, address = "unix", strings.TrimPrefix(address, "unix:")
+	case strings.HasPrefix(address, "tcp:"):
+		address = strings.TrimPrefix(address, "tcp:")
+	case strings.HasPrefix(address, "/"):
+		network = "unix"
+	}
+	if address == "" {
+		return nil, fmt.Errorf("empty clamd address")
+	}
+	return &ClamdScanner{Network: network, Address: address, Timeout: defaultClamdTimeout}, nil
+}
+
+// Scan streams r to clamd in chunks and reports the signature clamd found.
+func (c *ClamdScanner) Scan(r io.Reader) (string, error) {
+	conn, err := net.DialTimeout(c.Network, c.Address, c.Timeout)
+	if err != nil {
+		return "", fmt.Errorf("clamd unavailable: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(c.Timeout))
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return "", fmt.Errorf("clamd write failed: %v", err)
+	}
+
+	chunk := make([]byte, 4+clamdChunkSize)
+	defer WipeBytes(chunk)
+	for {
+		n, readErr := io.ReadFull(r, chunk[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(chunk[:4], uint32(n))
+			if _, err := conn.Write(chunk[:4+n]); err != nil {
+				// clamd closes the connection once StreamMaxLength is
+				// exceeded, its reply explains why
+				break
+			}
+		}
+		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+			conn.Write([]byte{0, 0, 0, 0})
+			break
+		}
+		if readErr != nil {
+			return "", readErr
+		}
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && reply == "" {
+		return "", fmt.Errorf("clamd read failed: %v", err)
+	}
+	return parseClamdReply(strings.TrimRight(reply, "\x00\n"))
+}
+
+// parseClamdReply interprets "stream: OK", "stream: <name> FOUND" and
+// "<message> ERROR" replies.
+func parseClamdReply(reply string) (string, error) {
+	reply = strings.TrimPrefix(reply, "stream: ")
+	switch {
+	case reply == "OK":
+		return "", nil
+	case strings.HasSuffix(reply, " FOUND"):
+		return strings.TrimSuffix(reply, " FOUND"), nil
+	case strings.HasSuffix(reply, " ERROR"):
+		return "", fmt.Errorf("clamd error: %s", strings.TrimSuffix(reply, " ERROR"))
+	default:
+		return "", fmt.Errorf("unexpected clamd reply: %q", reply)
+	}
+}
+
+var (
+	fileScanner     FileScanner
+	fileScannerMode string
+	fileScannerMu   sync.Mutex
+)
+
+// InitFileScanner configures optional malware scanning of uploaded and
+// downloaded files. FILE_SCAN_MODE selects when files are scanned: "upload",
+// "download", "both" or "off" (the default). CLAMD_ADDRESS points at the
+// ClamAV daemon (default unix:/run/clamav/clamd.ctl).
+func InitFileScanner() error {
+	mode := strings.ToLower(os.Getenv("FILE_SCAN_MODE"))
+	switch mode {
+	case "", "off":
+		SetFileScanner(nil, "off")
+		return nil
+	case "upload", "download", "both":
+	default:
+		return fmt.Errorf("unknown FILE_SCAN_MODE %q (expected off, upload, download or both)", mode)
+	}
+
+	address := os.Getenv("CLAMD_ADDRESS")
+	if address == "" {
+		address = "unix:/run/clamav/clamd.ctl"
+	}
+	scanner, err := NewClamdScanner(address)
+	if err != nil {
+		return err
+	}
+	log.Printf("Scanning files with clamd at %s (mode: %s)", address, mode)
+	SetFileScanner(scanner, mode)
+	return nil
+}
+
+// SetFileScanner installs scanner for the given mode ("upload", "download",
+// "both" or "off"), e.g. to plug in a scanner other than ClamAV.
+func SetFileScanner(scanner FileScanner, mode string) {
+	fileScannerMu.Lock()
+	defer fileScannerMu.Unlock()
+	fileScanner = scanner
+	fileScannerMode = mode
+}
+
+// UploadScanner returns the scanner to run on uploaded files, or nil when
+// uploads are not scanned.
+func UploadScanner() FileScanner {
+	return scannerFor("upload")
+}
+
+// DownloadScanner returns the scanner to run on decrypted files before they
+// are served, or nil when downloads are not scanned.
+func DownloadScanner() FileScanner {
+	return scannerFor("download")
+}
+
+func scannerFor(stage string) FileScanner {
+	fileScannerMu.Lock()
+	defer fileScannerMu.Unlock()
+	if fileScannerMode == stage || fileScannerMode == "both" {
+		return fileScanner
+	}
+	return nil
+}
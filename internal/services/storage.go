@@ -1,34 +1,150 @@
 package services
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/go-redis/redis/v8"
 	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/anazri/zeepass/internal/models"
 )
 
+// StorageBackend persists encrypted messages and files. Get methods return an
+// error when the record does not exist or has expired.
+//
+// ConsumeMessage fetches and deletes a message in one atomic step, for
+// secrets that may only be read once. It returns ErrNotFound if the message
+// is already gone.
+//
+// ClaimMessageView and ClaimFileView atomically record one view of a record
+// read with GetMessage or GetFile. They return the view count including this
+// view and whether the view was permitted, so that concurrent requests cannot
+// both consume the last view of a one-time secret. ReleaseFileView gives back
+// a view claimed for a download that did not complete.
+//
+// RecordFailedAttempt counts a wrong PIN or answer for a record ("message" or
+// "file") and returns the failures within the current lockout window and in
+// total. The window starts with the first failure and lasts lockout; the
+// total is kept as long as the record. FailedAttempts returns the failures in
+// the current window and ResetFailedAttempts clears both counts.
+type StorageBackend interface {
+	StoreMessage(id string, data *models.EncryptedData) error
+	GetMessage(id string) (*models.EncryptedData, error)
+	DeleteMessage(id string) error
+	ClaimMessageView(id string, data *models.EncryptedData) (int, bool, error)
+	ConsumeMessage(id string) (*models.EncryptedData, error)
+	StoreFile(id string, data *models.EncryptedFileData) error
+	GetFile(id string) (*models.EncryptedFileData, error)
+	DeleteFile(id string) error
+	ClaimFileView(id string, data *models.EncryptedFileData) (int, bool, error)
+	ReleaseFileView(id string) error
+	RecordFailedAttempt(kind, id string, lockout time.Duration, expiresAt *time.Time) (int, int, error)
+	FailedAttempts(kind, id string) (int, error)
+	ResetFailedAttempts(kind, id string) error
+}
+
 var (
 	rdb *redis.Client
 	ctx = context.Background()
+
+	storage      StorageBackend
+	storageMutex sync.Mutex
+
+	// encryptRecordsAtRest seals whole records (metadata included) with the
+	// server key before they are written to Redis, so Redis access alone does
+	// not reveal file names, PIN hashes or expiry details. It costs an extra
+	// AES-GCM pass per read and write. Enabled with ENCRYPT_RECORDS_AT_REST=true.
+	encryptRecordsAtRest bool
 )
 
-func InitRedis() {
-	rdb = redis.NewClient(&redis.Options{
-		Addr:     "localhost:6379",
-		Password: "lu7rodah8aefaiCi",
-		DB:       0,
-	})
+// sealedRecordPrefix marks records that were sealed at rest, so plain JSON
+// records written while the option was off remain readable.
+const sealedRecordPrefix = "zpsealed:"
+
+// redisFailoverOptionsFromEnv builds Sentinel options from
+// REDIS_SENTINEL_ADDRS (comma separated host:port list) and REDIS_MASTER_NAME.
+// It returns nil when Sentinel is not configured.
+func redisFailoverOptionsFromEnv() (*redis.FailoverOptions, error) {
+	addrsEnv := strings.TrimSpace(os.Getenv("REDIS_SENTINEL_ADDRS"))
+	masterName := strings.TrimSpace(os.Getenv("REDIS_MASTER_NAME"))
+	if addrsEnv == "" && masterName == "" {
+		return nil, nil
+	}
+	if addrsEnv == "" {
+		return nil, fmt.Errorf("REDIS_MASTER_NAME is set but REDIS_SENTINEL_ADDRS is empty")
+	}
+	if masterName == "" {
+		return nil, fmt.Errorf("REDIS_SENTINEL_ADDRS is set but REDIS_MASTER_NAME is empty")
+	}
+
+	var addrs []string
+	for _, addr := range strings.Split(addrsEnv, ",") {
+		addr = strings.TrimSpace(addr)
+		if addr == "" {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return nil, fmt.Errorf("invalid sentinel address %q: %v", addr, err)
+		}
+		addrs = append(addrs, addr)
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("REDIS_SENTINEL_ADDRS contains no addresses")
+	}
+
+	return &redis.FailoverOptions{
+		MasterName:       masterName,
+		SentinelAddrs:    addrs,
+		SentinelPassword: os.Getenv("REDIS_SENTINEL_PASSWORD"),
+		Password:         os.Getenv("REDIS_PASSWORD"),
+		DB:               0,
+	}, nil
+}
+
+// InitRedis connects to Redis, through Sentinel when it is configured. A
+// plain localhost connection that fails leaves rdb nil so storage falls back
+// to memory; a Sentinel setup that is invalid or unreachable is an error.
+func InitRedis() error {
+	encryptRecordsAtRest = os.Getenv("ENCRYPT_RECORDS_AT_REST") == "true"
+	if encryptRecordsAtRest {
+		log.Println("Encrypting stored records at rest")
+	}
+
+	failover, err := redisFailoverOptionsFromEnv()
+	if err != nil {
+		return fmt.Errorf("invalid Redis Sentinel configuration: %v", err)
+	}
+	if failover != nil {
+		log.Printf("Using Redis Sentinel for master %q via %s", failover.MasterName, strings.Join(failover.SentinelAddrs, ", "))
+		rdb = redis.NewFailoverClient(failover)
+	} else {
+		rdb = redis.NewClient(&redis.Options{
+			Addr:     "localhost:6379",
+			Password: os.Getenv("REDIS_PASSWORD"),
+			DB:       0,
+		})
+	}
 
 	pong, err := rdb.Ping(ctx).Result()
+	if err != nil && failover != nil {
+		// Sentinel was configured on purpose, so do not quietly run without it
+		rdb = nil
+		return fmt.Errorf("cannot reach Redis master %q through Sentinel: %v", failover.MasterName, err)
+	}
 	if err != nil {
-		log.Printf("Redis connection failed: %v. Falling back to in-memory storage.", err)
+		log.Printf("Redis connection failed: %v. Falling back to in-memory storage (secrets are lost on restart).", err)
 		log.Println("To use Redis: install Redis server and ensure it's running on localhost:6379")
 		rdb = nil
-		return
+		return nil
 	}
 	log.Printf("Connected to Redis: %s", pong)
 
@@ -38,6 +154,62 @@ func InitRedis() {
 		chatService.SetRedisClient(rdb)
 		log.Println("Redis client set for chat service")
 	}
+	return nil
+}
+
+// InitStorage selects the storage backend for secrets from STORAGE_BACKEND:
+// "redis" (the default, falling back to memory when Redis is unreachable),
+// "sqlite" (database file at SQLITE_PATH, default zeepass.db) or "memory".
+// It must run after InitRedis.
+func InitStorage() error {
+	switch backend := os.Getenv("STORAGE_BACKEND"); backend {
+	case "", "redis":
+		if rdb == nil {
+			SetStorage(NewMemoryBackend())
+			return nil
+		}
+		SetStorage(NewRedisBackend(rdb))
+	case "sqlite":
+		path := os.Getenv("SQLITE_PATH")
+		if path == "" {
+			path = "zeepass.db"
+		}
+		sqliteBackend, err := NewSQLiteBackend(path)
+		if err != nil {
+			return err
+		}
+		log.Printf("Storing secrets in SQLite database %s", path)
+		SetStorage(sqliteBackend)
+	case "memory":
+		log.Println("Storing secrets in memory (secrets are lost on restart)")
+		SetStorage(NewMemoryBackend())
+	default:
+		return fmt.Errorf("unknown STORAGE_BACKEND %q (expected redis, sqlite or memory)", backend)
+	}
+	return nil
+}
+
+// ConsumeEncryptedData atomically fetches and deletes a one-time message.
+func ConsumeEncryptedData(id string) (*models.EncryptedData, error) {
+	return GetStorage().ConsumeMessage(id)
+}
+
+// VerifyMessageStored reads a message back after it was stored and checks
+// that it is intact and decrypts with the server key. It catches backends
+// that accept a write without persisting it, at the cost of a second round
+// trip to storage.
+func VerifyMessageStored(id string) error {
+	data, err := GetStorage().GetMessage(id)
+	if err != nil {
+		return fmt.Errorf("read-back failed: %v", err)
+	}
+	if data.ID != id {
+		return fmt.Errorf("read-back returned message %q", data.ID)
+	}
+	if _, err := Decrypt(data.Content, GetEncryptionKey(), id); err != nil {
+		return fmt.Errorf("read-back could not be decrypted: %v", err)
+	}
+	return nil
 }
 
 // GetRedisClient returns the Redis client instance
@@ -45,160 +217,365 @@ func GetRedisClient() *redis.Client {
 	return rdb
 }
 
-func StoreEncryptedData(id string, data *models.EncryptedData) error {
-	if rdb == nil {
-		return fmt.Errorf("Redis not available")
+// GetStorage returns the storage backend selected at startup. If none has
+// been selected yet an in-memory backend is used.
+func GetStorage() StorageBackend {
+	storageMutex.Lock()
+	defer storageMutex.Unlock()
+	if storage == nil {
+		storage = NewMemoryBackend()
+	}
+	return storage
+}
+
+// SetStorage replaces the storage backend, e.g. to inject a fake in tests.
+func SetStorage(backend StorageBackend) {
+	storageMutex.Lock()
+	defer storageMutex.Unlock()
+	storage = backend
+}
+
+const defaultRedisKeyPrefix = "zeepass:"
+
+// RedisKeyPrefix returns the namespace for every Redis key the server writes,
+// set with REDIS_KEY_PREFIX so several instances can share one Redis.
+func RedisKeyPrefix() string {
+	if prefix := os.Getenv("REDIS_KEY_PREFIX"); prefix != "" {
+		return prefix
 	}
+	return defaultRedisKeyPrefix
+}
+
+func messageKey(id string) string {
+	return RedisKeyPrefix() + "message:" + id
+}
+
+func fileKey(id string) string {
+	return RedisKeyPrefix() + "file:" + id
+}
+
+func viewsKey(kind, id string) string {
+	return RedisKeyPrefix() + "views:" + kind + ":" + id
+}
+
+func attemptsKey(kind, id string) string {
+	return RedisKeyPrefix() + "attempts:" + kind + ":" + id
+}
+
+func totalAttemptsKey(kind, id string) string {
+	return RedisKeyPrefix() + "attempts-total:" + kind + ":" + id
+}
+
+// ErrNotFound is returned when a record does not exist, has expired or has
+// already been consumed.
+var ErrNotFound = fmt.Errorf("record not found")
+
+// recordStore is the key/value layer beneath the Redis and in-memory backends.
+type recordStore interface {
+	Set(key string, record []byte, ttl time.Duration) error
+	Get(key string) ([]byte, error)
+	Del(key string) error
+	// GetDel atomically returns and removes the value at key.
+	GetDel(key string) ([]byte, error)
+	// Incr atomically increments the counter at key and returns the new
+	// value. A missing counter is first initialised to seed with ttl.
+	Incr(key string, seed int, ttl time.Duration) (int, error)
+	// Decr decrements an existing, positive counter at key. A missing
+	// counter is left alone.
+	Decr(key string) error
+}
 
-	jsonData, err := json.Marshal(data)
+// recordBackend implements StorageBackend on top of a recordStore. Records are
+// stored as JSON, sealed first when encryptRecordsAtRest is set, and expire
+// with the secret they hold.
+type recordBackend struct {
+	store recordStore
+}
+
+func (b *recordBackend) StoreMessage(id string, data *models.EncryptedData) error {
+	return b.put(messageKey(id), "message:"+id, data, data.ExpiresAt)
+}
+
+func (b *recordBackend) GetMessage(id string) (*models.EncryptedData, error) {
+	var data models.EncryptedData
+	err := b.load(messageKey(id), "message:"+id, &data)
+	if err == ErrNotFound {
+		return nil, fmt.Errorf("message not found")
+	}
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return &data, nil
+}
 
-	var ttl time.Duration
-	if data.ExpiresAt != nil {
-		ttl = time.Until(*data.ExpiresAt)
-		if ttl <= 0 {
-			ttl = time.Minute
-		}
-	} else {
-		ttl = 24 * time.Hour
+func (b *recordBackend) DeleteMessage(id string) error {
+	b.store.Del(viewsKey("message", id))
+	b.ResetFailedAttempts("message", id)
+	return b.store.Del(messageKey(id))
+}
+
+func (b *recordBackend) ConsumeMessage(id string) (*models.EncryptedData, error) {
+	record, err := b.store.GetDel(messageKey(id))
+	if err != nil {
+		return nil, err
 	}
+	b.store.Del(viewsKey("message", id))
+	b.ResetFailedAttempts("message", id)
 
-	key := "zeepass:message:" + id
-	err = rdb.Set(ctx, key, jsonData, ttl).Err()
+	jsonData, err := openRecord("message:"+id, record)
 	if err != nil {
-		log.Printf("Redis SET failed for key %s: %v", key, err)
-		return err
+		return nil, err
 	}
-	log.Printf("Redis SET successful for key %s with TTL %v", key, ttl)
-	return nil
+	var data models.EncryptedData
+	if err := json.Unmarshal(jsonData, &data); err != nil {
+		return nil, err
+	}
+	return &data, nil
 }
 
-func GetEncryptedData(id string) (*models.EncryptedData, error) {
-	if rdb == nil {
-		return nil, fmt.Errorf("Redis not available")
-	}
+func (b *recordBackend) ClaimMessageView(id string, data *models.EncryptedData) (int, bool, error) {
+	return b.claimView(viewsKey("message", id), data.ViewCount, data.MaxViews, data.ExpiresAt)
+}
 
-	key := "zeepass:message:" + id
-	log.Printf("Redis GET attempt for key: %s", key)
-	jsonData, err := rdb.Get(ctx, key).Result()
+func (b *recordBackend) StoreFile(id string, data *models.EncryptedFileData) error {
+	return b.put(fileKey(id), "file:"+id, data, data.ExpiresAt)
+}
+
+func (b *recordBackend) GetFile(id string) (*models.EncryptedFileData, error) {
+	var data models.EncryptedFileData
+	err := b.load(fileKey(id), "file:"+id, &data)
+	if err == ErrNotFound {
+		return nil, fmt.Errorf("file not found")
+	}
 	if err != nil {
-		if err == redis.Nil {
-			log.Printf("Redis GET: key %s not found (redis.Nil)", key)
-			return nil, fmt.Errorf("message not found")
-		}
-		log.Printf("Redis GET error for key %s: %v", key, err)
 		return nil, err
 	}
-	log.Printf("Redis GET successful for key %s, data length: %d", key, len(jsonData))
+	return &data, nil
+}
 
-	var data models.EncryptedData
-	err = json.Unmarshal([]byte(jsonData), &data)
-	return &data, err
+func (b *recordBackend) DeleteFile(id string) error {
+	b.store.Del(viewsKey("file", id))
+	b.ResetFailedAttempts("file", id)
+	return b.store.Del(fileKey(id))
 }
 
-func DeleteEncryptedData(id string) error {
-	if rdb == nil {
-		return fmt.Errorf("Redis not available")
+func (b *recordBackend) ClaimFileView(id string, data *models.EncryptedFileData) (int, bool, error) {
+	return b.claimView(viewsKey("file", id), data.ViewCount, data.MaxViews, data.ExpiresAt)
+}
+
+func (b *recordBackend) ReleaseFileView(id string) error {
+	return b.store.Decr(viewsKey("file", id))
+}
+
+// claimView counts views in a counter kept next to the record, seeded with
+// the view count stored in the record, because sealed records cannot be
+// updated in place by the store.
+func (b *recordBackend) claimView(key string, viewCount, maxViews int, expiresAt *time.Time) (int, bool, error) {
+	views, err := b.store.Incr(key, viewCount, recordTTL(expiresAt))
+	if err != nil {
+		return 0, false, err
 	}
-	return rdb.Del(ctx, "zeepass:message:"+id).Err()
+	return views, views <= maxViews, nil
 }
 
-func IncrementViewCount(id string) error {
-	if rdb == nil {
-		return fmt.Errorf("Redis not available")
+func (b *recordBackend) RecordFailedAttempt(kind, id string, lockout time.Duration, expiresAt *time.Time) (int, int, error) {
+	recent, err := b.store.Incr(attemptsKey(kind, id), 0, lockout)
+	if err != nil {
+		return 0, 0, err
+	}
+	total, err := b.store.Incr(totalAttemptsKey(kind, id), 0, recordTTL(expiresAt))
+	if err != nil {
+		return 0, 0, err
 	}
+	return recent, total, nil
+}
 
-	data, err := GetEncryptedData(id)
+func (b *recordBackend) FailedAttempts(kind, id string) (int, error) {
+	value, err := b.store.Get(attemptsKey(kind, id))
+	if err == ErrNotFound {
+		return 0, nil
+	}
 	if err != nil {
-		return err
+		return 0, err
 	}
+	return strconv.Atoi(string(value))
+}
 
-	data.ViewCount++
+func (b *recordBackend) ResetFailedAttempts(kind, id string) error {
+	b.store.Del(totalAttemptsKey(kind, id))
+	return b.store.Del(attemptsKey(kind, id))
+}
 
-	if data.ViewCount >= data.MaxViews {
-		return DeleteEncryptedData(id)
+func (b *recordBackend) put(key, name string, v interface{}, expiresAt *time.Time) error {
+	jsonData, err := json.Marshal(v)
+	if err != nil {
+		return err
 	}
 
-	return StoreEncryptedData(id, data)
+	record, err := sealRecord(name, jsonData)
+	if err != nil {
+		return err
+	}
+
+	return b.store.Set(key, record, recordTTL(expiresAt))
 }
 
-func StoreEncryptedFileData(id string, data *models.EncryptedFileData) error {
-	if rdb == nil {
-		return fmt.Errorf("Redis not available")
+func (b *recordBackend) load(key, name string, v interface{}) error {
+	record, err := b.store.Get(key)
+	if err != nil {
+		return err
 	}
 
-	jsonData, err := json.Marshal(data)
+	jsonData, err := openRecord(name, record)
 	if err != nil {
 		return err
 	}
+	return json.Unmarshal(jsonData, v)
+}
 
-	var ttl time.Duration
-	if data.ExpiresAt != nil {
-		ttl = time.Until(*data.ExpiresAt)
-		if ttl <= 0 {
-			ttl = time.Minute
-		}
-	} else {
-		ttl = 24 * time.Hour
+// recordTTL is how long a record is kept: until it expires, or 24 hours for
+// secrets without an expiry.
+func recordTTL(expiresAt *time.Time) time.Duration {
+	if expiresAt == nil {
+		return 24 * time.Hour
+	}
+	ttl := time.Until(*expiresAt)
+	if ttl <= 0 {
+		ttl = time.Minute
 	}
+	return ttl
+}
+
+// RedisBackend stores records in Redis with native key expiry.
+type RedisBackend struct {
+	recordBackend
+}
+
+// NewRedisBackend returns a StorageBackend that uses client.
+func NewRedisBackend(client *redis.Client) *RedisBackend {
+	return &RedisBackend{recordBackend{store: &redisRecordStore{client: client}}}
+}
+
+type redisRecordStore struct {
+	client            *redis.Client
+	getDelUnsupported atomic.Bool
+}
 
-	key := "zeepass:file:" + id
-	err = rdb.Set(ctx, key, jsonData, ttl).Err()
+func (s *redisRecordStore) Set(key string, record []byte, ttl time.Duration) error {
+	err := s.client.Set(ctx, key, record, ttl).Err()
 	if err != nil {
-		log.Printf("Redis SET failed for file key %s: %v", key, err)
+		log.Printf("Redis SET failed for key %s: %v", key, err)
 		return err
 	}
-	log.Printf("Redis SET successful for file key %s with TTL %v", key, ttl)
+	log.Printf("Redis SET successful for key %s with TTL %v", key, ttl)
 	return nil
 }
 
-func GetEncryptedFileData(id string) (*models.EncryptedFileData, error) {
-	if rdb == nil {
-		return nil, fmt.Errorf("Redis not available")
-	}
-
-	key := "zeepass:file:" + id
-	log.Printf("Redis GET attempt for file key: %s", key)
-	jsonData, err := rdb.Get(ctx, key).Result()
+func (s *redisRecordStore) Get(key string) ([]byte, error) {
+	log.Printf("Redis GET attempt for key: %s", key)
+	record, err := s.client.Get(ctx, key).Bytes()
 	if err != nil {
 		if err == redis.Nil {
-			log.Printf("Redis GET: file key %s not found (redis.Nil)", key)
-			return nil, fmt.Errorf("file not found")
+			log.Printf("Redis GET: key %s not found (redis.Nil)", key)
+			return nil, ErrNotFound
 		}
-		log.Printf("Redis GET error for file key %s: %v", key, err)
+		log.Printf("Redis GET error for key %s: %v", key, err)
 		return nil, err
 	}
-	log.Printf("Redis GET successful for file key %s, data length: %d", key, len(jsonData))
+	log.Printf("Redis GET successful for key %s, data length: %d", key, len(record))
+	return record, nil
+}
 
-	var data models.EncryptedFileData
-	err = json.Unmarshal([]byte(jsonData), &data)
-	return &data, err
+func (s *redisRecordStore) Del(key string) error {
+	return s.client.Del(ctx, key).Err()
 }
 
-func DeleteEncryptedFileData(id string) error {
-	if rdb == nil {
-		return fmt.Errorf("Redis not available")
+// GetDel uses GETDEL (Redis 6.2+) so a one-time record is read and removed in
+// a single round trip. On older servers, which reject the command, it falls
+// back to GET and DEL in a MULTI transaction.
+func (s *redisRecordStore) GetDel(key string) ([]byte, error) {
+	if !s.getDelUnsupported.Load() {
+		record, err := s.client.GetDel(ctx, key).Bytes()
+		if err == redis.Nil {
+			return nil, ErrNotFound
+		}
+		if err == nil || !isUnknownCommand(err) {
+			return record, err
+		}
+		log.Printf("Redis server does not support GETDEL, falling back to GET+DEL: %v", err)
+		s.getDelUnsupported.Store(true)
+	}
+
+	var get *redis.StringCmd
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		get = pipe.Get(ctx, key)
+		pipe.Del(ctx, key)
+		return nil
+	})
+	if err == redis.Nil {
+		return nil, ErrNotFound
 	}
-	return rdb.Del(ctx, "zeepass:file:"+id).Err()
+	if err != nil {
+		return nil, err
+	}
+	return get.Bytes()
+}
+
+func isUnknownCommand(err error) bool {
+	return strings.HasPrefix(err.Error(), "ERR unknown command")
 }
 
-func IncrementFileViewCount(id string) error {
-	if rdb == nil {
-		return fmt.Errorf("Redis not available")
+// incrWithSeedScript seeds a missing counter (KEYS[1]) with ARGV[1] and a TTL
+// of ARGV[2] milliseconds, then increments it, in one atomic step.
+var incrWithSeedScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+end
+return redis.call('INCR', KEYS[1])
+`)
+
+// decrIfPositiveScript decrements the counter at KEYS[1] if it exists and is
+// above zero, keeping its TTL.
+var decrIfPositiveScript = redis.NewScript(`
+local value = tonumber(redis.call('GET', KEYS[1]))
+if value and value > 0 then
+	return redis.call('DECR', KEYS[1])
+end
+return 0
+`)
+
+func (s *redisRecordStore) Decr(key string) error {
+	return decrIfPositiveScript.Run(ctx, s.client, []string{key}).Err()
+}
+
+func (s *redisRecordStore) Incr(key string, seed int, ttl time.Duration) (int, error) {
+	return incrWithSeedScript.Run(ctx, s.client, []string{key}, seed, ttl.Milliseconds()).Int()
+}
+
+// sealRecord encrypts a serialized record when at-rest encryption is enabled.
+// The record name is bound as associated data so sealed records cannot be
+// swapped between keys.
+func sealRecord(name string, record []byte) ([]byte, error) {
+	if !encryptRecordsAtRest {
+		return record, nil
 	}
 
-	data, err := GetEncryptedFileData(id)
+	sealed, err := EncryptFile(record, GetEncryptionKey(), name)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("failed to seal record: %v", err)
 	}
+	return append([]byte(sealedRecordPrefix), sealed...), nil
+}
 
-	data.ViewCount++
-
-	if data.ViewCount >= data.MaxViews {
-		return DeleteEncryptedFileData(id)
+// openRecord reverses sealRecord. Unsealed records are returned unchanged.
+func openRecord(name string, record []byte) ([]byte, error) {
+	if !bytes.HasPrefix(record, []byte(sealedRecordPrefix)) {
+		return record, nil
 	}
 
-	return StoreEncryptedFileData(id, data)
+	opened, err := DecryptFile(record[len(sealedRecordPrefix):], GetEncryptionKey(), name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sealed record: %v", err)
+	}
+	return opened, nil
 }
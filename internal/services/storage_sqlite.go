@@ -0,0 +1,300 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/anazri/zeepass/internal/models"
+	_ "modernc.org/sqlite"
+)
+
+// sqliteSweepInterval is how often expired rows are deleted.
+const sqliteSweepInterval = time.Minute
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS records (
+	kind       TEXT    NOT NULL,
+	id         TEXT    NOT NULL,
+	data       BLOB    NOT NULL,
+	view_count INTEGER NOT NULL DEFAULT 0,
+	max_views  INTEGER NOT NULL,
+	expires_at INTEGER NOT NULL,
+	PRIMARY KEY (kind, id)
+);
+CREATE INDEX IF NOT EXISTS records_expires_at ON records (expires_at);
+CREATE TABLE IF NOT EXISTS attempts (
+	kind       TEXT    NOT NULL,
+	id         TEXT    NOT NULL,
+	recent     INTEGER NOT NULL,
+	lockout_at INTEGER NOT NULL,
+	total      INTEGER NOT NULL,
+	expires_at INTEGER NOT NULL,
+	PRIMARY KEY (kind, id)
+);
+`
+
+// SQLiteBackend stores records in a single SQLite database file, for
+// single-node deployments that do not want to run Redis. Records are kept as
+// JSON (sealed when encryptRecordsAtRest is set) alongside view_count,
+// max_views and expires_at columns. A background job deletes expired rows.
+type SQLiteBackend struct {
+	db *sql.DB
+}
+
+// NewSQLiteBackend opens (or creates) the database at path and starts the
+// expiry sweeper.
+func NewSQLiteBackend(path string) (*SQLiteBackend, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SQLite database: %v", err)
+	}
+	// SQLite allows a single writer; serializing access avoids SQLITE_BUSY
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create SQLite schema: %v", err)
+	}
+
+	backend := &SQLiteBackend{db: db}
+	go backend.sweep(sqliteSweepInterval)
+	return backend, nil
+}
+
+func (b *SQLiteBackend) StoreMessage(id string, data *models.EncryptedData) error {
+	return b.put("message", id, data, data.ViewCount, data.MaxViews, data.ExpiresAt)
+}
+
+func (b *SQLiteBackend) GetMessage(id string) (*models.EncryptedData, error) {
+	var data models.EncryptedData
+	err := b.load("message", id, &data)
+	if err == ErrNotFound {
+		return nil, fmt.Errorf("message not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+func (b *SQLiteBackend) DeleteMessage(id string) error {
+	return b.delete("message", id)
+}
+
+func (b *SQLiteBackend) ClaimMessageView(id string, data *models.EncryptedData) (int, bool, error) {
+	return b.claimView("message", id)
+}
+
+func (b *SQLiteBackend) ConsumeMessage(id string) (*models.EncryptedData, error) {
+	var record []byte
+	err := b.db.QueryRow(`DELETE FROM records WHERE kind = 'message' AND id = ? AND expires_at > ? RETURNING data`,
+		id, time.Now().Unix()).Scan(&record)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	b.ResetFailedAttempts("message", id)
+
+	jsonData, err := openRecord("message:"+id, record)
+	if err != nil {
+		return nil, err
+	}
+	var data models.EncryptedData
+	if err := json.Unmarshal(jsonData, &data); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+func (b *SQLiteBackend) StoreFile(id string, data *models.EncryptedFileData) error {
+	return b.put("file", id, data, data.ViewCount, data.MaxViews, data.ExpiresAt)
+}
+
+func (b *SQLiteBackend) GetFile(id string) (*models.EncryptedFileData, error) {
+	var data models.EncryptedFileData
+	err := b.load("file", id, &data)
+	if err == ErrNotFound {
+		return nil, fmt.Errorf("file not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+func (b *SQLiteBackend) DeleteFile(id string) error {
+	return b.delete("file", id)
+}
+
+func (b *SQLiteBackend) ClaimFileView(id string, data *models.EncryptedFileData) (int, bool, error) {
+	return b.claimView("file", id)
+}
+
+func (b *SQLiteBackend) ReleaseFileView(id string) error {
+	_, err := b.db.Exec(`UPDATE records SET view_count = view_count - 1
+		WHERE kind = 'file' AND id = ? AND view_count > 0`, id)
+	return err
+}
+
+// claimView increments view_count only while it is below max_views, so the
+// database serializes concurrent views of the same record.
+func (b *SQLiteBackend) claimView(kind, id string) (int, bool, error) {
+	tx, err := b.db.Begin()
+	if err != nil {
+		return 0, false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`UPDATE records SET view_count = view_count + 1
+		WHERE kind = ? AND id = ? AND view_count < max_views AND expires_at > ?`,
+		kind, id, time.Now().Unix())
+	if err != nil {
+		return 0, false, err
+	}
+	claimed, err := result.RowsAffected()
+	if err != nil {
+		return 0, false, err
+	}
+
+	var views int
+	err = tx.QueryRow(`SELECT view_count FROM records WHERE kind = ? AND id = ?`, kind, id).Scan(&views)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return views, claimed == 1, tx.Commit()
+}
+
+// put writes a record in a transaction. Rewriting a record with a view count
+// that reaches max_views deletes it instead, so a single-view secret is
+// burned atomically with the view that consumed it. A stale write carrying a
+// lower view count than the stored one is ignored.
+func (b *SQLiteBackend) put(kind, id string, v interface{}, viewCount, maxViews int, expiresAt *time.Time) error {
+	jsonData, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	record, err := sealRecord(kind+":"+id, jsonData)
+	if err != nil {
+		return err
+	}
+
+	tx, err := b.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if viewCount > 0 && viewCount >= maxViews {
+		if _, err := tx.Exec(`DELETE FROM records WHERE kind = ? AND id = ?`, kind, id); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO records (kind, id, data, view_count, max_views, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (kind, id) DO UPDATE SET
+			data = excluded.data,
+			view_count = excluded.view_count,
+			max_views = excluded.max_views,
+			expires_at = excluded.expires_at
+		WHERE excluded.view_count >= records.view_count`,
+		kind, id, record, viewCount, maxViews, time.Now().Add(recordTTL(expiresAt)).Unix())
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (b *SQLiteBackend) load(kind, id string, v interface{}) error {
+	var record []byte
+	err := b.db.QueryRow(`SELECT data FROM records WHERE kind = ? AND id = ? AND expires_at > ?`,
+		kind, id, time.Now().Unix()).Scan(&record)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	jsonData, err := openRecord(kind+":"+id, record)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(jsonData, v)
+}
+
+func (b *SQLiteBackend) delete(kind, id string) error {
+	b.ResetFailedAttempts(kind, id)
+	_, err := b.db.Exec(`DELETE FROM records WHERE kind = ? AND id = ?`, kind, id)
+	return err
+}
+
+// RecordFailedAttempt keeps both counts in one attempts row. lockout_at is
+// when the current window ends; a failure after it starts a new window.
+func (b *SQLiteBackend) RecordFailedAttempt(kind, id string, lockout time.Duration, expiresAt *time.Time) (int, int, error) {
+	now := time.Now()
+	var recent, total int
+	err := b.db.QueryRow(`
+		INSERT INTO attempts (kind, id, recent, lockout_at, total, expires_at)
+		VALUES (?, ?, 1, ?, 1, ?)
+		ON CONFLICT (kind, id) DO UPDATE SET
+			recent = CASE WHEN attempts.lockout_at > ? THEN attempts.recent + 1 ELSE 1 END,
+			lockout_at = CASE WHEN attempts.lockout_at > ? THEN attempts.lockout_at ELSE excluded.lockout_at END,
+			total = CASE WHEN attempts.expires_at > ? THEN attempts.total + 1 ELSE 1 END,
+			expires_at = excluded.expires_at
+		RETURNING recent, total`,
+		kind, id, now.Add(lockout).Unix(), now.Add(recordTTL(expiresAt)).Unix(),
+		now.Unix(), now.Unix(), now.Unix()).Scan(&recent, &total)
+	if err != nil {
+		return 0, 0, err
+	}
+	return recent, total, nil
+}
+
+func (b *SQLiteBackend) FailedAttempts(kind, id string) (int, error) {
+	var recent int
+	err := b.db.QueryRow(`SELECT recent FROM attempts WHERE kind = ? AND id = ? AND lockout_at > ?`,
+		kind, id, time.Now().Unix()).Scan(&recent)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return recent, err
+}
+
+func (b *SQLiteBackend) ResetFailedAttempts(kind, id string) error {
+	_, err := b.db.Exec(`DELETE FROM attempts WHERE kind = ? AND id = ?`, kind, id)
+	return err
+}
+
+// removeExpired deletes every row whose expiry has passed.
+func (b *SQLiteBackend) removeExpired() {
+	result, err := b.db.Exec(`DELETE FROM records WHERE expires_at <= ?`, time.Now().Unix())
+	if err != nil {
+		log.Printf("SQLite expiry sweep failed: %v", err)
+		return
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		log.Printf("SQLite expiry sweep removed %d records", n)
+	}
+	if _, err := b.db.Exec(`DELETE FROM attempts WHERE expires_at <= ?`, time.Now().Unix()); err != nil {
+		log.Printf("SQLite expiry sweep failed: %v", err)
+	}
+}
+
+func (b *SQLiteBackend) sweep(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		b.removeExpired()
+	}
+}
@@ -0,0 +1,354 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/anazri/zeepass/internal/models"
+	"github.com/go-redis/redis/v8"
+)
+
+// newTestRedis starts an in-process miniredis server and returns it with a
+// client connected to it. Both are shut down with the test.
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return server, client
+}
+
+// newTestRedisStore returns a redisRecordStore backed by miniredis.
+func newTestRedisStore(t *testing.T) *redisRecordStore {
+	t.Helper()
+	_, client := newTestRedis(t)
+	return &redisRecordStore{client: client}
+}
+
+// testRecordStores returns every recordStore implementation, including Redis
+// with the GET+DEL fallback used for servers without GETDEL.
+func testRecordStores(t *testing.T) map[string]recordStore {
+	t.Helper()
+	fallback := newTestRedisStore(t)
+	fallback.getDelUnsupported.Store(true)
+	return map[string]recordStore{
+		"memory":        newMemoryStore(),
+		"redis":         newTestRedisStore(t),
+		"redis GET+DEL": fallback,
+	}
+}
+
+func TestInitRedisRejectsIncompleteSentinelConfig(t *testing.T) {
+	t.Setenv("REDIS_SENTINEL_ADDRS", "")
+	t.Setenv("REDIS_MASTER_NAME", "mymaster")
+	if err := InitRedis(); err == nil {
+		t.Fatal("InitRedis accepted a master name without sentinel addresses")
+	}
+}
+
+func TestRedisFailoverOptionsReadPasswordFromEnv(t *testing.T) {
+	t.Setenv("REDIS_SENTINEL_ADDRS", "sentinel-1:26379, sentinel-2:26379")
+	t.Setenv("REDIS_MASTER_NAME", "mymaster")
+	t.Setenv("REDIS_PASSWORD", "from-env")
+	opts, err := redisFailoverOptionsFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.Password != "from-env" {
+		t.Errorf("Password = %q, want the REDIS_PASSWORD value", opts.Password)
+	}
+	if len(opts.SentinelAddrs) != 2 {
+		t.Errorf("SentinelAddrs = %v", opts.SentinelAddrs)
+	}
+
+	t.Setenv("REDIS_PASSWORD", "")
+	if opts, _ = redisFailoverOptionsFromEnv(); opts.Password != "" {
+		t.Errorf("Password = %q without REDIS_PASSWORD", opts.Password)
+	}
+}
+
+// testBackend is a StorageBackend under test. elapse lets d pass on the clock
+// the backend expires records by.
+type testBackend struct {
+	StorageBackend
+	elapse func(d time.Duration)
+}
+
+// testStorageBackends returns a fresh instance of every StorageBackend.
+func testStorageBackends(t *testing.T) map[string]testBackend {
+	t.Helper()
+	server, client := newTestRedis(t)
+	sqlite, err := NewSQLiteBackend(filepath.Join(t.TempDir(), "zeepass.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlite.db.Close() })
+	return map[string]testBackend{
+		"memory": {NewMemoryBackend(), time.Sleep},
+		"redis":  {NewRedisBackend(client), server.FastForward},
+		"sqlite": {sqlite, time.Sleep},
+	}
+}
+
+func TestRecordStoreGetDelConsumesOnce(t *testing.T) {
+	for name, store := range testRecordStores(t) {
+		t.Run(name, func(t *testing.T) {
+			if err := store.Set("key", []byte("record"), time.Minute); err != nil {
+				t.Fatal(err)
+			}
+			record, err := store.GetDel("key")
+			if err != nil || string(record) != "record" {
+				t.Fatalf("first GetDel = %q, %v", record, err)
+			}
+			if _, err := store.GetDel("key"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("second GetDel error = %v, want ErrNotFound", err)
+			}
+			if _, err := store.Get("key"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Get after GetDel error = %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func TestRecordStoreGetDelHasOneWinner(t *testing.T) {
+	for name, store := range testRecordStores(t) {
+		t.Run(name, func(t *testing.T) {
+			for round := 0; round < 20; round++ {
+				key := fmt.Sprintf("key-%d", round)
+				if err := store.Set(key, []byte("record"), time.Minute); err != nil {
+					t.Fatal(err)
+				}
+				var winners atomic.Int32
+				var wg sync.WaitGroup
+				for i := 0; i < 10; i++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						record, err := store.GetDel(key)
+						if err == nil && string(record) == "record" {
+							winners.Add(1)
+						} else if !errors.Is(err, ErrNotFound) {
+							t.Errorf("GetDel error = %v", err)
+						}
+					}()
+				}
+				wg.Wait()
+				if n := winners.Load(); n != 1 {
+					t.Fatalf("round %d: %d consumers read the record, want 1", round, n)
+				}
+			}
+		})
+	}
+}
+
+func TestClaimViewAllowsExactlyMaxViews(t *testing.T) {
+	const maxViews, claimers = 3, 20
+	for name, backend := range testStorageBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			expiresAt := time.Now().Add(time.Hour)
+			message := &models.EncryptedData{ID: "claim-message", Content: "ciphertext", MaxViews: maxViews, ExpiresAt: &expiresAt}
+			file := &models.EncryptedFileData{ID: "claim-file", Content: []byte("ciphertext"), MaxViews: maxViews, ExpiresAt: &expiresAt}
+			if err := backend.StoreMessage(message.ID, message); err != nil {
+				t.Fatal(err)
+			}
+			if err := backend.StoreFile(file.ID, file); err != nil {
+				t.Fatal(err)
+			}
+
+			claims := map[string]func() (int, bool, error){
+				"message": func() (int, bool, error) { return backend.ClaimMessageView(message.ID, message) },
+				"file":    func() (int, bool, error) { return backend.ClaimFileView(file.ID, file) },
+			}
+			for kind, claim := range claims {
+				var allowed atomic.Int32
+				var wg sync.WaitGroup
+				for i := 0; i < claimers; i++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						views, ok, err := claim()
+						if err != nil {
+							t.Errorf("%s claim: %v", kind, err)
+							return
+						}
+						if ok {
+							if views < 1 || views > maxViews {
+								t.Errorf("%s claim allowed at view %d", kind, views)
+							}
+							allowed.Add(1)
+						}
+					}()
+				}
+				wg.Wait()
+				if n := allowed.Load(); n != maxViews {
+					t.Errorf("%s: %d of %d concurrent claims allowed, want %d", kind, n, claimers, maxViews)
+				}
+			}
+		})
+	}
+}
+
+// storageConformanceCases are run against every StorageBackend. Each case uses
+// its own ids, so they can share a backend.
+var storageConformanceCases = []struct {
+	name string
+	run  func(t *testing.T, b testBackend)
+}{
+	{"store and get", func(t *testing.T, b testBackend) {
+		expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+		message := &models.EncryptedData{ID: "get-message", Content: "ciphertext", PIN: "pin-hash", MaxViews: 2, ExpiresAt: &expiresAt}
+		if err := b.StoreMessage(message.ID, message); err != nil {
+			t.Fatal(err)
+		}
+		gotMessage, err := b.GetMessage(message.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if gotMessage.Content != message.Content || gotMessage.PIN != message.PIN || gotMessage.MaxViews != message.MaxViews ||
+			gotMessage.ExpiresAt == nil || !gotMessage.ExpiresAt.Equal(expiresAt) {
+			t.Errorf("GetMessage = %+v, want %+v", gotMessage, message)
+		}
+
+		file := &models.EncryptedFileData{ID: "get-file", Content: []byte{0, 1, 2}, FileName: "a.txt", MaxViews: 1, ExpiresAt: &expiresAt}
+		if err := b.StoreFile(file.ID, file); err != nil {
+			t.Fatal(err)
+		}
+		gotFile, err := b.GetFile(file.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(gotFile.Content) != string(file.Content) || gotFile.FileName != file.FileName || gotFile.MaxViews != file.MaxViews {
+			t.Errorf("GetFile = %+v, want %+v", gotFile, file)
+		}
+
+		if _, err := b.GetMessage("missing"); err == nil {
+			t.Error("GetMessage found a missing message")
+		}
+		if _, err := b.GetFile("missing"); err == nil {
+			t.Error("GetFile found a missing file")
+		}
+	}},
+	{"claim", func(t *testing.T, b testBackend) {
+		expiresAt := time.Now().Add(time.Hour)
+		message := &models.EncryptedData{ID: "claim-message", Content: "ciphertext", MaxViews: 2, ExpiresAt: &expiresAt}
+		if err := b.StoreMessage(message.ID, message); err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range []struct {
+			views int
+			ok    bool
+		}{{1, true}, {2, true}, {3, false}} {
+			views, ok, err := b.ClaimMessageView(message.ID, message)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != want.ok || ok && views != want.views {
+				t.Errorf("ClaimMessageView = %d, %v, want %d, %v", views, ok, want.views, want.ok)
+			}
+		}
+	}},
+	{"release", func(t *testing.T, b testBackend) {
+		expiresAt := time.Now().Add(time.Hour)
+		file := &models.EncryptedFileData{ID: "release-file", Content: []byte("ciphertext"), MaxViews: 1, ExpiresAt: &expiresAt}
+		if err := b.StoreFile(file.ID, file); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok, err := b.ClaimFileView(file.ID, file); err != nil || !ok {
+			t.Fatalf("first claim = %v, %v", ok, err)
+		}
+		if err := b.ReleaseFileView(file.ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok, err := b.ClaimFileView(file.ID, file); err != nil || !ok {
+			t.Fatalf("claim after release = %v, %v", ok, err)
+		}
+		if _, ok, err := b.ClaimFileView(file.ID, file); err != nil || ok {
+			t.Errorf("claim beyond MaxViews = %v, %v", ok, err)
+		}
+	}},
+	{"consume", func(t *testing.T, b testBackend) {
+		expiresAt := time.Now().Add(time.Hour)
+		message := &models.EncryptedData{ID: "consume-message", Content: "ciphertext", MaxViews: 1, ExpiresAt: &expiresAt}
+		if err := b.StoreMessage(message.ID, message); err != nil {
+			t.Fatal(err)
+		}
+		got, err := b.ConsumeMessage(message.ID)
+		if err != nil || got.Content != message.Content {
+			t.Fatalf("ConsumeMessage = %+v, %v", got, err)
+		}
+		if _, err := b.ConsumeMessage(message.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("second ConsumeMessage error = %v, want ErrNotFound", err)
+		}
+		if _, err := b.GetMessage(message.ID); err == nil {
+			t.Error("consumed message is still readable")
+		}
+	}},
+	{"delete", func(t *testing.T, b testBackend) {
+		expiresAt := time.Now().Add(time.Hour)
+		message := &models.EncryptedData{ID: "delete-message", Content: "ciphertext", MaxViews: 1, ExpiresAt: &expiresAt}
+		file := &models.EncryptedFileData{ID: "delete-file", Content: []byte("ciphertext"), MaxViews: 1, ExpiresAt: &expiresAt}
+		if err := b.StoreMessage(message.ID, message); err != nil {
+			t.Fatal(err)
+		}
+		if err := b.StoreFile(file.ID, file); err != nil {
+			t.Fatal(err)
+		}
+		if err := b.DeleteMessage(message.ID); err != nil {
+			t.Fatal(err)
+		}
+		if err := b.DeleteFile(file.ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := b.GetMessage(message.ID); err == nil {
+			t.Error("deleted message is still readable")
+		}
+		if _, err := b.ConsumeMessage(message.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("ConsumeMessage of a deleted message error = %v, want ErrNotFound", err)
+		}
+		if _, err := b.GetFile(file.ID); err == nil {
+			t.Error("deleted file is still readable")
+		}
+	}},
+	{"expiry", func(t *testing.T, b testBackend) {
+		expiresAt := time.Now().Add(time.Second)
+		message := &models.EncryptedData{ID: "expiry-message", Content: "ciphertext", MaxViews: 1, ExpiresAt: &expiresAt}
+		file := &models.EncryptedFileData{ID: "expiry-file", Content: []byte("ciphertext"), MaxViews: 1, ExpiresAt: &expiresAt}
+		if err := b.StoreMessage(message.ID, message); err != nil {
+			t.Fatal(err)
+		}
+		if err := b.StoreFile(file.ID, file); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := b.GetMessage(message.ID); err != nil {
+			t.Fatalf("message expired early: %v", err)
+		}
+
+		b.elapse(2 * time.Second)
+		if _, err := b.GetMessage(message.ID); err == nil {
+			t.Error("expired message is still readable")
+		}
+		if _, err := b.ConsumeMessage(message.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("ConsumeMessage of an expired message error = %v, want ErrNotFound", err)
+		}
+		if _, err := b.GetFile(file.ID); err == nil {
+			t.Error("expired file is still readable")
+		}
+	}},
+}
+
+func TestStorageBackendConformance(t *testing.T) {
+	for name, backend := range testStorageBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			for _, tc := range storageConformanceCases {
+				t.Run(tc.name, func(t *testing.T) { tc.run(t, backend) })
+			}
+		})
+	}
+}
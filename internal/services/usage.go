@@ -0,0 +1,150 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// UsageTools are the tools whose use is counted by RecordToolUsage.
+var UsageTools = []string{"text", "file", "chat", "password", "ssh", "base64"}
+
+const (
+	// Hourly buckets are kept for a week and a day, daily buckets for a
+	// little over a year.
+	usageHourlyRetention = 8 * 24 * time.Hour
+	usageDailyRetention  = 400 * 24 * time.Hour
+)
+
+// UsageBucket holds the number of uses of each tool in one hour or day.
+type UsageBucket struct {
+	Start  time.Time      `json:"start"`
+	Counts map[string]int `json:"counts"`
+}
+
+var (
+	usageStore     recordStore
+	usageStoreOnce sync.Once
+)
+
+// UsageStatsEnabled reports whether tool usage is counted, enabled with
+// USAGE_STATS=true.
+func UsageStatsEnabled() bool {
+	return os.Getenv("USAGE_STATS") == "true"
+}
+
+// getUsageStore keeps the counters in Redis when it is available so every
+// instance adds to the same buckets, and in memory otherwise.
+func getUsageStore() recordStore {
+	usageStoreOnce.Do(func() {
+		if rdb != nil {
+			usageStore = &redisRecordStore{client: rdb}
+			return
+		}
+		store := newMemoryStore()
+		go store.sweep(memoryStoreSweepInterval)
+		usageStore = store
+	})
+	return usageStore
+}
+
+func usageKey(window, tool string, start time.Time) string {
+	format := "2006010215"
+	if window == "day" {
+		format = "20060102"
+	}
+	return RedisKeyPrefix() + "usage:" + window + ":" + tool + ":" + start.UTC().Format(format)
+}
+
+// RecordToolUsage counts one use of tool in the current hourly and daily
+// buckets. Only the count is stored, nothing about the request or its
+// content. It does nothing unless usage stats are enabled.
+func RecordToolUsage(tool string) {
+	if !UsageStatsEnabled() {
+		return
+	}
+	store := getUsageStore()
+	now := time.Now()
+	if _, err := store.Incr(usageKey("hour", tool, now), 0, usageHourlyRetention); err != nil {
+		log.Printf("Error recording %s usage: %v", tool, err)
+		return
+	}
+	if _, err := store.Incr(usageKey("day", tool, now), 0, usageDailyRetention); err != nil {
+		log.Printf("Error recording %s usage: %v", tool, err)
+	}
+}
+
+// ToolUsage returns the per-tool counts of the last n hourly or daily
+// buckets up to and including the one containing now, oldest first.
+func ToolUsage(window string, n int, now time.Time) ([]UsageBucket, error) {
+	var step time.Duration
+	var current time.Time
+	now = now.UTC()
+	switch window {
+	case "hour":
+		step = time.Hour
+		current = now.Truncate(time.Hour)
+	case "day":
+		step = 24 * time.Hour
+		current = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	default:
+		return nil, fmt.Errorf("unknown window %q (expected hour or day)", window)
+	}
+
+	starts := make([]time.Time, n)
+	keys := make([]string, 0, n*len(UsageTools))
+	for i := range starts {
+		starts[i] = current.Add(-time.Duration(n-1-i) * step)
+		for _, tool := range UsageTools {
+			keys = append(keys, usageKey(window, tool, starts[i]))
+		}
+	}
+	counts, err := loadUsageCounters(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	buckets := make([]UsageBucket, n)
+	for i, start := range starts {
+		bucket := UsageBucket{Start: start, Counts: make(map[string]int, len(UsageTools))}
+		for j, tool := range UsageTools {
+			bucket.Counts[tool] = counts[i*len(UsageTools)+j]
+		}
+		buckets[i] = bucket
+	}
+	return buckets, nil
+}
+
+// loadUsageCounters reads many counters at once, with a single MGET when
+// they are kept in Redis. Missing counters read as zero.
+func loadUsageCounters(keys []string) ([]int, error) {
+	counts := make([]int, len(keys))
+	store := getUsageStore()
+	if redisStore, ok := store.(*redisRecordStore); ok {
+		values, err := redisStore.client.MGet(ctx, keys...).Result()
+		if err != nil {
+			return nil, err
+		}
+		for i, value := range values {
+			if s, ok := value.(string); ok {
+				counts[i], _ = strconv.Atoi(s)
+			}
+		}
+		return counts, nil
+	}
+
+	for i, key := range keys {
+		value, err := store.Get(key)
+		if err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		counts[i], _ = strconv.Atoi(string(value))
+	}
+	return counts, nil
+}
@@ -0,0 +1,46 @@
+package services
+
+import (
+	_ "embed"
+	"strings"
+)
+
+// effLargeWordlistData is the EFF long wordlist for five-dice diceware
+// (https://www.eff.org/dice), published by the Electronic Frontier
+// Foundation under CC BY 3.0 US. Each line is a dice roll and a word
+// separated by a tab.
+//
+//go:embed wordlists/eff_large_wordlist.txt
+var effLargeWordlistData string
+
+// effLargeWordlistSize is the number of words in the EFF long wordlist,
+// one per roll of five dice.
+const effLargeWordlistSize = 7776
+
+// effLargeWords holds the words of the EFF long wordlist in dice order.
+var effLargeWords = parseWordlist(effLargeWordlistData)
+
+// effLargeWordSet is used to recognise the few words that contain a hyphen
+// when a passphrase is split back into words.
+var effLargeWordSet = func() map[string]bool {
+	set := make(map[string]bool, len(effLargeWords))
+	for _, word := range effLargeWords {
+		set[word] = true
+	}
+	return set
+}()
+
+func parseWordlist(data string) []string {
+	words := make([]string, 0, effLargeWordlistSize)
+	for _, line := range strings.Split(data, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		words = append(words, fields[len(fields)-1])
+	}
+	if len(words) != effLargeWordlistSize {
+		panic("services: EFF wordlist is incomplete")
+	}
+	return words
+}
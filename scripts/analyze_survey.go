@@ -1,7 +1,9 @@
 package main
 
 import (
+	"bufio"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
 	"os"
@@ -25,10 +27,14 @@ type SurveyResponse struct {
 	Name               string    `json:"name,omitempty"`
 	Updates            bool      `json:"updates"`
 	IPAddress          string    `json:"ip_address"`
+	Source             string    `json:"source,omitempty"`
 }
 
 func main() {
-	filePath := "data/survey_responses.json"
+	source := flag.String("source", "", "only analyze responses from this campaign/source (use \"none\" for untagged responses)")
+	flag.Parse()
+
+	filePath := "data/survey_responses.jsonl"
 	
 	// Check if file exists
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
@@ -43,6 +49,10 @@ func main() {
 		log.Fatalf("Error reading survey responses: %v", err)
 	}
 
+	if *source != "" {
+		responses = filterBySource(responses, *source)
+	}
+
 	if len(responses) == 0 {
 		fmt.Println("No survey responses found.")
 		return
@@ -51,8 +61,15 @@ func main() {
 	// Generate analysis
 	fmt.Printf("📊 ZeePass Survey Analysis\n")
 	fmt.Printf("=========================\n\n")
+	if *source != "" {
+		fmt.Printf("Source: %s\n", *source)
+	}
 	fmt.Printf("Total Responses: %d\n\n", len(responses))
 
+	if *source == "" {
+		analyzeSources(responses)
+	}
+
 	analyzeLikelihood(responses)
 	analyzeTools(responses)
 	analyzeUseCases(responses)
@@ -68,20 +85,101 @@ func main() {
 	}
 }
 
+// readSurveyResponses reads a JSON Lines file with one response per line.
 func readSurveyResponses(filePath string) ([]SurveyResponse, error) {
-	fileData, err := os.ReadFile(filePath)
+	file, err := os.Open(filePath)
 	if err != nil {
 		return nil, err
 	}
+	defer file.Close()
 
 	var responses []SurveyResponse
-	if err := json.Unmarshal(fileData, &responses); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal survey responses: %v", err)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var response SurveyResponse
+		if err := json.Unmarshal(scanner.Bytes(), &response); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal survey response on line %d: %v", line, err)
+		}
+		responses = append(responses, response)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
 	}
 
 	return responses, nil
 }
 
+// filterBySource keeps responses tagged with source; "none" selects
+// responses without a source.
+func filterBySource(responses []SurveyResponse, source string) []SurveyResponse {
+	source = strings.ToLower(strings.TrimSpace(source))
+	if source == "none" {
+		source = ""
+	}
+
+	var filtered []SurveyResponse
+	for _, r := range responses {
+		if r.Source == source {
+			filtered = append(filtered, r)
+		}
+	}
+	return filtered
+}
+
+func analyzeSources(responses []SurveyResponse) {
+	fmt.Printf("📣 Responses by Campaign/Source:\n")
+	bySource := make(map[string][]SurveyResponse)
+	for _, r := range responses {
+		source := r.Source
+		if source == "" {
+			source = "(none)"
+		}
+		bySource[source] = append(bySource[source], r)
+	}
+
+	var sources []string
+	for source := range bySource {
+		sources = append(sources, source)
+	}
+	sort.Slice(sources, func(i, j int) bool {
+		if len(bySource[sources[i]]) != len(bySource[sources[j]]) {
+			return len(bySource[sources[i]]) > len(bySource[sources[j]])
+		}
+		return sources[i] < sources[j]
+	})
+
+	for _, source := range sources {
+		group := bySource[source]
+		percentage := float64(len(group)) / float64(len(responses)) * 100
+		fmt.Printf("  %s: %d (%.1f%%), NPS %.1f\n", source, len(group), percentage, calculateNPS(group))
+	}
+	fmt.Println()
+}
+
+// calculateNPS returns the Net Promoter Score (% promoters - % detractors).
+func calculateNPS(responses []SurveyResponse) float64 {
+	promoters, detractors, total := 0, 0, 0
+	for _, r := range responses {
+		if r.NPS < 0 || r.NPS > 10 {
+			continue
+		}
+		total++
+		if r.NPS >= 9 {
+			promoters++
+		} else if r.NPS <= 6 {
+			detractors++
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(promoters-detractors) / float64(total) * 100
+}
+
 func analyzeLikelihood(responses []SurveyResponse) {
 	fmt.Printf("🎯 Likelihood to Use ZeePass:\n")
 	likelihood := make(map[string]int)